@@ -2,16 +2,25 @@ package main
 
 import (
 	"account/internal/application"
+	"account/internal/domain"
+	"account/internal/infrastructure/cache"
 	"account/internal/infrastructure/configuration"
+	"account/internal/infrastructure/jwt"
 	"account/internal/infrastructure/logger"
+	"account/internal/infrastructure/mail"
 	"account/internal/infrastructure/postgresql"
 	code_repository "account/internal/infrastructure/repository/code"
+	email_repository "account/internal/infrastructure/repository/email"
 	session_repository "account/internal/infrastructure/repository/session"
 	user_repository "account/internal/infrastructure/repository/user"
+	"account/internal/infrastructure/scheduler"
+	"account/internal/infrastructure/sms"
 	code_service "account/internal/service/code"
+	email_service "account/internal/service/email"
 	session_service "account/internal/service/session"
 	user_service "account/internal/service/user"
 	"account/internal/transport/grpc"
+	"account/internal/transport/http"
 	"context"
 	"log"
 	"os"
@@ -41,17 +50,89 @@ func main() {
 
 	// repositories
 	userRepository := user_repository.New(db)
-	codeRepository := code_repository.New(db)
-	sessionRepository := session_repository.New(db)
+	codeRepository := code_repository.New(db, []byte(cfg.SMS.CodeSecret))
+	emailRepository := email_repository.New(db)
+	var sessionCache *cache.Cache[string, domain.Session]
+	if cfg.Session.CacheSize > 0 {
+		sessionCache = cache.New[string, domain.Session](cfg.Session.CacheSize, cfg.Session.CacheTTL)
+	}
+	sessionRepository := session_repository.New(db, sessionCache)
+
+	var signer session_service.Signer
+	if cfg.Session.TokenFormat == configuration.TokenJWT {
+		jwtSigner, err := jwt.New(&cfg.JWT)
+		if err != nil {
+			logger.Fatal("Failed to load jwt signing keys", zap.Error(err))
+		}
+		signer = jwtSigner
+	}
+
+	if err := code_service.Policy(&cfg.SMS).Validate(); err != nil {
+		logger.Fatal("Invalid sms code policy", zap.Error(err))
+	}
+
+	smsSender, err := sms.New(&cfg.SMS, logger)
+	if err != nil {
+		logger.Fatal("Failed to configure sms senders", zap.Error(err))
+	}
+
+	if err := email_service.Policy(&cfg.Mail).Validate(); err != nil {
+		logger.Fatal("Invalid email code policy", zap.Error(err))
+	}
+
+	mailer, err := mail.New(&cfg.Mail)
+	if err != nil {
+		logger.Fatal("Failed to configure mailer", zap.Error(err))
+	}
+	if mailer == nil {
+		logger.Warn("MAIL_MAILER is not set, emails can't be added")
+	}
 
 	// services
 	userService := user_service.New(userRepository)
-	codeService := code_service.New(&cfg.SMS, codeRepository)
-	sessionService := session_service.New(sessionRepository)
+	codeService := code_service.New(&cfg.SMS, codeRepository, smsSender)
+	sessionService := session_service.New(&cfg.Session, sessionRepository, signer)
+	emailService := email_service.New(&cfg.Mail, cfg.SMS.CodeSecret, emailRepository, mailer)
+
+	useCase := application.New(logger, userService, codeService, sessionService, emailService)
+
+	grpcServer := grpc.New(useCase, cfg.Server.TrustedProxies)
+	callbackServer := http.New(useCase, smsSender, cfg.SMS.CallbackToken)
+
+	// background jobs
+	jobs := scheduler.New()
+	jobs.Every(cfg.Session.PurgeInterval, useCase.PurgeExpiredSessions)
+	jobs.Every(cfg.SMS.OutboxInterval, useCase.DeliverSMS)
+	jobs.Every(cfg.SMS.StatusPollInterval, useCase.PollSMSStatuses)
+	jobs.Every(cfg.SMS.PurgeInterval, useCase.PurgeExpiredCodes)
+
+	if sessionCache != nil {
+		jobs.Go(func(c context.Context) {
+			for {
+				err := sessionRepository.Listen(c)
+				if c.Err() != nil {
+					return
+				}
+				logger.Error("session revocation listener stopped, restarting", zap.Error(err))
 
-	useCase := application.New(logger, userService, codeService, sessionService)
+				select {
+				case <-c.Done():
+					return
+				case <-time.After(time.Second * 5):
+				}
+			}
+		})
 
-	grpcServer := grpc.New(useCase)
+		jobs.Every(cfg.Session.CacheStatsInterval, func(c context.Context) {
+			stats := sessionRepository.CacheStats()
+			logger.Info("session cache stats",
+				zap.Uint64("hits", stats.Hits),
+				zap.Uint64("misses", stats.Misses),
+				zap.Uint64("evictions", stats.Evictions),
+				zap.Int("entries", stats.Entries),
+			)
+		})
+	}
 
 	go func() {
 		if err := grpcServer.Run(cfg.Server.GrpcSocker); err != nil {
@@ -59,12 +140,28 @@ func main() {
 		}
 	}()
 
+	if cfg.SMS.CallbackAddr != "" {
+		if cfg.SMS.CallbackToken == "" {
+			logger.Fatal("SMS_CALLBACK_TOKEN is required to receive sms delivery reports")
+		}
+
+		go func() {
+			if err := callbackServer.Run(cfg.SMS.CallbackAddr); err != nil {
+				logger.Fatal("sms callback server down", zap.Error(err))
+			}
+		}()
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
 	<-quit
 
-	_, shutdown := context.WithTimeout(context.Background(), time.Second*3)
+	shutdownCtx, shutdown := context.WithTimeout(context.Background(), time.Second*3)
 	defer shutdown()
 
 	grpcServer.Stop()
+	if err := callbackServer.Stop(shutdownCtx); err != nil {
+		logger.Error("failed to stop sms callback server", zap.Error(err))
+	}
+	jobs.Stop()
 }
@@ -12,7 +12,7 @@ import (
 	"go.uber.org/zap"
 )
 
-func setup(t *testing.T) (context.Context, *zap.Logger, *mock.MockUserService, *mock.MockCodeService, *mock.MockSessionService) {
+func setup(t *testing.T) (context.Context, *zap.Logger, *mock.MockUserService, *mock.MockCodeService, *mock.MockSessionService, *mock.MockEmailService) {
 	ctx := context.Background()
 
 	logger, err := zap.NewProduction()
@@ -26,20 +26,21 @@ func setup(t *testing.T) (context.Context, *zap.Logger, *mock.MockUserService, *
 	userService := mock.NewMockUserService(ctrl)
 	codeService := mock.NewMockCodeService(ctrl)
 	sessionService := mock.NewMockSessionService(ctrl)
+	emailService := mock.NewMockEmailService(ctrl)
 
-	return ctx, logger, userService, codeService, sessionService
+	return ctx, logger, userService, codeService, sessionService, emailService
 }
 
 func TestRegister(t *testing.T) {
-	c, logger, userService, codeService, sessionService := setup(t)
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
 
 	t.Run("success", func(t *testing.T) {
 		userService.EXPECT().IsPhoneExists(c, "7775559966").Return(false, nil)
-		codeService.EXPECT().Send(c, "7775559966", "127.0.0.1").Return(nil)
+		codeService.EXPECT().Send(c, "7775559966", "127.0.0.1", domain.PurposeRegister, domain.ChannelSMS, domain.LocaleRussian).Return(nil)
 
-		app := New(logger, userService, codeService, sessionService)
+		app := New(logger, userService, codeService, sessionService, emailService)
 
-		dto := &dtos.RegisterInput{Phone: "7775559966", IP: "127.0.0.1"}
+		dto := &dtos.RegisterInput{Phone: "7775559966", IP: "127.0.0.1", Locale: "ru-KZ"}
 		err := app.Register(c, dto)
 
 		assert.NoError(t, err)
@@ -48,41 +49,74 @@ func TestRegister(t *testing.T) {
 	t.Run("fail", func(t *testing.T) {
 		userService.EXPECT().IsPhoneExists(c, "7775559966").Return(true, nil)
 
-		app := New(logger, userService, codeService, sessionService)
+		app := New(logger, userService, codeService, sessionService, emailService)
 
 		dto := &dtos.RegisterInput{Phone: "7775559966"}
 		err := app.Register(c, dto)
 
 		assert.ErrorIs(t, err, domain.ErrPhoneAlreadyInUse)
 	})
+
+	t.Run("channel", func(t *testing.T) {
+		userService.EXPECT().IsPhoneExists(c, "7775559966").Return(false, nil)
+		codeService.EXPECT().Send(c, "7775559966", "127.0.0.1", domain.PurposeRegister, domain.ChannelTelegram, domain.LocaleKazakh).Return(nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		dto := &dtos.RegisterInput{Phone: "7775559966", IP: "127.0.0.1", Channel: "telegram"}
+		err := app.Register(c, dto)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid channel", func(t *testing.T) {
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		dto := &dtos.RegisterInput{Phone: "7775559966", Channel: "pigeon"}
+		err := app.Register(c, dto)
+
+		assert.ErrorIs(t, err, domain.ErrInvalidChannel)
+	})
 }
 
 func TestConfirmCode(t *testing.T) {
-	c, logger, userService, codeService, sessionService := setup(t)
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
 
 	t.Run("success", func(t *testing.T) {
-		codeService.EXPECT().Verify(c, "7778889966", "1234").Return(nil)
+		codeService.EXPECT().Verify(c, "7778889966", domain.PurposeRegister, "1234").Return(nil)
 
-		app := New(logger, userService, codeService, sessionService)
+		app := New(logger, userService, codeService, sessionService, emailService)
 
 		dto := &dtos.ConfirmCodeInput{Phone: "7778889966", Code: "1234"}
 		err := app.ConfirmCode(c, dto)
 
 		assert.NoError(t, err)
 	})
+
+	t.Run("too many attempts", func(t *testing.T) {
+		codeService.EXPECT().Verify(c, "7778889966", domain.PurposeRegister, "1234").Return(domain.ErrTooManyAttempts)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		dto := &dtos.ConfirmCodeInput{Phone: "7778889966", Code: "1234"}
+		err := app.ConfirmCode(c, dto)
+
+		assert.ErrorIs(t, err, domain.ErrTooManyAttempts)
+	})
 }
 
 func TestCompleteRegister(t *testing.T) {
-	c, logger, userService, codeService, sessionService := setup(t)
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
 
 	t.Run("success", func(t *testing.T) {
 		// setup mocks
-		codeService.EXPECT().Verify(c, "7778889966", "1234").Return(nil)
-		codeService.EXPECT().RemoveAll(c, "7778889966").Return(nil)
-		userService.EXPECT().Create(c, gomock.Any(), gomock.Any(), gomock.Any()).Return(1, nil)
-		sessionService.EXPECT().Create(c, 1).Return(&dtos.AuthOutput{AccessToken: "this is a token"}, nil)
+		codeService.EXPECT().Verify(c, "7778889966", domain.PurposeRegister, "1234").Return(nil)
+		codeService.EXPECT().RemoveAll(c, "7778889966", domain.PurposeRegister).Return(nil)
+		userService.EXPECT().Create(c, gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).Return(1, nil)
+		userService.EXPECT().FindOneByID(c, 1).Return(&domain.User{ID: 1, Role: &domain.Role{ID: domain.UserRole}}, nil)
+		sessionService.EXPECT().Create(c, gomock.Any(), gomock.Any()).Return(&dtos.AuthOutput{AccessToken: "this is a token"}, nil)
 
-		app := New(logger, userService, codeService, sessionService)
+		app := New(logger, userService, codeService, sessionService, emailService)
 
 		dto := &dtos.CompleteRegisterInput{
 			Phone:    "7778889966",
@@ -99,14 +133,14 @@ func TestCompleteRegister(t *testing.T) {
 }
 
 func TestLogin(t *testing.T) {
-	c, logger, userService, codeService, sessionService := setup(t)
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
 
 	t.Run("success", func(t *testing.T) {
 		user, _ := domain.NewUser("john", "7775556699", "12345678")
 		userService.EXPECT().FindOneByPhone(c, "7775556699").Return(user, nil)
-		sessionService.EXPECT().Create(c, gomock.Any()).Return(&dtos.AuthOutput{AccessToken: "this is a token"}, nil)
+		sessionService.EXPECT().Create(c, gomock.Any(), gomock.Any()).Return(&dtos.AuthOutput{AccessToken: "this is a token"}, nil)
 
-		service := New(logger, userService, codeService, sessionService)
+		service := New(logger, userService, codeService, sessionService, emailService)
 
 		dto := &dtos.LoginInput{Phone: "7775556699", Password: "12345678"}
 		output, err := service.Login(c, dto)
@@ -118,11 +152,538 @@ func TestLogin(t *testing.T) {
 	t.Run("fail", func(t *testing.T) {
 		user, _ := domain.NewUser("john", "7775556699", "12345678")
 		userService.EXPECT().FindOneByPhone(c, "7775556699").Return(user, nil)
-		service := New(logger, userService, codeService, sessionService)
+		service := New(logger, userService, codeService, sessionService, emailService)
 
 		dto := &dtos.LoginInput{Phone: "7775556699", Password: "wrongpass"}
 		_, err := service.Login(c, dto)
 
 		assert.ErrorIs(t, err, domain.ErrInvalidCredentials)
 	})
+
+	t.Run("email", func(t *testing.T) {
+		user, _ := domain.NewUser("john", "7775556699", "12345678")
+		userService.EXPECT().FindOneByEmail(c, "john@example.com").Return(user, nil)
+		sessionService.EXPECT().Create(c, user, gomock.Any()).Return(&dtos.AuthOutput{AccessToken: "this is a token"}, nil)
+
+		service := New(logger, userService, codeService, sessionService, emailService)
+
+		dto := &dtos.LoginInput{Email: "John@Example.com", Password: "12345678"}
+		output, err := service.Login(c, dto)
+
+		assert.NoError(t, err)
+		assert.NotZero(t, output)
+	})
+}
+
+func TestRefresh(t *testing.T) {
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		sessionService.EXPECT().Refresh(c, "refresh").Return(&dtos.AuthOutput{AccessToken: "access", RefreshToken: "new refresh"}, nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		output, err := app.Refresh(c, &dtos.RefreshInput{RefreshToken: "refresh"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "new refresh", output.RefreshToken)
+	})
+
+	t.Run("reused", func(t *testing.T) {
+		sessionService.EXPECT().Refresh(c, "refresh").Return(nil, domain.ErrRefreshTokenReused)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		_, err := app.Refresh(c, &dtos.RefreshInput{RefreshToken: "refresh"})
+
+		assert.ErrorIs(t, err, domain.ErrRefreshTokenReused)
+	})
+}
+
+func TestValidateSession(t *testing.T) {
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		role := &domain.Role{ID: domain.UserRole, Name: "user", Permissions: []string{}}
+		// the user comes with the session, it isn't looked up
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{UserID: 1, Username: "john", Role: *role}, nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		output, err := app.ValidateSession(c, &dtos.ValidateSessionInput{AccessToken: "token"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, output.UserID)
+		assert.Equal(t, "john", output.Username)
+		assert.Equal(t, role, output.Role)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "wrong token").Return(nil, domain.ErrInvalidAccessToken)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		_, err := app.ValidateSession(c, &dtos.ValidateSessionInput{AccessToken: "wrong token"})
+
+		assert.ErrorIs(t, err, domain.ErrInvalidAccessToken)
+	})
+
+	t.Run("deleted user", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{UserID: 2}, nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		_, err := app.ValidateSession(c, &dtos.ValidateSessionInput{AccessToken: "token"})
+
+		assert.ErrorIs(t, err, domain.ErrInvalidAccessToken)
+	})
+}
+
+func TestLogout(t *testing.T) {
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		sessionService.EXPECT().Remove(c, "token").Return(nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.Logout(c, &dtos.LogoutInput{AccessToken: "token"})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		sessionService.EXPECT().Remove(c, "wrong token").Return(domain.ErrInvalidAccessToken)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.Logout(c, &dtos.LogoutInput{AccessToken: "wrong token"})
+
+		assert.ErrorIs(t, err, domain.ErrInvalidAccessToken)
+	})
+}
+
+func TestLogoutAll(t *testing.T) {
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{UserID: 1}, nil)
+		sessionService.EXPECT().RemoveAll(c, 1, "").Return(nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.LogoutAll(c, &dtos.LogoutAllInput{AccessToken: "token"})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("keep current", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{UserID: 1}, nil)
+		sessionService.EXPECT().RemoveAll(c, 1, "token").Return(nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.LogoutAll(c, &dtos.LogoutAllInput{AccessToken: "token", KeepCurrent: true})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "wrong token").Return(nil, domain.ErrInvalidAccessToken)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.LogoutAll(c, &dtos.LogoutAllInput{AccessToken: "wrong token"})
+
+		assert.ErrorIs(t, err, domain.ErrInvalidAccessToken)
+	})
+
+	t.Run("impersonated", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{UserID: 1, ImpersonatorID: 2}, nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.LogoutAll(c, &dtos.LogoutAllInput{AccessToken: "token"})
+
+		assert.ErrorIs(t, err, domain.ErrImpersonatedSession)
+	})
+}
+
+func TestListSessions(t *testing.T) {
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{ID: 2, UserID: 1}, nil)
+		sessionService.EXPECT().List(c, 1).Return([]domain.Session{
+			{ID: 2, UserID: 1, Device: domain.NewDevice("127.0.0.1", "", "android", "Pixel 8")},
+			{ID: 1, UserID: 1, Device: domain.NewDevice("127.0.0.2", "Mozilla/5.0", "web", "")},
+		}, nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		output, err := app.ListSessions(c, &dtos.ListSessionsInput{AccessToken: "token"})
+
+		assert.NoError(t, err)
+		assert.Len(t, output, 2)
+		assert.True(t, output[0].Current)
+		assert.Equal(t, "Pixel 8", output[0].DeviceName)
+		assert.False(t, output[1].Current)
+	})
+}
+
+func TestRevokeSession(t *testing.T) {
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{ID: 2, UserID: 1}, nil)
+		sessionService.EXPECT().RemoveOne(c, 1, 3).Return(nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.RevokeSession(c, &dtos.RevokeSessionInput{AccessToken: "token", SessionID: 3})
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{ID: 2, UserID: 1}, nil)
+		sessionService.EXPECT().RemoveOne(c, 1, 4).Return(domain.ErrSessionNotFound)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.RevokeSession(c, &dtos.RevokeSessionInput{AccessToken: "token", SessionID: 4})
+
+		assert.ErrorIs(t, err, domain.ErrSessionNotFound)
+	})
+}
+
+func TestImpersonate(t *testing.T) {
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
+
+	staff := &domain.User{ID: 2, Role: &domain.Role{ID: domain.ModeratorRole}}
+	target := &domain.User{ID: 1, Role: &domain.Role{ID: domain.UserRole}}
+
+	t.Run("success", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{UserID: 2}, nil)
+		userService.EXPECT().FindOneByID(c, 2).Return(staff, nil)
+		userService.EXPECT().FindOneByID(c, 1).Return(target, nil)
+		sessionService.EXPECT().Impersonate(c, staff, target, "report #42", gomock.Any()).Return(&dtos.AuthOutput{AccessToken: "impersonated token"}, nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		output, err := app.Impersonate(c, &dtos.ImpersonateInput{AccessToken: "token", TargetUserID: 1, Reason: " report #42 "})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "impersonated token", output.AccessToken)
+	})
+
+	t.Run("no reason", func(t *testing.T) {
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		_, err := app.Impersonate(c, &dtos.ImpersonateInput{AccessToken: "token", TargetUserID: 1, Reason: " "})
+
+		assert.ErrorIs(t, err, domain.ErrReasonRequired)
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{UserID: 1}, nil)
+		userService.EXPECT().FindOneByID(c, 1).Return(target, nil)
+		userService.EXPECT().FindOneByID(c, 2).Return(staff, nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		_, err := app.Impersonate(c, &dtos.ImpersonateInput{AccessToken: "token", TargetUserID: 2, Reason: "report #42"})
+
+		assert.ErrorIs(t, err, domain.ErrImpersonationDenied)
+	})
+
+	t.Run("from impersonated session", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{UserID: 2, ImpersonatorID: 3}, nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		_, err := app.Impersonate(c, &dtos.ImpersonateInput{AccessToken: "token", TargetUserID: 1, Reason: "report #42"})
+
+		assert.ErrorIs(t, err, domain.ErrImpersonatedSession)
+	})
+}
+
+func TestWatchRevocations(t *testing.T) {
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		sessionService.EXPECT().ValidateWatchToken("secret").Return(nil)
+		sessionService.EXPECT().WatchRevocations(c, "", gomock.Any()).DoAndReturn(func(_ context.Context, _ string, send func(*domain.Revocation) error) error {
+			return send(&domain.Revocation{Cursor: domain.RevocationCursor{TxID: 100, ID: 1}, SessionID: 1, Reason: domain.RevokedByLogout})
+		})
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		var sent []*dtos.RevocationOutput
+		err := app.WatchRevocations(c, &dtos.WatchRevocationsInput{Token: "secret"}, func(revocation *dtos.RevocationOutput) error {
+			sent = append(sent, revocation)
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Len(t, sent, 1)
+		assert.Equal(t, "100.1", sent[0].Cursor)
+		assert.Equal(t, "logout", sent[0].Reason)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		sessionService.EXPECT().ValidateWatchToken("secret").Return(nil)
+		sessionService.EXPECT().WatchRevocations(c, "wrong", gomock.Any()).Return(domain.ErrInvalidCursor)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.WatchRevocations(c, &dtos.WatchRevocationsInput{Cursor: "wrong", Token: "secret"}, func(revocation *dtos.RevocationOutput) error {
+			return nil
+		})
+
+		assert.ErrorIs(t, err, domain.ErrInvalidCursor)
+	})
+
+	t.Run("invalid token", func(t *testing.T) {
+		sessionService.EXPECT().ValidateWatchToken("wrong").Return(domain.ErrInvalidServiceToken)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.WatchRevocations(c, &dtos.WatchRevocationsInput{Token: "wrong"}, func(revocation *dtos.RevocationOutput) error {
+			return nil
+		})
+
+		assert.ErrorIs(t, err, domain.ErrInvalidServiceToken)
+	})
+}
+
+func TestGetJWKS(t *testing.T) {
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		sessionService.EXPECT().PublicKeys().Return([]domain.PublicKey{{ID: "first"}, {ID: "second"}})
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		keys := app.GetJWKS(c)
+
+		assert.Len(t, keys, 2)
+	})
+}
+
+func TestDeliverSMS(t *testing.T) {
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		codeService.EXPECT().DeliverQueued(c).Return(2, 1, nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		app.DeliverSMS(c)
+	})
+}
+
+func TestGetCodeDeliveryStatus(t *testing.T) {
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
+
+	owner := func() {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{UserID: 1}, nil)
+		userService.EXPECT().FindOneByID(c, 1).Return(&domain.User{ID: 1, Phone: "7778889966", Role: &domain.Role{ID: domain.UserRole}}, nil)
+	}
+
+	t.Run("success", func(t *testing.T) {
+		owner()
+		codeService.EXPECT().DeliveryStatus(c, "7778889966").Return(&domain.SMS{Status: domain.SMSDelivered, Channel: domain.ChannelSMS, Attempts: 1}, nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		res, err := app.GetCodeDeliveryStatus(c, &dtos.CodeDeliveryStatusInput{AccessToken: "token", Phone: "7778889966"})
+		assert.NoError(t, err)
+		assert.Equal(t, "delivered", res.Status)
+		assert.Equal(t, "sms", res.Channel)
+		assert.Equal(t, 1, res.Attempts)
+	})
+
+	t.Run("given up", func(t *testing.T) {
+		owner()
+		codeService.EXPECT().DeliveryStatus(c, "7778889966").Return(&domain.SMS{Status: domain.SMSDead, Attempts: 8}, nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		res, err := app.GetCodeDeliveryStatus(c, &dtos.CodeDeliveryStatusInput{AccessToken: "token", Phone: "7778889966"})
+		assert.NoError(t, err)
+		assert.Equal(t, "failed", res.Status)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{UserID: 2}, nil)
+		userService.EXPECT().FindOneByID(c, 2).Return(&domain.User{ID: 2, Phone: "7778889955", Role: &domain.Role{ID: domain.UserRole}}, nil)
+		codeService.EXPECT().DeliveryStatus(c, "7778889955").Return(nil, domain.ErrSMSNotFound)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		_, err := app.GetCodeDeliveryStatus(c, &dtos.CodeDeliveryStatusInput{AccessToken: "token", Phone: "7778889955"})
+		assert.ErrorIs(t, err, domain.ErrSMSNotFound)
+	})
+
+	t.Run("support", func(t *testing.T) {
+		support := &domain.Role{ID: domain.ModeratorRole, Permissions: []string{domain.PermissionViewCodeDelivery}}
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{UserID: 3}, nil)
+		userService.EXPECT().FindOneByID(c, 3).Return(&domain.User{ID: 3, Phone: "7770001122", Role: support}, nil)
+		codeService.EXPECT().DeliveryStatus(c, "7778889966").Return(&domain.SMS{Status: domain.SMSSent}, nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		res, err := app.GetCodeDeliveryStatus(c, &dtos.CodeDeliveryStatusInput{AccessToken: "token", Phone: "7778889966"})
+		assert.NoError(t, err)
+		assert.Equal(t, "sent", res.Status)
+	})
+
+	t.Run("other phone", func(t *testing.T) {
+		owner()
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		_, err := app.GetCodeDeliveryStatus(c, &dtos.CodeDeliveryStatusInput{AccessToken: "token", Phone: "7778889955"})
+		assert.ErrorIs(t, err, domain.ErrPermissionDenied)
+	})
+
+	t.Run("invalid access token", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "wrong").Return(nil, domain.ErrInvalidAccessToken)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		_, err := app.GetCodeDeliveryStatus(c, &dtos.CodeDeliveryStatusInput{AccessToken: "wrong", Phone: "7778889966"})
+		assert.ErrorIs(t, err, domain.ErrInvalidAccessToken)
+	})
+}
+
+func TestUpdateSMSStatus(t *testing.T) {
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		codeService.EXPECT().UpdateStatus(c, "smsc", "7", domain.SMSDelivered).Return(nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.UpdateSMSStatus(c, &dtos.SMSStatusInput{Provider: "smsc", MessageID: "7", Status: domain.SMSDelivered})
+		assert.NoError(t, err)
+	})
+}
+
+func TestPollSMSStatuses(t *testing.T) {
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		codeService.EXPECT().PollStatuses(c).Return(1, nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		app.PollSMSStatuses(c)
+	})
+}
+
+func TestPurgeExpiredSessions(t *testing.T) {
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		sessionService.EXPECT().PurgeExpired(c).Return(int64(2), nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		app.PurgeExpiredSessions(c)
+	})
+}
+
+func TestPurgeExpiredCodes(t *testing.T) {
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		codeService.EXPECT().PurgeExpired(c).Return(int64(3), int64(1), int64(2), nil)
+		emailService.EXPECT().PurgeExpired(c).Return(int64(4), nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		app.PurgeExpiredCodes(c)
+	})
+}
+
+func TestAddEmail(t *testing.T) {
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{UserID: 1}, nil)
+		userService.EXPECT().FindOneByID(c, 1).Return(&domain.User{ID: 1, Locale: domain.LocaleRussian}, nil)
+		userService.EXPECT().FindOneByEmail(c, "john@example.com").Return(nil, domain.ErrUserNotFound)
+		emailService.EXPECT().Send(c, 1, "john@example.com", domain.LocaleRussian).Return(nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.AddEmail(c, &dtos.AddEmailInput{AccessToken: "token", Email: "john@example.com"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("used by another user", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{UserID: 1}, nil)
+		userService.EXPECT().FindOneByID(c, 1).Return(&domain.User{ID: 1}, nil)
+		userService.EXPECT().FindOneByEmail(c, "john@example.com").Return(&domain.User{ID: 2}, nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.AddEmail(c, &dtos.AddEmailInput{AccessToken: "token", Email: "john@example.com"})
+		assert.ErrorIs(t, err, domain.ErrEmailAlreadyInUse)
+	})
+
+	t.Run("invalid email", func(t *testing.T) {
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.AddEmail(c, &dtos.AddEmailInput{AccessToken: "token", Email: "john"})
+		assert.ErrorIs(t, err, domain.ErrInvalidEmail)
+	})
+
+	t.Run("impersonated session", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{UserID: 1, ImpersonatorID: 2}, nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.AddEmail(c, &dtos.AddEmailInput{AccessToken: "token", Email: "john@example.com"})
+		assert.ErrorIs(t, err, domain.ErrImpersonatedSession)
+	})
+}
+
+func TestVerifyEmail(t *testing.T) {
+	c, logger, userService, codeService, sessionService, emailService := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{UserID: 1}, nil)
+		userService.EXPECT().FindOneByID(c, 1).Return(&domain.User{ID: 1}, nil)
+		emailService.EXPECT().Verify(c, 1, "123456").Return("john@example.com", nil)
+		userService.EXPECT().SetEmail(c, 1, "john@example.com").Return(nil)
+		emailService.EXPECT().RemoveAll(c, 1).Return(nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.VerifyEmail(c, &dtos.VerifyEmailInput{AccessToken: "token", Code: "123456"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{UserID: 1}, nil)
+		userService.EXPECT().FindOneByID(c, 1).Return(&domain.User{ID: 1}, nil)
+		emailService.EXPECT().Verify(c, 1, "654321").Return("", domain.ErrInvalidCode)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.VerifyEmail(c, &dtos.VerifyEmailInput{AccessToken: "token", Code: "654321"})
+		assert.ErrorIs(t, err, domain.ErrInvalidCode)
+	})
+
+	t.Run("impersonated session", func(t *testing.T) {
+		sessionService.EXPECT().Validate(c, "token").Return(&domain.Session{UserID: 1, ImpersonatorID: 2}, nil)
+
+		app := New(logger, userService, codeService, sessionService, emailService)
+
+		err := app.VerifyEmail(c, &dtos.VerifyEmailInput{AccessToken: "token", Code: "123456"})
+		assert.ErrorIs(t, err, domain.ErrImpersonatedSession)
+	})
 }
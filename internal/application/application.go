@@ -4,6 +4,8 @@ import (
 	"account/internal/application/dtos"
 	"account/internal/domain"
 	"context"
+	"errors"
+	"strings"
 
 	"go.uber.org/zap"
 )
@@ -11,26 +13,53 @@ import (
 //go:generate mockgen -source ./application.go -destination ./mock/mock.go -package mock
 type UserService interface {
 	FindOneByPhone(c context.Context, phone string) (*domain.User, error)
+	FindOneByID(c context.Context, id int) (*domain.User, error)
+	FindOneByEmail(c context.Context, email string) (*domain.User, error)
 
 	IsPhoneExists(c context.Context, phone string) (bool, error)
 
-	Create(c context.Context, username, phone, password string) (int, error)
+	Create(c context.Context, username, phone, password string, locale domain.Locale) (int, error)
+	SetEmail(c context.Context, id int, email string) error
 }
 
 type CodeService interface {
-	Send(c context.Context, phone, ip string) error
-	Verify(c context.Context, phone, code string) error
-	RemoveAll(c context.Context, phone string) error
+	Send(c context.Context, phone, ip string, purpose domain.CodePurpose, channel domain.Channel, locale domain.Locale) error
+	Verify(c context.Context, phone string, purpose domain.CodePurpose, code string) error
+	RemoveAll(c context.Context, phone string, purpose domain.CodePurpose) error
+	DeliverQueued(c context.Context) (sent, dead int, err error)
+	PollStatuses(c context.Context) (int, error)
+	UpdateStatus(c context.Context, provider, providerMessageId string, status domain.SMSStatus) error
+	DeliveryStatus(c context.Context, phone string) (*domain.SMS, error)
+	PurgeExpired(c context.Context) (codes, failures, messages int64, err error)
+}
+
+type EmailService interface {
+	Send(c context.Context, userId int, email string, locale domain.Locale) error
+	Verify(c context.Context, userId int, code string) (string, error)
+	RemoveAll(c context.Context, userId int) error
+	PurgeExpired(c context.Context) (int64, error)
 }
 
 type SessionService interface {
-	Create(c context.Context, userId int) (*dtos.AuthOutput, error)
+	Create(c context.Context, user *domain.User, device domain.Device) (*dtos.AuthOutput, error)
+	Refresh(c context.Context, refreshToken string) (*dtos.AuthOutput, error)
+	Validate(c context.Context, accessToken string) (*domain.Session, error)
+	List(c context.Context, userId int) ([]domain.Session, error)
+	Remove(c context.Context, accessToken string) error
+	RemoveOne(c context.Context, userId, id int) error
+	RemoveAll(c context.Context, userId int, exceptAccessToken string) error
+	PurgeExpired(c context.Context) (int64, error)
+	PublicKeys() []domain.PublicKey
+	Impersonate(c context.Context, staff, target *domain.User, reason string, device domain.Device) (*dtos.AuthOutput, error)
+	ValidateWatchToken(token string) error
+	WatchRevocations(c context.Context, cursor string, send func(revocation *domain.Revocation) error) error
 }
 
 type app struct {
 	userService    UserService
 	codeService    CodeService
 	sessionService SessionService
+	emailService   EmailService
 	logger         *zap.Logger
 }
 
@@ -39,16 +68,23 @@ func New(
 	userService UserService,
 	codeService CodeService,
 	sessionService SessionService,
+	emailService EmailService,
 ) *app {
 	return &app{
 		logger:         logger,
 		userService:    userService,
 		codeService:    codeService,
 		sessionService: sessionService,
+		emailService:   emailService,
 	}
 }
 
 func (app *app) Register(c context.Context, dto *dtos.RegisterInput) error {
+	channel, err := domain.ParseChannel(dto.Channel)
+	if err != nil {
+		return err
+	}
+
 	exists, err := app.userService.IsPhoneExists(c, dto.Phone)
 	if err != nil {
 		app.logger.Error("failed to check phone", zap.Error(err))
@@ -58,7 +94,7 @@ func (app *app) Register(c context.Context, dto *dtos.RegisterInput) error {
 		return domain.ErrPhoneAlreadyInUse
 	}
 
-	if err := app.codeService.Send(c, dto.Phone, dto.IP); err != nil {
+	if err := app.codeService.Send(c, dto.Phone, dto.IP, domain.PurposeRegister, channel, domain.ParseLocale(dto.Locale)); err != nil {
 		app.logger.Error("failed to send code", zap.Error(err))
 		return err
 	}
@@ -67,32 +103,46 @@ func (app *app) Register(c context.Context, dto *dtos.RegisterInput) error {
 }
 
 func (app *app) ConfirmCode(c context.Context, dto *dtos.ConfirmCodeInput) error {
-	err := app.codeService.Verify(c, dto.Phone, dto.Code)
-	if err != nil {
+	return app.verifyCode(c, dto.Phone, domain.PurposeRegister, dto.Code)
+}
+
+func (app *app) verifyCode(c context.Context, phone string, purpose domain.CodePurpose, code string) error {
+	err := app.codeService.Verify(c, phone, purpose, code)
+	switch {
+	case err == nil:
+	case errors.Is(err, domain.ErrTooManyAttempts):
+		app.logger.Warn("code verification locked", zap.String("phone", phone), zap.String("purpose", string(purpose)))
+	case errors.Is(err, domain.ErrInvalidCode), errors.Is(err, domain.ErrCodeExpired):
+		// ordinary typos, only locks are worth attention
+		app.logger.Debug("wrong code entered", zap.String("purpose", string(purpose)), zap.Error(err))
+	default:
 		app.logger.Error("failed to confirm code", zap.Error(err))
-		return err
 	}
-	return nil
+	return err
 }
 
 func (app *app) CompleteRegister(c context.Context, dto *dtos.CompleteRegisterInput) (*dtos.AuthOutput, error) {
-	err := app.codeService.Verify(c, dto.Phone, dto.Code)
-	if err != nil {
-		app.logger.Error("failed to confirm code", zap.Error(err))
+	if err := app.verifyCode(c, dto.Phone, domain.PurposeRegister, dto.Code); err != nil {
 		return nil, err
 	}
 
-	userId, err := app.userService.Create(c, dto.Username, dto.Phone, dto.Password)
+	userId, err := app.userService.Create(c, dto.Username, dto.Phone, dto.Password, domain.ParseLocale(dto.Locale))
 	if err != nil {
 		app.logger.Error("failed to create user", zap.Error(err))
 		return nil, err
 	}
 
-	if err := app.codeService.RemoveAll(c, dto.Phone); err != nil {
+	if err := app.codeService.RemoveAll(c, dto.Phone, domain.PurposeRegister); err != nil {
 		app.logger.Error("failed to remove codes after register", zap.Error(err))
 	}
 
-	res, err := app.sessionService.Create(c, userId)
+	user, err := app.userService.FindOneByID(c, userId)
+	if err != nil {
+		app.logger.Error("failed to find created user", zap.Error(err))
+		return nil, err
+	}
+
+	res, err := app.sessionService.Create(c, user, dto.Device)
 	if err != nil {
 		app.logger.Error("failed to create session", zap.Error(err))
 		return nil, err
@@ -101,8 +151,9 @@ func (app *app) CompleteRegister(c context.Context, dto *dtos.CompleteRegisterIn
 	return res, nil
 }
 
+// Login accepts either the phone or a verified email with the password
 func (app *app) Login(c context.Context, dto *dtos.LoginInput) (*dtos.AuthOutput, error) {
-	user, err := app.userService.FindOneByPhone(c, dto.Phone)
+	user, err := app.findLoginUser(c, dto)
 	if err != nil {
 		app.logger.Error("failed to find user", zap.Error(err))
 		return &dtos.AuthOutput{}, err
@@ -112,5 +163,416 @@ func (app *app) Login(c context.Context, dto *dtos.LoginInput) (*dtos.AuthOutput
 		return &dtos.AuthOutput{}, domain.ErrInvalidCredentials
 	}
 
-	return app.sessionService.Create(c, user.ID)
+	return app.sessionService.Create(c, user, dto.Device)
+}
+
+func (app *app) findLoginUser(c context.Context, dto *dtos.LoginInput) (*domain.User, error) {
+	if dto.Email == "" {
+		return app.userService.FindOneByPhone(c, dto.Phone)
+	}
+
+	email, err := domain.ParseEmail(dto.Email)
+	if err != nil {
+		return nil, err
+	}
+	return app.userService.FindOneByEmail(c, email)
+}
+
+func (app *app) Refresh(c context.Context, dto *dtos.RefreshInput) (*dtos.AuthOutput, error) {
+	res, err := app.sessionService.Refresh(c, dto.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrRefreshTokenReused):
+			app.logger.Warn("refresh token reuse detected, session revoked")
+		case !errors.Is(err, domain.ErrInvalidRefreshToken) && !errors.Is(err, domain.ErrSessionExpired):
+			app.logger.Error("failed to refresh session", zap.Error(err))
+		}
+		return nil, err
+	}
+
+	return res, nil
+}
+
+func (app *app) ValidateSession(c context.Context, dto *dtos.ValidateSessionInput) (*dtos.ValidateSessionOutput, error) {
+	session, err := app.sessionService.Validate(c, dto.AccessToken)
+	if err != nil {
+		if !errors.Is(err, domain.ErrInvalidAccessToken) {
+			app.logger.Error("failed to validate session", zap.Error(err))
+		}
+		return nil, err
+	}
+
+	// the session carries the current user, so no lookup is needed
+	if session.Username == "" {
+		// the user is gone
+		return nil, domain.ErrInvalidAccessToken
+	}
+
+	return &dtos.ValidateSessionOutput{
+		SessionID:      session.ID,
+		UserID:         session.UserID,
+		Username:       session.Username,
+		Role:           &session.Role,
+		ImpersonatorID: session.ImpersonatorID,
+	}, nil
+}
+
+func (app *app) Logout(c context.Context, dto *dtos.LogoutInput) error {
+	err := app.sessionService.Remove(c, dto.AccessToken)
+	if err != nil && !errors.Is(err, domain.ErrInvalidAccessToken) {
+		app.logger.Error("failed to remove session", zap.Error(err))
+	}
+	return err
+}
+
+func (app *app) LogoutAll(c context.Context, dto *dtos.LogoutAllInput) error {
+	session, err := app.sessionService.Validate(c, dto.AccessToken)
+	if err != nil {
+		if !errors.Is(err, domain.ErrInvalidAccessToken) {
+			app.logger.Error("failed to validate session", zap.Error(err))
+		}
+		return err
+	}
+
+	if session.IsImpersonated() {
+		return domain.ErrImpersonatedSession
+	}
+
+	var except string
+	if dto.KeepCurrent {
+		except = dto.AccessToken
+	}
+
+	if err := app.sessionService.RemoveAll(c, session.UserID, except); err != nil {
+		app.logger.Error("failed to remove user sessions", zap.Error(err))
+		return err
+	}
+
+	return nil
+}
+
+func (app *app) ListSessions(c context.Context, dto *dtos.ListSessionsInput) ([]dtos.SessionOutput, error) {
+	current, err := app.sessionService.Validate(c, dto.AccessToken)
+	if err != nil {
+		if !errors.Is(err, domain.ErrInvalidAccessToken) {
+			app.logger.Error("failed to validate session", zap.Error(err))
+		}
+		return nil, err
+	}
+
+	sessions, err := app.sessionService.List(c, current.UserID)
+	if err != nil {
+		app.logger.Error("failed to list user sessions", zap.Error(err))
+		return nil, err
+	}
+
+	output := make([]dtos.SessionOutput, 0, len(sessions))
+	for _, session := range sessions {
+		output = append(output, dtos.SessionOutput{
+			ID:         session.ID,
+			IP:         session.Device.IP,
+			UserAgent:  session.Device.UserAgent,
+			Platform:   session.Device.Platform,
+			DeviceName: session.Device.Name,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			Current:    session.ID == current.ID,
+
+			Impersonated: session.IsImpersonated(),
+		})
+	}
+
+	return output, nil
+}
+
+func (app *app) RevokeSession(c context.Context, dto *dtos.RevokeSessionInput) error {
+	current, err := app.sessionService.Validate(c, dto.AccessToken)
+	if err != nil {
+		if !errors.Is(err, domain.ErrInvalidAccessToken) {
+			app.logger.Error("failed to validate session", zap.Error(err))
+		}
+		return err
+	}
+
+	if current.IsImpersonated() {
+		return domain.ErrImpersonatedSession
+	}
+
+	err = app.sessionService.RemoveOne(c, current.UserID, dto.SessionID)
+	if err != nil && !errors.Is(err, domain.ErrSessionNotFound) {
+		app.logger.Error("failed to revoke session", zap.Error(err))
+	}
+	return err
+}
+
+// Impersonate lets a staff member act as the target user, e.g. to debug a report.
+// Every impersonation is logged and stored in the audit log.
+func (app *app) Impersonate(c context.Context, dto *dtos.ImpersonateInput) (*dtos.AuthOutput, error) {
+	reason := strings.TrimSpace(dto.Reason)
+	if reason == "" {
+		return nil, domain.ErrReasonRequired
+	}
+
+	session, err := app.sessionService.Validate(c, dto.AccessToken)
+	if err != nil {
+		if !errors.Is(err, domain.ErrInvalidAccessToken) {
+			app.logger.Error("failed to validate session", zap.Error(err))
+		}
+		return nil, err
+	}
+
+	if session.IsImpersonated() {
+		return nil, domain.ErrImpersonatedSession
+	}
+
+	staff, err := app.userService.FindOneByID(c, session.UserID)
+	if err != nil {
+		app.logger.Error("failed to find session user", zap.Error(err))
+		return nil, err
+	}
+
+	target, err := app.userService.FindOneByID(c, dto.TargetUserID)
+	if err != nil {
+		if !errors.Is(err, domain.ErrUserNotFound) {
+			app.logger.Error("failed to find impersonation target", zap.Error(err))
+		}
+		return nil, err
+	}
+
+	if !staff.CanImpersonate(target) {
+		app.logger.Warn("impersonation denied",
+			zap.Int("staff_id", staff.ID),
+			zap.Int("target_user_id", target.ID),
+		)
+		return nil, domain.ErrImpersonationDenied
+	}
+
+	res, err := app.sessionService.Impersonate(c, staff, target, reason, dto.Device)
+	if err != nil {
+		app.logger.Error("failed to create impersonation session", zap.Error(err))
+		return nil, err
+	}
+
+	app.logger.Info("impersonation started",
+		zap.Int("staff_id", staff.ID),
+		zap.Int("target_user_id", target.ID),
+		zap.String("reason", reason),
+		zap.String("ip", dto.Device.IP),
+	)
+
+	return res, nil
+}
+
+// AddEmail sends a code to the email, it's set to the user by VerifyEmail.
+// Staff acting as the user can't change their email.
+func (app *app) AddEmail(c context.Context, dto *dtos.AddEmailInput) error {
+	email, err := domain.ParseEmail(dto.Email)
+	if err != nil {
+		return err
+	}
+
+	user, err := app.sessionUser(c, dto.AccessToken)
+	if err != nil {
+		return err
+	}
+
+	owner, err := app.userService.FindOneByEmail(c, email)
+	if err == nil {
+		if owner.ID == user.ID {
+			return nil
+		}
+		return domain.ErrEmailAlreadyInUse
+	}
+	if !errors.Is(err, domain.ErrUserNotFound) {
+		app.logger.Error("failed to check email", zap.Error(err))
+		return err
+	}
+
+	if err := app.emailService.Send(c, user.ID, email, user.Locale); err != nil {
+		if !errors.Is(err, domain.ErrTooManyCodesSent) && !errors.Is(err, domain.ErrCodeSendingLimit) && !errors.Is(err, domain.ErrEmailDisabled) {
+			app.logger.Error("failed to send email code", zap.Error(err))
+		}
+		return err
+	}
+
+	return nil
+}
+
+// VerifyEmail sets the email the code was sent to as the verified email of the user
+func (app *app) VerifyEmail(c context.Context, dto *dtos.VerifyEmailInput) error {
+	user, err := app.sessionUser(c, dto.AccessToken)
+	if err != nil {
+		return err
+	}
+
+	email, err := app.emailService.Verify(c, user.ID, dto.Code)
+	if err != nil {
+		return err
+	}
+
+	if err := app.userService.SetEmail(c, user.ID, email); err != nil {
+		if !errors.Is(err, domain.ErrEmailAlreadyInUse) {
+			app.logger.Error("failed to set email", zap.Error(err))
+		}
+		return err
+	}
+
+	if err := app.emailService.RemoveAll(c, user.ID); err != nil {
+		app.logger.Error("failed to remove email codes", zap.Error(err))
+	}
+
+	return nil
+}
+
+// sessionUser returns the owner of the session, impersonated sessions are refused
+func (app *app) sessionUser(c context.Context, accessToken string) (*domain.User, error) {
+	session, err := app.sessionService.Validate(c, accessToken)
+	if err != nil {
+		if !errors.Is(err, domain.ErrInvalidAccessToken) {
+			app.logger.Error("failed to validate session", zap.Error(err))
+		}
+		return nil, err
+	}
+
+	if session.IsImpersonated() {
+		return nil, domain.ErrImpersonatedSession
+	}
+
+	user, err := app.userService.FindOneByID(c, session.UserID)
+	if err != nil {
+		app.logger.Error("failed to find session user", zap.Error(err))
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// WatchRevocations streams revoked sessions to send until c is done, see SessionService.WatchRevocations.
+// Only internal services holding the watch token may listen.
+func (app *app) WatchRevocations(c context.Context, dto *dtos.WatchRevocationsInput, send func(revocation *dtos.RevocationOutput) error) error {
+	if err := app.sessionService.ValidateWatchToken(dto.Token); err != nil {
+		return err
+	}
+
+	err := app.sessionService.WatchRevocations(c, dto.Cursor, func(revocation *domain.Revocation) error {
+		return send(&dtos.RevocationOutput{
+			Cursor:    revocation.Cursor.String(),
+			SessionID: revocation.SessionID,
+			UserID:    revocation.UserID,
+			Reason:    string(revocation.Reason),
+			RevokedAt: revocation.CreatedAt,
+		})
+	})
+	if c.Err() != nil {
+		// the client is gone or the server is stopping
+		return nil
+	}
+	if err != nil && !errors.Is(err, domain.ErrInvalidCursor) {
+		app.logger.Error("failed to watch revocations", zap.Error(err))
+	}
+	return err
+}
+
+func (app *app) GetJWKS(c context.Context) []domain.PublicKey {
+	return app.sessionService.PublicKeys()
+}
+
+// DeliverSMS sends messages queued in the outbox
+func (app *app) DeliverSMS(c context.Context) {
+	sent, dead, err := app.codeService.DeliverQueued(c)
+	if err != nil && c.Err() == nil {
+		app.logger.Error("failed to deliver queued sms", zap.Error(err))
+	}
+	if dead > 0 {
+		app.logger.Warn("sms delivery given up", zap.Int("count", dead))
+	}
+	if sent > 0 {
+		app.logger.Debug("delivered queued sms", zap.Int("count", sent))
+	}
+}
+
+// PollSMSStatuses asks providers about delivery of sent messages
+func (app *app) PollSMSStatuses(c context.Context) {
+	updated, err := app.codeService.PollStatuses(c)
+	if err != nil && c.Err() == nil {
+		app.logger.Warn("failed to poll sms delivery status", zap.Error(err))
+	}
+	if updated > 0 {
+		app.logger.Debug("updated sms delivery status", zap.Int("count", updated))
+	}
+}
+
+func (app *app) UpdateSMSStatus(c context.Context, dto *dtos.SMSStatusInput) error {
+	err := app.codeService.UpdateStatus(c, dto.Provider, dto.MessageID, dto.Status)
+	if err != nil && !errors.Is(err, domain.ErrSMSNotFound) {
+		app.logger.Error("failed to update sms delivery status", zap.String("provider", dto.Provider), zap.Error(err))
+	}
+	return err
+}
+
+// GetCodeDeliveryStatus tells what happened to the last code sent to the phone,
+// only the phone owner and support staff may ask
+func (app *app) GetCodeDeliveryStatus(c context.Context, dto *dtos.CodeDeliveryStatusInput) (*dtos.CodeDeliveryStatusOutput, error) {
+	user, err := app.sessionUser(c, dto.AccessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.CanViewCodeDelivery(dto.Phone) {
+		return nil, domain.ErrPermissionDenied
+	}
+
+	message, err := app.codeService.DeliveryStatus(c, dto.Phone)
+	if err != nil {
+		if !errors.Is(err, domain.ErrSMSNotFound) {
+			app.logger.Error("failed to find sms delivery status", zap.Error(err))
+		}
+		return nil, err
+	}
+
+	status := message.Status
+	if status == domain.SMSDead {
+		// clients don't need to tell whether we or the provider gave up
+		status = domain.SMSFailed
+	}
+
+	return &dtos.CodeDeliveryStatusOutput{
+		Status:    string(status),
+		Channel:   string(message.Channel),
+		Attempts:  message.Attempts,
+		QueuedAt:  message.CreatedAt,
+		UpdatedAt: message.StatusAt,
+	}, nil
+}
+
+func (app *app) PurgeExpiredSessions(c context.Context) {
+	count, err := app.sessionService.PurgeExpired(c)
+	if err != nil {
+		app.logger.Error("failed to purge expired sessions", zap.Error(err))
+		return
+	}
+	if count > 0 {
+		app.logger.Info("purged expired sessions", zap.Int64("count", count))
+	}
+}
+
+// PurgeExpiredCodes removes stale codes with their failures and sent messages,
+// and stale email codes, rows removed before shutdown are counted too
+func (app *app) PurgeExpiredCodes(c context.Context) {
+	codes, failures, messages, err := app.codeService.PurgeExpired(c)
+	if err != nil && c.Err() == nil {
+		app.logger.Error("failed to purge expired codes", zap.Error(err))
+	}
+
+	emailCodes, err := app.emailService.PurgeExpired(c)
+	if err != nil && c.Err() == nil {
+		app.logger.Error("failed to purge expired email codes", zap.Error(err))
+	}
+
+	app.logger.Info("purged expired codes",
+		zap.Int64("codes", codes),
+		zap.Int64("failures", failures),
+		zap.Int64("messages", messages),
+		zap.Int64("email_codes", emailCodes),
+	)
 }
@@ -1,8 +1,15 @@
 package dtos
 
+import (
+	"account/internal/domain"
+	"time"
+)
+
 type RegisterInput struct {
-	Phone string `json:"phone"`
-	IP    string // client ip address
+	Phone   string `json:"phone"`
+	Locale  string `json:"locale"`  // language of the sms, e.g. "kk", "ru" or "en"
+	Channel string `json:"channel"` // how to send the code: "sms", "telegram", "whatsapp" or "call", sms by default
+	IP      string // client ip address
 }
 
 type ConfirmCodeInput struct {
@@ -11,17 +18,123 @@ type ConfirmCodeInput struct {
 }
 
 type CompleteRegisterInput struct {
-	Phone    string `json:"phone"`
-	Code     string `json:"code"`
-	Username string `json:"username"`
-	Password string `json:"password"`
+	Phone    string        `json:"phone"`
+	Code     string        `json:"code"`
+	Username string        `json:"username"`
+	Password string        `json:"password"`
+	Locale   string        `json:"locale"` // preferred language of messages
+	Device   domain.Device // client device
 }
 
 type LoginInput struct {
-	Phone    string `json:"phone"`
-	Password string `json:"password"`
+	Phone    string        `json:"phone"`
+	Email    string        `json:"email"` // verified email, it's used instead of the phone if set
+	Password string        `json:"password"`
+	Device   domain.Device // client device
+}
+
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 type AuthOutput struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // access token lifetime in seconds
+
+	EvictedSessions []SessionOutput `json:"evicted_sessions"` // sessions removed to stay within the limit
+}
+
+type ValidateSessionInput struct {
+	AccessToken string `json:"access_token"`
+}
+
+type LogoutInput struct {
+	AccessToken string `json:"access_token"`
+}
+
+type LogoutAllInput struct {
+	AccessToken string `json:"access_token"`
+	KeepCurrent bool   `json:"keep_current"`
+}
+
+type ValidateSessionOutput struct {
+	SessionID int          `json:"session_id"`
+	UserID    int          `json:"user_id"`
+	Username  string       `json:"username"`
+	Role      *domain.Role `json:"role"`
+
+	ImpersonatorID int `json:"impersonator_id"` // staff member acting as the user, 0 if none
+}
+
+type ListSessionsInput struct {
+	AccessToken string `json:"access_token"`
+}
+
+type SessionOutput struct {
+	ID         int       `json:"id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Platform   string    `json:"platform"`
+	DeviceName string    `json:"device_name"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+
+	Impersonated bool `json:"impersonated"`
+}
+
+type RevokeSessionInput struct {
+	AccessToken string `json:"access_token"`
+	SessionID   int    `json:"session_id"`
+}
+
+type ImpersonateInput struct {
+	AccessToken  string        `json:"access_token"`
+	TargetUserID int           `json:"target_user_id"`
+	Reason       string        `json:"reason"`
+	Device       domain.Device // staff member device
+}
+
+type WatchRevocationsInput struct {
+	Cursor string `json:"cursor"` // empty to watch only new revocations
+	Token  string `json:"token"`
+}
+
+type RevocationOutput struct {
+	Cursor    string    `json:"cursor"` // pass to WatchRevocationsInput to resume after this revocation
+	SessionID int       `json:"session_id"`
+	UserID    int       `json:"user_id"`
+	Reason    string    `json:"reason"`
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+type AddEmailInput struct {
+	AccessToken string `json:"access_token"`
+	Email       string `json:"email"`
+}
+
+type VerifyEmailInput struct {
 	AccessToken string `json:"access_token"`
+	Code        string `json:"code"`
+}
+
+type CodeDeliveryStatusInput struct {
+	AccessToken string `json:"access_token"`
+	Phone       string `json:"phone"`
+}
+
+type CodeDeliveryStatusOutput struct {
+	Status    string    `json:"status"`  // queued, sent, delivered or failed
+	Channel   string    `json:"channel"` // channel which delivered the code, sms if the requested channel failed
+	Attempts  int       `json:"attempts"`
+	QueuedAt  time.Time `json:"queued_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SMSStatusInput is a delivery report posted by an sms provider
+type SMSStatusInput struct {
+	Provider  string
+	MessageID string
+	Status    domain.SMSStatus
 }
@@ -43,18 +43,48 @@ func (m *MockUserService) EXPECT() *MockUserServiceMockRecorder {
 }
 
 // Create mocks base method.
-func (m *MockUserService) Create(c context.Context, username, phone, password string) (int, error) {
+func (m *MockUserService) Create(c context.Context, username, phone, password string, locale domain.Locale) (int, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", c, username, phone, password)
+	ret := m.ctrl.Call(m, "Create", c, username, phone, password, locale)
 	ret0, _ := ret[0].(int)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
-func (mr *MockUserServiceMockRecorder) Create(c, username, phone, password any) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) Create(c, username, phone, password, locale any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUserService)(nil).Create), c, username, phone, password)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockUserService)(nil).Create), c, username, phone, password, locale)
+}
+
+// FindOneByEmail mocks base method.
+func (m *MockUserService) FindOneByEmail(c context.Context, email string) (*domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOneByEmail", c, email)
+	ret0, _ := ret[0].(*domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOneByEmail indicates an expected call of FindOneByEmail.
+func (mr *MockUserServiceMockRecorder) FindOneByEmail(c, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOneByEmail", reflect.TypeOf((*MockUserService)(nil).FindOneByEmail), c, email)
+}
+
+// FindOneByID mocks base method.
+func (m *MockUserService) FindOneByID(c context.Context, id int) (*domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOneByID", c, id)
+	ret0, _ := ret[0].(*domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOneByID indicates an expected call of FindOneByID.
+func (mr *MockUserServiceMockRecorder) FindOneByID(c, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOneByID", reflect.TypeOf((*MockUserService)(nil).FindOneByID), c, id)
 }
 
 // FindOneByPhone mocks base method.
@@ -87,6 +117,20 @@ func (mr *MockUserServiceMockRecorder) IsPhoneExists(c, phone any) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsPhoneExists", reflect.TypeOf((*MockUserService)(nil).IsPhoneExists), c, phone)
 }
 
+// SetEmail mocks base method.
+func (m *MockUserService) SetEmail(c context.Context, id int, email string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetEmail", c, id, email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetEmail indicates an expected call of SetEmail.
+func (mr *MockUserServiceMockRecorder) SetEmail(c, id, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetEmail", reflect.TypeOf((*MockUserService)(nil).SetEmail), c, id, email)
+}
+
 // MockCodeService is a mock of CodeService interface.
 type MockCodeService struct {
 	ctrl     *gomock.Controller
@@ -111,46 +155,205 @@ func (m *MockCodeService) EXPECT() *MockCodeServiceMockRecorder {
 	return m.recorder
 }
 
+// DeliverQueued mocks base method.
+func (m *MockCodeService) DeliverQueued(c context.Context) (int, int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeliverQueued", c)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(int)
+	ret2, _ := ret[2].(error)
+	return ret0, ret1, ret2
+}
+
+// DeliverQueued indicates an expected call of DeliverQueued.
+func (mr *MockCodeServiceMockRecorder) DeliverQueued(c any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeliverQueued", reflect.TypeOf((*MockCodeService)(nil).DeliverQueued), c)
+}
+
+// DeliveryStatus mocks base method.
+func (m *MockCodeService) DeliveryStatus(c context.Context, phone string) (*domain.SMS, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeliveryStatus", c, phone)
+	ret0, _ := ret[0].(*domain.SMS)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeliveryStatus indicates an expected call of DeliveryStatus.
+func (mr *MockCodeServiceMockRecorder) DeliveryStatus(c, phone any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeliveryStatus", reflect.TypeOf((*MockCodeService)(nil).DeliveryStatus), c, phone)
+}
+
+// PollStatuses mocks base method.
+func (m *MockCodeService) PollStatuses(c context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PollStatuses", c)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PollStatuses indicates an expected call of PollStatuses.
+func (mr *MockCodeServiceMockRecorder) PollStatuses(c any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PollStatuses", reflect.TypeOf((*MockCodeService)(nil).PollStatuses), c)
+}
+
+// PurgeExpired mocks base method.
+func (m *MockCodeService) PurgeExpired(c context.Context) (int64, int64, int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeExpired", c)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(int64)
+	ret2, _ := ret[2].(int64)
+	ret3, _ := ret[3].(error)
+	return ret0, ret1, ret2, ret3
+}
+
+// PurgeExpired indicates an expected call of PurgeExpired.
+func (mr *MockCodeServiceMockRecorder) PurgeExpired(c any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeExpired", reflect.TypeOf((*MockCodeService)(nil).PurgeExpired), c)
+}
+
 // RemoveAll mocks base method.
-func (m *MockCodeService) RemoveAll(c context.Context, phone string) error {
+func (m *MockCodeService) RemoveAll(c context.Context, phone string, purpose domain.CodePurpose) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "RemoveAll", c, phone)
+	ret := m.ctrl.Call(m, "RemoveAll", c, phone, purpose)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // RemoveAll indicates an expected call of RemoveAll.
-func (mr *MockCodeServiceMockRecorder) RemoveAll(c, phone any) *gomock.Call {
+func (mr *MockCodeServiceMockRecorder) RemoveAll(c, phone, purpose any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveAll", reflect.TypeOf((*MockCodeService)(nil).RemoveAll), c, phone)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveAll", reflect.TypeOf((*MockCodeService)(nil).RemoveAll), c, phone, purpose)
 }
 
 // Send mocks base method.
-func (m *MockCodeService) Send(c context.Context, phone, ip string) error {
+func (m *MockCodeService) Send(c context.Context, phone, ip string, purpose domain.CodePurpose, channel domain.Channel, locale domain.Locale) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Send", c, phone, ip)
+	ret := m.ctrl.Call(m, "Send", c, phone, ip, purpose, channel, locale)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Send indicates an expected call of Send.
-func (mr *MockCodeServiceMockRecorder) Send(c, phone, ip any) *gomock.Call {
+func (mr *MockCodeServiceMockRecorder) Send(c, phone, ip, purpose, channel, locale any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockCodeService)(nil).Send), c, phone, ip)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockCodeService)(nil).Send), c, phone, ip, purpose, channel, locale)
+}
+
+// UpdateStatus mocks base method.
+func (m *MockCodeService) UpdateStatus(c context.Context, provider, providerMessageId string, status domain.SMSStatus) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateStatus", c, provider, providerMessageId, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateStatus indicates an expected call of UpdateStatus.
+func (mr *MockCodeServiceMockRecorder) UpdateStatus(c, provider, providerMessageId, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatus", reflect.TypeOf((*MockCodeService)(nil).UpdateStatus), c, provider, providerMessageId, status)
 }
 
 // Verify mocks base method.
-func (m *MockCodeService) Verify(c context.Context, phone, code string) error {
+func (m *MockCodeService) Verify(c context.Context, phone string, purpose domain.CodePurpose, code string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", c, phone, purpose, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Verify indicates an expected call of Verify.
+func (mr *MockCodeServiceMockRecorder) Verify(c, phone, purpose, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockCodeService)(nil).Verify), c, phone, purpose, code)
+}
+
+// MockEmailService is a mock of EmailService interface.
+type MockEmailService struct {
+	ctrl     *gomock.Controller
+	recorder *MockEmailServiceMockRecorder
+	isgomock struct{}
+}
+
+// MockEmailServiceMockRecorder is the mock recorder for MockEmailService.
+type MockEmailServiceMockRecorder struct {
+	mock *MockEmailService
+}
+
+// NewMockEmailService creates a new mock instance.
+func NewMockEmailService(ctrl *gomock.Controller) *MockEmailService {
+	mock := &MockEmailService{ctrl: ctrl}
+	mock.recorder = &MockEmailServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockEmailService) EXPECT() *MockEmailServiceMockRecorder {
+	return m.recorder
+}
+
+// PurgeExpired mocks base method.
+func (m *MockEmailService) PurgeExpired(c context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeExpired", c)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeExpired indicates an expected call of PurgeExpired.
+func (mr *MockEmailServiceMockRecorder) PurgeExpired(c any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeExpired", reflect.TypeOf((*MockEmailService)(nil).PurgeExpired), c)
+}
+
+// RemoveAll mocks base method.
+func (m *MockEmailService) RemoveAll(c context.Context, userId int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveAll", c, userId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveAll indicates an expected call of RemoveAll.
+func (mr *MockEmailServiceMockRecorder) RemoveAll(c, userId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveAll", reflect.TypeOf((*MockEmailService)(nil).RemoveAll), c, userId)
+}
+
+// Send mocks base method.
+func (m *MockEmailService) Send(c context.Context, userId int, email string, locale domain.Locale) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Verify", c, phone, code)
+	ret := m.ctrl.Call(m, "Send", c, userId, email, locale)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
+// Send indicates an expected call of Send.
+func (mr *MockEmailServiceMockRecorder) Send(c, userId, email, locale any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockEmailService)(nil).Send), c, userId, email, locale)
+}
+
+// Verify mocks base method.
+func (m *MockEmailService) Verify(c context.Context, userId int, code string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Verify", c, userId, code)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
 // Verify indicates an expected call of Verify.
-func (mr *MockCodeServiceMockRecorder) Verify(c, phone, code any) *gomock.Call {
+func (mr *MockEmailServiceMockRecorder) Verify(c, userId, code any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockCodeService)(nil).Verify), c, phone, code)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Verify", reflect.TypeOf((*MockEmailService)(nil).Verify), c, userId, code)
 }
 
 // MockSessionService is a mock of SessionService interface.
@@ -178,16 +381,175 @@ func (m *MockSessionService) EXPECT() *MockSessionServiceMockRecorder {
 }
 
 // Create mocks base method.
-func (m *MockSessionService) Create(c context.Context, userId int) (*dtos.AuthOutput, error) {
+func (m *MockSessionService) Create(c context.Context, user *domain.User, device domain.Device) (*dtos.AuthOutput, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", c, userId)
+	ret := m.ctrl.Call(m, "Create", c, user, device)
 	ret0, _ := ret[0].(*dtos.AuthOutput)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // Create indicates an expected call of Create.
-func (mr *MockSessionServiceMockRecorder) Create(c, userId any) *gomock.Call {
+func (mr *MockSessionServiceMockRecorder) Create(c, user, device any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSessionService)(nil).Create), c, user, device)
+}
+
+// Impersonate mocks base method.
+func (m *MockSessionService) Impersonate(c context.Context, staff, target *domain.User, reason string, device domain.Device) (*dtos.AuthOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Impersonate", c, staff, target, reason, device)
+	ret0, _ := ret[0].(*dtos.AuthOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Impersonate indicates an expected call of Impersonate.
+func (mr *MockSessionServiceMockRecorder) Impersonate(c, staff, target, reason, device any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Impersonate", reflect.TypeOf((*MockSessionService)(nil).Impersonate), c, staff, target, reason, device)
+}
+
+// List mocks base method.
+func (m *MockSessionService) List(c context.Context, userId int) ([]domain.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "List", c, userId)
+	ret0, _ := ret[0].([]domain.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockSessionServiceMockRecorder) List(c, userId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockSessionService)(nil).List), c, userId)
+}
+
+// PublicKeys mocks base method.
+func (m *MockSessionService) PublicKeys() []domain.PublicKey {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublicKeys")
+	ret0, _ := ret[0].([]domain.PublicKey)
+	return ret0
+}
+
+// PublicKeys indicates an expected call of PublicKeys.
+func (mr *MockSessionServiceMockRecorder) PublicKeys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublicKeys", reflect.TypeOf((*MockSessionService)(nil).PublicKeys))
+}
+
+// PurgeExpired mocks base method.
+func (m *MockSessionService) PurgeExpired(c context.Context) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PurgeExpired", c)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PurgeExpired indicates an expected call of PurgeExpired.
+func (mr *MockSessionServiceMockRecorder) PurgeExpired(c any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PurgeExpired", reflect.TypeOf((*MockSessionService)(nil).PurgeExpired), c)
+}
+
+// Refresh mocks base method.
+func (m *MockSessionService) Refresh(c context.Context, refreshToken string) (*dtos.AuthOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Refresh", c, refreshToken)
+	ret0, _ := ret[0].(*dtos.AuthOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Refresh indicates an expected call of Refresh.
+func (mr *MockSessionServiceMockRecorder) Refresh(c, refreshToken any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Refresh", reflect.TypeOf((*MockSessionService)(nil).Refresh), c, refreshToken)
+}
+
+// Remove mocks base method.
+func (m *MockSessionService) Remove(c context.Context, accessToken string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Remove", c, accessToken)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Remove indicates an expected call of Remove.
+func (mr *MockSessionServiceMockRecorder) Remove(c, accessToken any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockSessionService)(nil).Remove), c, accessToken)
+}
+
+// RemoveAll mocks base method.
+func (m *MockSessionService) RemoveAll(c context.Context, userId int, exceptAccessToken string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveAll", c, userId, exceptAccessToken)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveAll indicates an expected call of RemoveAll.
+func (mr *MockSessionServiceMockRecorder) RemoveAll(c, userId, exceptAccessToken any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveAll", reflect.TypeOf((*MockSessionService)(nil).RemoveAll), c, userId, exceptAccessToken)
+}
+
+// RemoveOne mocks base method.
+func (m *MockSessionService) RemoveOne(c context.Context, userId, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveOne", c, userId, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveOne indicates an expected call of RemoveOne.
+func (mr *MockSessionServiceMockRecorder) RemoveOne(c, userId, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveOne", reflect.TypeOf((*MockSessionService)(nil).RemoveOne), c, userId, id)
+}
+
+// Validate mocks base method.
+func (m *MockSessionService) Validate(c context.Context, accessToken string) (*domain.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Validate", c, accessToken)
+	ret0, _ := ret[0].(*domain.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Validate indicates an expected call of Validate.
+func (mr *MockSessionServiceMockRecorder) Validate(c, accessToken any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Validate", reflect.TypeOf((*MockSessionService)(nil).Validate), c, accessToken)
+}
+
+// ValidateWatchToken mocks base method.
+func (m *MockSessionService) ValidateWatchToken(token string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ValidateWatchToken", token)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ValidateWatchToken indicates an expected call of ValidateWatchToken.
+func (mr *MockSessionServiceMockRecorder) ValidateWatchToken(token any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ValidateWatchToken", reflect.TypeOf((*MockSessionService)(nil).ValidateWatchToken), token)
+}
+
+// WatchRevocations mocks base method.
+func (m *MockSessionService) WatchRevocations(c context.Context, cursor string, send func(*domain.Revocation) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WatchRevocations", c, cursor, send)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WatchRevocations indicates an expected call of WatchRevocations.
+func (mr *MockSessionServiceMockRecorder) WatchRevocations(c, cursor, send any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockSessionService)(nil).Create), c, userId)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WatchRevocations", reflect.TypeOf((*MockSessionService)(nil).WatchRevocations), c, cursor, send)
 }
@@ -0,0 +1,23 @@
+package domain
+
+// Channel is how a verification code is delivered to the phone
+type Channel string
+
+const (
+	ChannelSMS      Channel = "sms"
+	ChannelTelegram Channel = "telegram"
+	ChannelWhatsApp Channel = "whatsapp"
+	ChannelCall     Channel = "call" // flash call, the code is the end of the caller number
+)
+
+// ParseChannel accepts a channel name, empty name means sms
+func ParseChannel(name string) (Channel, error) {
+	switch channel := Channel(name); channel {
+	case "":
+		return ChannelSMS, nil
+	case ChannelSMS, ChannelTelegram, ChannelWhatsApp, ChannelCall:
+		return channel, nil
+	default:
+		return "", ErrInvalidChannel
+	}
+}
@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChannel(t *testing.T) {
+	tests := []struct {
+		name    string
+		channel Channel
+		err     error
+	}{
+		{"", ChannelSMS, nil},
+		{"sms", ChannelSMS, nil},
+		{"telegram", ChannelTelegram, nil},
+		{"whatsapp", ChannelWhatsApp, nil},
+		{"call", ChannelCall, nil},
+		{"pigeon", "", ErrInvalidChannel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel, err := ParseChannel(tt.name)
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.channel, channel)
+		})
+	}
+}
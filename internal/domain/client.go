@@ -0,0 +1,16 @@
+package domain
+
+import "context"
+
+type clientIPKey struct{}
+
+// WithClientIP returns a context carrying the address of the client the request came from
+func WithClientIP(c context.Context, ip string) context.Context {
+	return context.WithValue(c, clientIPKey{}, ip)
+}
+
+// ClientIP is the address of the client resolved by the transport, empty if unknown
+func ClientIP(c context.Context) string {
+	ip, _ := c.Value(clientIPKey{}).(string)
+	return ip
+}
@@ -0,0 +1,13 @@
+package domain
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	assert.Empty(t, ClientIP(context.Background()))
+	assert.Equal(t, "10.1.2.3", ClientIP(WithClientIP(context.Background(), "10.1.2.3")))
+}
@@ -1,43 +1,125 @@
 package domain
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"math/big"
 	"time"
 )
 
+// CodePurpose is the flow a code is issued for, it's accepted only by that flow
+type CodePurpose string
+
+const (
+	PurposeRegister      CodePurpose = "register"
+	PurposeLogin         CodePurpose = "login"
+	PurposePasswordReset CodePurpose = "password_reset"
+	PurposePhoneChange   CodePurpose = "phone_change"
+)
+
 type Code struct {
-	Code      string
+	ID        int
+	Code      string // plain code, it's set only on a new code and never stored
+	Hash      string // keyed hash of the code with Salt
+	Salt      string
 	Phone     string
 	IP        string
+	Purpose   CodePurpose
+	Channel   Channel // channel requested for the code, it may be sent by sms as a fallback
 	CreatedAt time.Time
+	ExpiresAt time.Time
+	Attempts  int // verification attempts made with the code
 }
 
-func randomCode(length int) (string, error) {
-	var output string
-	str := "1234567890"
+// CodePolicy is how verification codes are generated
+type CodePolicy struct {
+	Length   int
+	Alphabet string
+	TTL      time.Duration
+}
+
+// Validate rejects policies giving guessable or biased codes
+func (p CodePolicy) Validate() error {
+	if p.Length < 4 {
+		return fmt.Errorf("code length must be at least 4, got %d", p.Length)
+	}
+
+	seen := map[rune]bool{}
+	for _, r := range p.Alphabet {
+		if seen[r] {
+			// a repeated symbol would be drawn more often than the others
+			return fmt.Errorf("code alphabet has repeated symbol %q", r)
+		}
+		seen[r] = true
+	}
+	if len(seen) < 2 {
+		return errors.New("code alphabet must have at least 2 symbols")
+	}
+
+	if p.TTL <= 0 {
+		return errors.New("code ttl must be positive")
+	}
 
-	for i := 0; i < length; i++ {
-		i, err := rand.Int(rand.Reader, big.NewInt(int64(len(str)-1)))
+	return nil
+}
+
+// randomCode draws every symbol uniformly from the alphabet
+func randomCode(length int, alphabet string) (string, error) {
+	symbols := []rune(alphabet)
+	max := big.NewInt(int64(len(symbols)))
+
+	output := make([]rune, length)
+	for i := range output {
+		n, err := rand.Int(rand.Reader, max)
 		if err != nil {
 			return "", err
 		}
-		output = output + string(str[i.Int64()])
+		output[i] = symbols[n.Int64()]
 	}
 
-	return output, nil
+	return string(output), nil
+}
+
+// NewSalt returns a random salt for HashCode
+func NewSalt() (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(salt), nil
 }
 
-func NewCode(phone, ip string) (*Code, error) {
-	code, err := randomCode(4)
+// HashCode is the keyed digest of a code with a salt, only digests are stored,
+// so pending codes can't be used by someone who can read the database
+func HashCode(secret []byte, salt, code string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(salt))
+	mac.Write([]byte(code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func NewCode(phone, ip string, purpose CodePurpose, policy CodePolicy) (*Code, error) {
+	code, err := randomCode(policy.Length, policy.Alphabet)
 	if err != nil {
 		return nil, err
 	}
 
+	now := time.Now().UTC()
+
 	return &Code{
 		Code:      code,
 		Phone:     phone,
 		IP:        ip,
-		CreatedAt: time.Now().UTC(),
+		Purpose:   purpose,
+		CreatedAt: now,
+		ExpiresAt: now.Add(policy.TTL),
 	}, nil
 }
+
+func (c *Code) IsExpired(now time.Time) bool {
+	return !now.Before(c.ExpiresAt)
+}
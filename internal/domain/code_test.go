@@ -1,15 +1,135 @@
 package domain
 
 import (
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
+var digits = CodePolicy{Length: 4, Alphabet: "0123456789", TTL: time.Minute * 5}
+
 func TestCode(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
-		code, err := NewCode("7778881133", "127.0.0.1")
+		code, err := NewCode("7778881133", "127.0.0.1", PurposeRegister, digits)
 		assert.NoError(t, err)
 		assert.NotZero(t, code)
+		assert.Len(t, code.Code, 4)
+		assert.Equal(t, time.Minute*5, code.ExpiresAt.Sub(code.CreatedAt))
+	})
+
+	t.Run("policy", func(t *testing.T) {
+		policy := CodePolicy{Length: 8, Alphabet: "ABCDEFGHJKMNPQRSTUVWXYZ23456789", TTL: time.Minute}
+
+		code, err := NewCode("7778881133", "127.0.0.1", PurposeRegister, policy)
+		assert.NoError(t, err)
+		assert.Len(t, code.Code, 8)
+		for _, r := range code.Code {
+			assert.True(t, strings.ContainsRune(policy.Alphabet, r))
+		}
+	})
+}
+
+func TestCodeIsExpired(t *testing.T) {
+	now := time.Now().UTC()
+	code := &Code{CreatedAt: now, ExpiresAt: now.Add(time.Minute * 5)}
+
+	assert.False(t, code.IsExpired(now))
+	assert.False(t, code.IsExpired(now.Add(time.Minute*4)))
+	assert.True(t, code.IsExpired(now.Add(time.Minute*5)))
+}
+
+func TestCodePolicyValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy CodePolicy
+		valid  bool
+	}{
+		{"digits", digits, true},
+		{"unicode alphabet", CodePolicy{Length: 4, Alphabet: "абвгд", TTL: time.Minute}, true},
+		{"too short", CodePolicy{Length: 3, Alphabet: "0123456789", TTL: time.Minute}, false},
+		{"single symbol", CodePolicy{Length: 4, Alphabet: "0", TTL: time.Minute}, false},
+		{"repeated symbol", CodePolicy{Length: 4, Alphabet: "01234567890", TTL: time.Minute}, false},
+		{"no ttl", CodePolicy{Length: 4, Alphabet: "0123456789"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate()
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+// chiSquare of observed symbol counts against the uniform distribution
+func chiSquare(counts map[rune]int, symbols, total int) float64 {
+	expected := float64(total) / float64(symbols)
+
+	var sum float64
+	for _, observed := range counts {
+		d := float64(observed) - expected
+		sum += d * d / expected
+	}
+	// symbols never drawn
+	sum += float64(symbols-len(counts)) * expected
+
+	return sum
+}
+
+func TestRandomCodeDistribution(t *testing.T) {
+	// critical values of the chi-square distribution at p = 0.0001,
+	// a uniform generator fails a test once in 10000 runs
+	tests := []struct {
+		alphabet string
+		critical float64
+	}{
+		{"0123456789", 33.72},                      // 9 degrees of freedom
+		{"ABCDEFGHJKMNPQRSTUVWXYZ23456789", 65.25}, // 30 degrees of freedom
+		{"01", 15.14},                              // 1 degree of freedom
+	}
+
+	const codes = 20000
+	const length = 6
+
+	for _, tt := range tests {
+		t.Run(tt.alphabet, func(t *testing.T) {
+			symbols := len([]rune(tt.alphabet))
+
+			// every position is drawn independently, so each of them must be uniform
+			positions := make([]map[rune]int, length)
+			for i := range positions {
+				positions[i] = map[rune]int{}
+			}
+
+			for range codes {
+				code, err := randomCode(length, tt.alphabet)
+				if !assert.NoError(t, err) {
+					return
+				}
+				for i, r := range []rune(code) {
+					positions[i][r]++
+				}
+			}
+
+			for i, counts := range positions {
+				assert.Len(t, counts, symbols, "position %d misses symbols", i)
+				assert.Less(t, chiSquare(counts, symbols, codes), tt.critical, "position %d is not uniform", i)
+			}
+		})
+	}
+
+	t.Run("biased generator is detected", func(t *testing.T) {
+		// the old generator never drew the last symbol of the alphabet
+		counts := map[rune]int{}
+		for i := range codes {
+			counts[rune('1'+i%9)]++
+		}
+
+		assert.Greater(t, chiSquare(counts, 10, codes), 33.72)
 	})
 }
@@ -0,0 +1,30 @@
+package domain
+
+import "strings"
+
+// Device describes the client a session was created from
+type Device struct {
+	IP        string
+	UserAgent string
+	Platform  string // app and platform, e.g. "android 1.4.0"
+	Name      string // human-readable device name, e.g. "Pixel 8"
+}
+
+func NewDevice(ip, userAgent, platform, name string) Device {
+	return Device{
+		IP:        truncate(ip, 45),
+		UserAgent: truncate(userAgent, 256),
+		Platform:  truncate(platform, 64),
+		Name:      truncate(name, 128),
+	}
+}
+
+func truncate(value string, length int) string {
+	value = strings.TrimSpace(value)
+
+	runes := []rune(value)
+	if len(runes) > length {
+		return string(runes[:length])
+	}
+	return value
+}
@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDevice(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		device := NewDevice("127.0.0.1", " Mozilla/5.0 ", "android 1.4.0", "Pixel 8")
+
+		assert.Equal(t, "127.0.0.1", device.IP)
+		assert.Equal(t, "Mozilla/5.0", device.UserAgent)
+		assert.Equal(t, "android 1.4.0", device.Platform)
+		assert.Equal(t, "Pixel 8", device.Name)
+	})
+
+	t.Run("too long", func(t *testing.T) {
+		device := NewDevice("127.0.0.1", strings.Repeat("a", 300), "", strings.Repeat("ы", 200))
+
+		assert.Len(t, device.UserAgent, 256)
+		assert.Len(t, []rune(device.Name), 128)
+	})
+}
@@ -0,0 +1,58 @@
+package domain
+
+import (
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// EmailCode proves that the user owns the email, the email is set to the user
+// only after the code is entered
+type EmailCode struct {
+	ID        int
+	UserID    int
+	Email     string
+	Code      string // plain code, it's set only on a new code and never stored
+	Hash      string // keyed hash of the code with Salt
+	Salt      string
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	Attempts  int // verification attempts made with the code
+}
+
+func NewEmailCode(userId int, email string, policy CodePolicy) (*EmailCode, error) {
+	code, err := randomCode(policy.Length, policy.Alphabet)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	return &EmailCode{
+		UserID:    userId,
+		Email:     email,
+		Code:      code,
+		CreatedAt: now,
+		ExpiresAt: now.Add(policy.TTL),
+	}, nil
+}
+
+func (c *EmailCode) IsExpired(now time.Time) bool {
+	return !now.Before(c.ExpiresAt)
+}
+
+// ParseEmail accepts a bare address like "john@example.com" and returns it in lower case,
+// so the same mailbox can't be added twice with different case
+func ParseEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if len(email) > 254 {
+		return "", ErrInvalidEmail
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || address.Name != "" {
+		return "", ErrInvalidEmail
+	}
+
+	return strings.ToLower(email), nil
+}
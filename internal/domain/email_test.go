@@ -0,0 +1,43 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEmail(t *testing.T) {
+	testCases := []struct {
+		email   string
+		want    string
+		wantErr error
+	}{
+		{email: "john@example.com", want: "john@example.com"},
+		{email: " John.Doe@Example.COM ", want: "john.doe@example.com"},
+		{email: "", wantErr: ErrInvalidEmail},
+		{email: "john", wantErr: ErrInvalidEmail},
+		{email: "john@", wantErr: ErrInvalidEmail},
+		{email: "John <john@example.com>", wantErr: ErrInvalidEmail},
+		{email: strings.Repeat("a", 250) + "@x.kz", wantErr: ErrInvalidEmail},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.email, func(t *testing.T) {
+			email, err := ParseEmail(tc.email)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.want, email)
+		})
+	}
+}
+
+func TestNewEmailCode(t *testing.T) {
+	code, err := NewEmailCode(1, "john@example.com", CodePolicy{Length: 6, Alphabet: "0123456789", TTL: time.Minute})
+	if assert.NoError(t, err) {
+		assert.Len(t, code.Code, 6)
+		assert.Equal(t, 1, code.UserID)
+		assert.False(t, code.IsExpired(time.Now()))
+		assert.True(t, code.IsExpired(time.Now().Add(time.Minute)))
+	}
+}
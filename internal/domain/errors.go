@@ -11,4 +11,25 @@ var (
 	ErrUsernameAlreadyInUse = errors.New("USERNAME_ALREADY_IN_USE")
 	ErrCodeSendingLimit     = errors.New("CODE_SENDING_LIMIT")
 	ErrInvalidCredentials   = errors.New("INVALID_CREDENTIALS")
+	ErrInvalidAccessToken   = errors.New("INVALID_ACCESS_TOKEN")
+	ErrSessionExpired       = errors.New("SESSION_EXPIRED")
+	ErrAccessTokenExpired   = errors.New("ACCESS_TOKEN_EXPIRED")
+	ErrInvalidRefreshToken  = errors.New("INVALID_REFRESH_TOKEN")
+	ErrRefreshTokenReused   = errors.New("REFRESH_TOKEN_REUSED")
+	ErrSessionNotFound      = errors.New("SESSION_NOT_FOUND")
+	ErrTooManySessions      = errors.New("TOO_MANY_SESSIONS")
+	ErrImpersonationDenied  = errors.New("IMPERSONATION_DENIED")
+	ErrPermissionDenied     = errors.New("PERMISSION_DENIED")
+	ErrReasonRequired       = errors.New("REASON_REQUIRED")
+	ErrImpersonatedSession  = errors.New("IMPERSONATED_SESSION")
+	ErrInvalidCursor        = errors.New("INVALID_CURSOR")
+	ErrInvalidServiceToken  = errors.New("INVALID_SERVICE_TOKEN")
+	ErrInvalidCode          = errors.New("INVALID_CODE")
+	ErrCodeExpired          = errors.New("CODE_EXPIRED")
+	ErrTooManyAttempts      = errors.New("TOO_MANY_ATTEMPTS")
+	ErrSMSNotFound          = errors.New("SMS_NOT_FOUND")
+	ErrInvalidChannel       = errors.New("INVALID_CHANNEL")
+	ErrInvalidEmail         = errors.New("INVALID_EMAIL")
+	ErrEmailAlreadyInUse    = errors.New("EMAIL_ALREADY_IN_USE")
+	ErrEmailDisabled        = errors.New("EMAIL_DISABLED")
 )
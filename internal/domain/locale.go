@@ -0,0 +1,26 @@
+package domain
+
+import "strings"
+
+type Locale string
+
+const (
+	LocaleKazakh  Locale = "kk"
+	LocaleRussian Locale = "ru"
+	LocaleEnglish Locale = "en"
+
+	DefaultLocale = LocaleKazakh
+)
+
+// ParseLocale takes the language of a tag like "ru" or "ru-KZ",
+// unsupported and empty tags fall back to DefaultLocale
+func ParseLocale(tag string) Locale {
+	lang, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+
+	switch locale := Locale(strings.ToLower(strings.TrimSpace(lang))); locale {
+	case LocaleKazakh, LocaleRussian, LocaleEnglish:
+		return locale
+	default:
+		return DefaultLocale
+	}
+}
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLocale(t *testing.T) {
+	tests := []struct {
+		tag    string
+		locale Locale
+	}{
+		{"kk", LocaleKazakh},
+		{"ru", LocaleRussian},
+		{"en", LocaleEnglish},
+		{"ru-KZ", LocaleRussian},
+		{"EN_us", LocaleEnglish},
+		{"", DefaultLocale},
+		{"de", DefaultLocale},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			assert.Equal(t, tt.locale, ParseLocale(tt.tag))
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+type RevocationReason string
+
+const (
+	RevokedByLogout     RevocationReason = "logout"
+	RevokedByLogoutAll  RevocationReason = "logout_all"
+	RevokedByUser       RevocationReason = "revoked"
+	RevokedByExpiry     RevocationReason = "expired"
+	RevokedByTokenReuse RevocationReason = "refresh_token_reused"
+	RevokedByLimit      RevocationReason = "evicted"
+	RevokedByRotation   RevocationReason = "rotated" // the session is alive, only its previous access token is revoked
+)
+
+// Revocation is an entry of the feed of revoked sessions
+type Revocation struct {
+	Cursor    RevocationCursor
+	SessionID int
+	UserID    int
+	Reason    RevocationReason
+	CreatedAt time.Time
+}
+
+// RevocationCursor is a position in the revocation feed. Entries are ordered by
+// the id of the transaction that wrote them, so an entry committed late
+// is never skipped by a reader that already moved past its id.
+type RevocationCursor struct {
+	TxID int64
+	ID   int64
+}
+
+func (c RevocationCursor) String() string {
+	return fmt.Sprintf("%d.%d", c.TxID, c.ID)
+}
+
+func ParseRevocationCursor(s string) (RevocationCursor, error) {
+	var cursor RevocationCursor
+	if _, err := fmt.Sscanf(s, "%d.%d", &cursor.TxID, &cursor.ID); err != nil || cursor.String() != s {
+		return RevocationCursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRevocationCursor(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		cursor := RevocationCursor{TxID: 1052, ID: 7}
+
+		parsed, err := ParseRevocationCursor(cursor.String())
+
+		assert.NoError(t, err)
+		assert.Equal(t, cursor, parsed)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, s := range []string{"", "1052", "1052.", "a.b", "1052.7.1", " 1052.7"} {
+			_, err := ParseRevocationCursor(s)
+			assert.ErrorIs(t, err, ErrInvalidCursor, s)
+		}
+	})
+}
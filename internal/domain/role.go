@@ -1,5 +1,7 @@
 package domain
 
+import "slices"
+
 type RoleID int
 
 const (
@@ -10,8 +12,15 @@ const (
 	OwnerRole
 )
 
+// PermissionViewCodeDelivery lets support staff see delivery of codes sent to any phone
+const PermissionViewCodeDelivery = "code_delivery.view"
+
 type Role struct {
 	ID          RoleID
 	Name        string
 	Permissions []string
 }
+
+func (r *Role) HasPermission(permission string) bool {
+	return slices.Contains(r.Permissions, permission)
+}
@@ -0,0 +1,53 @@
+package domain
+
+import "time"
+
+type Session struct {
+	ID              int
+	AccessTokenHash string
+	AccessExpiresAt time.Time
+	UserID          int
+	Device          Device
+	CreatedAt       time.Time
+	LastSeenAt      time.Time
+
+	// Username and Role are the current ones of the user, they are cached with the session,
+	// so validating a session doesn't need to look the user up. Username is empty if the user is gone.
+	Username string
+	Role     Role
+
+	// ImpersonatorID is the staff member who acts as the user in this session, 0 if none
+	ImpersonatorID      int
+	ImpersonationReason string
+}
+
+func (s *Session) IsImpersonated() bool {
+	return s.ImpersonatorID != 0
+}
+
+// IsExpired reports whether the session outlived absoluteTTL since it was created
+// or was not used for longer than idleTTL
+func (s *Session) IsExpired(now time.Time, absoluteTTL, idleTTL time.Duration) bool {
+	if now.Sub(s.CreatedAt) > absoluteTTL {
+		return true
+	}
+	return now.Sub(s.LastSeenAt) > idleTTL
+}
+
+// IsAccessExpired reports whether the access token of the session has to be refreshed
+func (s *Session) IsAccessExpired(now time.Time) bool {
+	return now.After(s.AccessExpiresAt)
+}
+
+// RefreshToken belongs to a session, every session is a single token family:
+// each refresh rotates the token, and presenting a used token again revokes the whole session
+type RefreshToken struct {
+	TokenHash string
+	SessionID int
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (t *RefreshToken) IsUsed() bool {
+	return t.UsedAt != nil
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionIsExpired(t *testing.T) {
+	now := time.Now().UTC()
+
+	type testCase struct {
+		name       string
+		createdAt  time.Time
+		lastSeenAt time.Time
+		want       bool
+	}
+
+	testCases := []testCase{
+		{
+			name:       "active",
+			createdAt:  now.Add(-time.Hour * 24),
+			lastSeenAt: now.Add(-time.Hour),
+			want:       false,
+		},
+		{
+			name:       "absolute lifetime exceeded",
+			createdAt:  now.Add(-time.Hour * 24 * 31),
+			lastSeenAt: now.Add(-time.Minute),
+			want:       true,
+		},
+		{
+			name:       "idle lifetime exceeded",
+			createdAt:  now.Add(-time.Hour * 24 * 10),
+			lastSeenAt: now.Add(-time.Hour * 24 * 8),
+			want:       true,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			session := &Session{CreatedAt: tt.createdAt, LastSeenAt: tt.lastSeenAt}
+			assert.Equal(t, tt.want, session.IsExpired(now, time.Hour*24*30, time.Hour*24*7))
+		})
+	}
+}
+
+func TestSessionIsAccessExpired(t *testing.T) {
+	now := time.Now().UTC()
+
+	t.Run("active", func(t *testing.T) {
+		session := &Session{AccessExpiresAt: now.Add(time.Minute)}
+		assert.False(t, session.IsAccessExpired(now))
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		session := &Session{AccessExpiresAt: now.Add(-time.Minute)}
+		assert.True(t, session.IsAccessExpired(now))
+	})
+}
+
+func TestIsImpersonated(t *testing.T) {
+	assert.False(t, (&Session{UserID: 1}).IsImpersonated())
+	assert.True(t, (&Session{UserID: 1, ImpersonatorID: 2}).IsImpersonated())
+}
@@ -0,0 +1,55 @@
+package domain
+
+import "time"
+
+type SMSStatus string
+
+const (
+	SMSQueued    SMSStatus = "queued"
+	SMSSent      SMSStatus = "sent"      // accepted by a provider
+	SMSDelivered SMSStatus = "delivered" // the provider reported delivery to the phone
+	SMSFailed    SMSStatus = "failed"    // the provider reported the message undeliverable
+	SMSDead      SMSStatus = "dead"      // delivery was given up, the message is kept for investigation
+)
+
+// SMSReport is a delivery report posted by a provider
+type SMSReport struct {
+	MessageID string
+	Status    SMSStatus
+}
+
+// SMS is a message of the outbox, it's delivered by a background worker
+// through its channel, or by sms if the channel fails
+type SMS struct {
+	ID        int
+	Recipient string
+	Text      string  // sms text, it carries the code and is stored encrypted
+	Code      string  // verification code for channels which format messages themselves, it is stored encrypted
+	Channel   Channel // requested channel until the message is sent, then the channel which delivered it
+	Locale    Locale
+	Status    SMSStatus
+	Attempts  int // failed delivery attempts
+	CreatedAt time.Time
+	ExpiresAt time.Time // the message is useless after that, e.g. its code has expired
+
+	// Provider and ProviderMessageID identify the message at the provider which accepted it
+	Provider          string
+	ProviderMessageID string
+	StatusAt          time.Time // when the status last changed
+}
+
+func NewSMS(recipient, text string, expiresAt time.Time) *SMS {
+	return &SMS{
+		Recipient: recipient,
+		Text:      text,
+		Channel:   ChannelSMS,
+		Locale:    DefaultLocale,
+		Status:    SMSQueued,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+}
+
+func (s *SMS) IsExpired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"crypto/ed25519"
+	"time"
+)
+
+// TokenClaims are carried by signed access tokens,
+// so other services can authorize requests without calling us
+type TokenClaims struct {
+	SessionID int
+	UserID    int
+	RoleID    RoleID
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+
+	// ImpersonatorID is the staff member acting as the user, 0 if none
+	ImpersonatorID int
+}
+
+// PublicKey verifies signatures of access tokens issued with the key of the same id
+type PublicKey struct {
+	ID  string
+	Key ed25519.PublicKey
+}
@@ -11,10 +11,12 @@ type User struct {
 	ID          int
 	Username    string
 	Phone       string
+	Email       *string // verified email, the user can log in with it instead of the phone
 	Password    string
 	Photo       *string
 	Description *string
 	Role        *Role
+	Locale      Locale // language of messages sent to the user
 	CreatedAt   time.Time
 }
 
@@ -41,9 +43,31 @@ func NewUser(username, phone, password string) (*User, error) {
 		Username: username,
 		Phone:    phone,
 		Password: string(hashedPassword),
+		Locale:   DefaultLocale,
 	}, nil
 }
 
 func (u *User) ComparePassword(password string) error {
 	return bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
 }
+
+// CanViewCodeDelivery reports whether the user may see delivery of codes sent to the phone,
+// users see their own phone and support staff any phone
+func (u *User) CanViewCodeDelivery(phone string) bool {
+	if u.Phone == phone {
+		return true
+	}
+	return u.Role != nil && u.Role.HasPermission(PermissionViewCodeDelivery)
+}
+
+// CanImpersonate reports whether the user is a staff member allowed to act as target,
+// staff can only impersonate users with a lower role
+func (u *User) CanImpersonate(target *User) bool {
+	if u.Role == nil || target.Role == nil || u.ID == target.ID {
+		return false
+	}
+	if u.Role.ID < ModeratorRole {
+		return false
+	}
+	return target.Role.ID < u.Role.ID
+}
@@ -52,3 +52,55 @@ func TestUser(t *testing.T) {
 		})
 	}
 }
+
+func TestCanImpersonate(t *testing.T) {
+	user := func(id int, role RoleID) *User {
+		return &User{ID: id, Role: &Role{ID: role}}
+	}
+
+	type testCase struct {
+		name   string
+		staff  *User
+		target *User
+		want   bool
+	}
+
+	testCases := []testCase{
+		{name: "moderator", staff: user(1, ModeratorRole), target: user(2, UserRole), want: true},
+		{name: "admin", staff: user(1, AdminRole), target: user(2, ModeratorRole), want: true},
+		{name: "author", staff: user(1, AuthorRole), target: user(2, UserRole), want: false},
+		{name: "same role", staff: user(1, ModeratorRole), target: user(2, ModeratorRole), want: false},
+		{name: "higher role", staff: user(1, ModeratorRole), target: user(2, AdminRole), want: false},
+		{name: "self", staff: user(1, AdminRole), target: user(1, AdminRole), want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.staff.CanImpersonate(tc.target))
+		})
+	}
+}
+
+func TestCanViewCodeDelivery(t *testing.T) {
+	support := &Role{ID: ModeratorRole, Permissions: []string{PermissionViewCodeDelivery}}
+
+	type testCase struct {
+		name  string
+		user  *User
+		phone string
+		want  bool
+	}
+
+	testCases := []testCase{
+		{name: "own phone", user: &User{Phone: "7778889966", Role: &Role{ID: UserRole}}, phone: "7778889966", want: true},
+		{name: "other phone", user: &User{Phone: "7778889966", Role: &Role{ID: UserRole}}, phone: "7778889955", want: false},
+		{name: "support", user: &User{Phone: "7778889966", Role: support}, phone: "7778889955", want: true},
+		{name: "no role", user: &User{Phone: "7778889966"}, phone: "7778889955", want: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.user.CanViewCodeDelivery(tc.phone))
+		})
+	}
+}
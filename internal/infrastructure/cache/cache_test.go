@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		cache := New[string, int](2, time.Minute)
+		cache.Set("one", 1)
+
+		value, ok := cache.Get("one")
+		assert.True(t, ok)
+		assert.Equal(t, 1, value)
+
+		_, ok = cache.Get("two")
+		assert.False(t, ok)
+
+		stats := cache.Stats()
+		assert.Equal(t, uint64(1), stats.Hits)
+		assert.Equal(t, uint64(1), stats.Misses)
+		assert.Equal(t, 1, stats.Entries)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		cache := New[string, int](2, time.Millisecond*10)
+		cache.Set("one", 1)
+
+		time.Sleep(time.Millisecond * 20)
+
+		_, ok := cache.Get("one")
+		assert.False(t, ok)
+		assert.Equal(t, 0, cache.Stats().Entries)
+	})
+}
+
+func TestSet(t *testing.T) {
+	t.Run("evicts least recently used", func(t *testing.T) {
+		cache := New[string, int](2, time.Minute)
+		cache.Set("one", 1)
+		cache.Set("two", 2)
+		cache.Get("one")
+		cache.Set("three", 3)
+
+		_, ok := cache.Get("two")
+		assert.False(t, ok)
+
+		_, ok = cache.Get("one")
+		assert.True(t, ok)
+		_, ok = cache.Get("three")
+		assert.True(t, ok)
+
+		stats := cache.Stats()
+		assert.Equal(t, uint64(1), stats.Evictions)
+		assert.Equal(t, 2, stats.Entries)
+	})
+
+	t.Run("stale generation", func(t *testing.T) {
+		cache := New[string, int](2, time.Minute)
+
+		generation := cache.Generation()
+		cache.Delete("one")
+		cache.SetIfCurrent("one", 1, generation)
+
+		_, ok := cache.Get("one")
+		assert.False(t, ok)
+
+		cache.SetIfCurrent("one", 1, cache.Generation())
+
+		_, ok = cache.Get("one")
+		assert.True(t, ok)
+	})
+}
+
+func TestPurge(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		cache := New[string, int](2, time.Minute)
+		cache.Set("one", 1)
+		cache.Set("two", 2)
+
+		cache.Purge()
+
+		_, ok := cache.Get("one")
+		assert.False(t, ok)
+		assert.Equal(t, 0, cache.Stats().Entries)
+	})
+}
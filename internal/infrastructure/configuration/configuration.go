@@ -1,16 +1,293 @@
 package configuration
 
 import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/Netflix/go-env"
 )
 
 type ServerConfig struct {
 	GrpcSocker string `env:"GRPC_SOCKET"`
+	// TrustedProxies are CIDRs or addresses of proxies in front of the server separated by "|",
+	// the client address is taken from x-forwarded-for or x-real-ip only on requests from them
+	TrustedProxies Prefixes `env:"GRPC_TRUSTED_PROXIES"`
+}
+
+// Prefixes are network prefixes, a bare address is a prefix of itself
+type Prefixes []netip.Prefix
+
+func (p *Prefixes) UnmarshalEnvironmentValue(data string) error {
+	prefixes := Prefixes{}
+	for _, value := range strings.Split(data, "|") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		if !strings.Contains(value, "/") {
+			addr, err := netip.ParseAddr(value)
+			if err != nil {
+				return fmt.Errorf("invalid address %q: %w", value, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return fmt.Errorf("invalid cidr %q: %w", value, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	*p = prefixes
+	return nil
 }
 
 type SMSConfig struct {
+	// Senders are providers tried in order until one accepts a message,
+	// separated by "|": "mobizon", "smsc" or "console" for local development
+	Senders []string `env:"SMS_SENDERS,default=mobizon"`
+	// mobizon.kz
 	ApiKey    string `env:"SMS_API_KEY"`
 	ApiDomain string `env:"SMS_API_DOMAIN"`
+	// smsc.kz
+	SMSCDomain   string `env:"SMS_SMSC_DOMAIN,default=https://smsc.kz"`
+	SMSCLogin    string `env:"SMS_SMSC_LOGIN"`
+	SMSCPassword string `env:"SMS_SMSC_PASSWORD"`
+	// ConsoleFile is where the console sender writes messages, stdout if empty
+	ConsoleFile string `env:"SMS_CONSOLE_FILE"`
+	// Channels are enabled alternatives to sms separated by "|": "telegram", "whatsapp"
+	// or "call" for flash calls through smsc.kz, codes requested for other channels are sent by sms
+	Channels []string `env:"SMS_CHANNELS"`
+	// ChannelMaxCodes limits codes sent to a phone through a channel within PhoneWindow,
+	// in form "channel:limit" separated by "|"
+	ChannelMaxCodes ChannelLimits `env:"SMS_CHANNEL_MAX_CODES,default=call:2|telegram:5|whatsapp:5"`
+	// Telegram Gateway
+	TelegramDomain string `env:"SMS_TELEGRAM_DOMAIN,default=https://gatewayapi.telegram.org"`
+	TelegramToken  string `env:"SMS_TELEGRAM_TOKEN"`
+	// WhatsApp Business, WhatsAppTemplate is an approved authentication template
+	// with the code as its only parameter and a copy code button
+	WhatsAppDomain   string `env:"SMS_WHATSAPP_DOMAIN,default=https://graph.facebook.com/v20.0"`
+	WhatsAppToken    string `env:"SMS_WHATSAPP_TOKEN"`
+	WhatsAppPhoneID  string `env:"SMS_WHATSAPP_PHONE_ID"`
+	WhatsAppTemplate string `env:"SMS_WHATSAPP_TEMPLATE,default=verification_code"`
+	// WhatsAppVerifyToken confirms the callback url when the webhook is subscribed,
+	// WhatsAppAppSecret checks signatures of delivery reports
+	WhatsAppVerifyToken string `env:"SMS_WHATSAPP_VERIFY_TOKEN"`
+	WhatsAppAppSecret   string `env:"SMS_WHATSAPP_APP_SECRET"`
+	// AppDomain is the sender name in messages, iOS autofills codes bound to it
+	AppDomain string `env:"SMS_APP_DOMAIN,default=mangahana.com"`
+	// AndroidAppHash is the 11 character hash of the Android app for SMS Retriever,
+	// it's not added to messages if empty
+	AndroidAppHash string `env:"SMS_ANDROID_APP_HASH"`
+	// CodeSecret is the key of HMAC-SHA256 digests under which codes are stored
+	CodeSecret string `env:"SMS_CODE_SECRET,required=true"`
+	// CodeLength and CodeAlphabet define generated verification codes,
+	// symbols of the alphabet must not repeat
+	CodeLength   int    `env:"SMS_CODE_LENGTH,default=4"`
+	CodeAlphabet string `env:"SMS_CODE_ALPHABET,default=0123456789"`
+	// CodeTTL is how long a sent verification code can be used
+	CodeTTL time.Duration `env:"SMS_CODE_TTL,default=5m"`
+	// CodeMaxAttempts is how many times a code can be checked before it's invalidated
+	CodeMaxAttempts int `env:"SMS_CODE_MAX_ATTEMPTS,default=5"`
+	// PhoneMaxFailures is how many wrong codes can be entered for a phone within
+	// PhoneLockWindow, after that the phone is locked until the failures fall out of the window
+	PhoneMaxFailures int           `env:"SMS_PHONE_MAX_FAILURES,default=10"`
+	PhoneLockWindow  time.Duration `env:"SMS_PHONE_LOCK_WINDOW,default=1h"`
+	// PhoneMaxCodes is how many codes can be sent to a phone within PhoneWindow
+	PhoneMaxCodes int           `env:"SMS_PHONE_MAX_CODES,default=5"`
+	PhoneWindow   time.Duration `env:"SMS_PHONE_WINDOW,default=30m"`
+	// PhoneCooldown is the pause after the first code sent to a phone within PhoneWindow,
+	// it doubles with every next code
+	PhoneCooldown time.Duration `env:"SMS_PHONE_COOLDOWN,default=1m"`
+	// OutboxInterval is how often the outbox is checked for messages to deliver
+	OutboxInterval time.Duration `env:"SMS_OUTBOX_INTERVAL,default=1s"`
+	// OutboxBatchSize is how many messages a worker takes from the outbox at once
+	OutboxBatchSize int `env:"SMS_OUTBOX_BATCH_SIZE,default=20"`
+	// OutboxLease is how long a taken message is hidden from other workers,
+	// it's also the timeout of a delivery attempt
+	OutboxLease time.Duration `env:"SMS_OUTBOX_LEASE,default=30s"`
+	// OutboxMaxAttempts is how many times a message is tried before it's marked dead
+	OutboxMaxAttempts int `env:"SMS_OUTBOX_MAX_ATTEMPTS,default=8"`
+	// OutboxBackoff is the pause after the first failed attempt, it doubles
+	// with every next one up to OutboxMaxBackoff
+	OutboxBackoff    time.Duration `env:"SMS_OUTBOX_BACKOFF,default=2s"`
+	OutboxMaxBackoff time.Duration `env:"SMS_OUTBOX_MAX_BACKOFF,default=1m"`
+	// StatusPollInterval is how often providers are asked about the delivery of a sent message
+	// which has no delivery report yet, they are asked for StatusPollWindow after sending
+	StatusPollInterval time.Duration `env:"SMS_STATUS_POLL_INTERVAL,default=1m"`
+	StatusPollWindow   time.Duration `env:"SMS_STATUS_POLL_WINDOW,default=1h"`
+	// CallbackAddr is the address of the http server receiving delivery reports at
+	// /sms/status/{provider}?token=CallbackToken, the server is disabled if empty
+	CallbackAddr  string `env:"SMS_CALLBACK_ADDR"`
+	CallbackToken string `env:"SMS_CALLBACK_TOKEN"`
+	// CodeRetention is how long codes, wrong code failures and sent messages are kept
+	// after they expired, it's never shorter than the windows of sending and verification limits
+	CodeRetention time.Duration `env:"SMS_CODE_RETENTION,default=24h"`
+	// PurgeInterval is how often stale codes are removed, PurgeBatchSize rows at a time
+	PurgeInterval  time.Duration `env:"SMS_PURGE_INTERVAL,default=1h"`
+	PurgeBatchSize int           `env:"SMS_PURGE_BATCH_SIZE,default=1000"`
+}
+
+type MailConfig struct {
+	// Mailer is "smtp" or "console" for local development, emails are disabled if empty
+	Mailer string `env:"MAIL_MAILER"`
+	// SMTP server, the connection is upgraded with STARTTLS
+	SMTPHost     string `env:"MAIL_SMTP_HOST"`
+	SMTPPort     int    `env:"MAIL_SMTP_PORT,default=587"`
+	SMTPUsername string `env:"MAIL_SMTP_USERNAME"`
+	SMTPPassword string `env:"MAIL_SMTP_PASSWORD"`
+	// SMTPInsecure allows servers without STARTTLS, e.g. a local relay, emails are sent to them in cleartext
+	SMTPInsecure bool `env:"MAIL_SMTP_INSECURE,default=false"`
+	// From is the sender address of emails
+	From string `env:"MAIL_FROM,default=no-reply@mangahana.com"`
+	// ConsoleFile is where the console mailer writes emails, stdout if empty
+	ConsoleFile string `env:"MAIL_CONSOLE_FILE"`
+	// Timeout limits sending of an email
+	Timeout time.Duration `env:"MAIL_TIMEOUT,default=10s"`
+	// CodeLength is the number of digits in email verification codes
+	CodeLength int `env:"MAIL_CODE_LENGTH,default=6"`
+	// CodeTTL is how long a sent email verification code can be used
+	CodeTTL time.Duration `env:"MAIL_CODE_TTL,default=30m"`
+	// CodeMaxAttempts is how many times a code can be checked before it's invalidated
+	CodeMaxAttempts int `env:"MAIL_CODE_MAX_ATTEMPTS,default=5"`
+	// MaxCodes is how many codes can be sent to a user within Window
+	MaxCodes int           `env:"MAIL_MAX_CODES,default=5"`
+	Window   time.Duration `env:"MAIL_WINDOW,default=1h"`
+	// Cooldown is the pause after the first code sent within Window, it doubles with every next code
+	Cooldown time.Duration `env:"MAIL_COOLDOWN,default=1m"`
+	// PurgeBatchSize is how many expired codes are removed at a time, they are purged with sms codes
+	PurgeBatchSize int `env:"MAIL_PURGE_BATCH_SIZE,default=1000"`
+}
+
+// session token formats
+const (
+	TokenOpaque = "opaque"
+	TokenJWT    = "jwt"
+)
+
+// session limit policies
+const (
+	LimitEvictOldest = "evict_oldest"
+	LimitRefuse      = "refuse"
+)
+
+type SessionConfig struct {
+	// TokenFormat is either "opaque" for random access tokens or "jwt" for signed ones
+	TokenFormat string `env:"SESSION_TOKEN_FORMAT,default=opaque"`
+	// TokenSecret is the key of HMAC-SHA256 digests under which tokens are stored
+	TokenSecret string `env:"SESSION_TOKEN_SECRET,required=true"`
+	// AccessTTL is the lifetime of an access token, after that it has to be refreshed
+	AccessTTL time.Duration `env:"SESSION_ACCESS_TTL,default=15m"`
+	// AbsoluteTTL is the maximum lifetime of a session since it was created
+	AbsoluteTTL time.Duration `env:"SESSION_ABSOLUTE_TTL,default=720h"`
+	// IdleTTL is the maximum time a session may stay unused
+	IdleTTL time.Duration `env:"SESSION_IDLE_TTL,default=168h"`
+	// TouchInterval limits how often last_seen_at of a session is updated
+	TouchInterval time.Duration `env:"SESSION_TOUCH_INTERVAL,default=5m"`
+	// PurgeInterval is how often expired sessions are removed from the database
+	PurgeInterval time.Duration `env:"SESSION_PURGE_INTERVAL,default=1h"`
+	// MaxPerUser is the maximum number of active sessions of a user, 0 means unlimited
+	MaxPerUser int `env:"SESSION_MAX_PER_USER,default=0"`
+	// MaxPerRole overrides MaxPerUser for roles, in form "role_id:limit" separated by "|"
+	MaxPerRole RoleLimits `env:"SESSION_MAX_PER_ROLE"`
+	// LimitPolicy is either "evict_oldest" to remove least recently used sessions
+	// when the limit is reached or "refuse" to reject the new login
+	LimitPolicy string `env:"SESSION_LIMIT_POLICY,default=evict_oldest"`
+	// ImpersonationTTL is the lifetime of a session started by staff to act as a user,
+	// such sessions can't be refreshed
+	ImpersonationTTL time.Duration `env:"SESSION_IMPERSONATION_TTL,default=30m"`
+	// RevocationPollInterval is how often WatchRevocations streams check for new revocations
+	RevocationPollInterval time.Duration `env:"SESSION_REVOCATION_POLL_INTERVAL,default=1s"`
+	// RevocationRetention is how long revocations are kept, a stream can be resumed
+	// from a cursor not older than that
+	RevocationRetention time.Duration `env:"SESSION_REVOCATION_RETENTION,default=24h"`
+	// WatchToken is the secret internal services pass to WatchRevocations,
+	// the stream is refused to everyone if empty
+	WatchToken string `env:"SESSION_WATCH_TOKEN"`
+	// CacheSize is the maximum number of sessions cached by access token, 0 disables the cache
+	CacheSize int `env:"SESSION_CACHE_SIZE,default=10000"`
+	// CacheTTL bounds how long a cached session may be stale if a revocation notification is lost
+	CacheTTL time.Duration `env:"SESSION_CACHE_TTL,default=30s"`
+	// CacheStatsInterval is how often cache hit/miss counters are logged
+	CacheStatsInterval time.Duration `env:"SESSION_CACHE_STATS_INTERVAL,default=5m"`
+}
+
+// RoleLimits maps role id to a limit
+type RoleLimits map[int]int
+
+func (l *RoleLimits) UnmarshalEnvironmentValue(data string) error {
+	limits := RoleLimits{}
+	err := parseLimits(data, func(key string, limit int) error {
+		id, err := strconv.Atoi(key)
+		if err != nil {
+			return fmt.Errorf("invalid role id %q: %w", key, err)
+		}
+		limits[id] = limit
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	*l = limits
+	return nil
+}
+
+// ChannelLimits maps channel name to a limit
+type ChannelLimits map[string]int
+
+func (l *ChannelLimits) UnmarshalEnvironmentValue(data string) error {
+	limits := ChannelLimits{}
+	err := parseLimits(data, func(key string, limit int) error {
+		limits[key] = limit
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	*l = limits
+	return nil
+}
+
+// parseLimits reads "key:limit" pairs separated by "|"
+func parseLimits(data string, set func(key string, limit int) error) error {
+	for _, pair := range strings.Split(data, "|") {
+		if pair == "" {
+			continue
+		}
+
+		key, limit, ok := strings.Cut(pair, ":")
+		if !ok {
+			return fmt.Errorf("invalid limit %q", pair)
+		}
+
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return fmt.Errorf("invalid limit in %q: %w", pair, err)
+		}
+
+		if err := set(key, n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type JWTConfig struct {
+	Issuer string `env:"JWT_ISSUER,default=account.mangahana.com"`
+	// SigningKeys are Ed25519 keys in form "key_id:base64_seed" separated by "|".
+	// The first key signs new tokens, the others are only published to verify
+	// tokens signed before rotation, so a new key is rolled out in three steps:
+	// append it, move it to the front once verifiers picked it up,
+	// remove the old one after SESSION_ACCESS_TTL has passed.
+	SigningKeys []string `env:"JWT_SIGNING_KEYS"`
 }
 
 type DBConfig struct {
@@ -21,9 +298,12 @@ type DBConfig struct {
 }
 
 type Config struct {
-	DB     DBConfig
-	Server ServerConfig
-	SMS    SMSConfig
+	DB      DBConfig
+	Server  ServerConfig
+	SMS     SMSConfig
+	Mail    MailConfig
+	Session SessionConfig
+	JWT     JWTConfig
 }
 
 func Load() (*Config, error) {
@@ -32,5 +312,59 @@ func Load() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
 	return &cfg, err
 }
+
+// validate rejects values that would otherwise fall through to a default behaviour,
+// so a typo in the environment fails the start instead of changing how the service works
+func (cfg *Config) validate() error {
+	switch cfg.Session.TokenFormat {
+	case TokenOpaque, TokenJWT:
+	default:
+		return fmt.Errorf("SESSION_TOKEN_FORMAT must be %q or %q, got %q", TokenOpaque, TokenJWT, cfg.Session.TokenFormat)
+	}
+
+	switch cfg.Session.LimitPolicy {
+	case LimitEvictOldest, LimitRefuse:
+	default:
+		return fmt.Errorf("SESSION_LIMIT_POLICY must be %q or %q, got %q", LimitEvictOldest, LimitRefuse, cfg.Session.LimitPolicy)
+	}
+
+	if cfg.SMS.PhoneMaxCodes <= 0 {
+		return fmt.Errorf("SMS_PHONE_MAX_CODES must be positive, got %d", cfg.SMS.PhoneMaxCodes)
+	}
+	if cfg.Mail.MaxCodes <= 0 {
+		return fmt.Errorf("MAIL_MAX_CODES must be positive, got %d", cfg.Mail.MaxCodes)
+	}
+
+	// a zero batch would never remove anything
+	if cfg.SMS.PurgeBatchSize <= 0 {
+		return fmt.Errorf("SMS_PURGE_BATCH_SIZE must be positive, got %d", cfg.SMS.PurgeBatchSize)
+	}
+	if cfg.Mail.PurgeBatchSize <= 0 {
+		return fmt.Errorf("MAIL_PURGE_BATCH_SIZE must be positive, got %d", cfg.Mail.PurgeBatchSize)
+	}
+
+	// intervals of background jobs and streams, tickers panic on non-positive ones
+	intervals := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"SESSION_PURGE_INTERVAL", cfg.Session.PurgeInterval},
+		{"SESSION_REVOCATION_POLL_INTERVAL", cfg.Session.RevocationPollInterval},
+		{"SESSION_CACHE_STATS_INTERVAL", cfg.Session.CacheStatsInterval},
+		{"SMS_OUTBOX_INTERVAL", cfg.SMS.OutboxInterval},
+		{"SMS_STATUS_POLL_INTERVAL", cfg.SMS.StatusPollInterval},
+		{"SMS_PURGE_INTERVAL", cfg.SMS.PurgeInterval},
+	}
+	for _, interval := range intervals {
+		if interval.value <= 0 {
+			return fmt.Errorf("%s must be positive, got %s", interval.name, interval.value)
+		}
+	}
+
+	return nil
+}
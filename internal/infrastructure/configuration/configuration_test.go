@@ -0,0 +1,63 @@
+package configuration
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		return &Config{
+			Session: SessionConfig{
+				TokenFormat:            TokenOpaque,
+				LimitPolicy:            LimitEvictOldest,
+				PurgeInterval:          time.Hour,
+				RevocationPollInterval: time.Second,
+				CacheStatsInterval:     time.Minute * 5,
+			},
+			SMS: SMSConfig{
+				PhoneMaxCodes:      5,
+				OutboxInterval:     time.Second,
+				StatusPollInterval: time.Minute,
+				PurgeInterval:      time.Hour,
+				PurgeBatchSize:     1000,
+			},
+			Mail: MailConfig{MaxCodes: 5, PurgeBatchSize: 1000},
+		}
+	}
+
+	testCases := []struct {
+		name   string
+		modify func(cfg *Config)
+		valid  bool
+	}{
+		{"defaults", func(cfg *Config) {}, true},
+		{"jwt", func(cfg *Config) { cfg.Session.TokenFormat = TokenJWT }, true},
+		{"unknown token format", func(cfg *Config) { cfg.Session.TokenFormat = "jwr" }, false},
+		{"refuse", func(cfg *Config) { cfg.Session.LimitPolicy = LimitRefuse }, true},
+		{"unknown limit policy", func(cfg *Config) { cfg.Session.LimitPolicy = "evict-oldest" }, false},
+		{"no phone codes", func(cfg *Config) { cfg.SMS.PhoneMaxCodes = 0 }, false},
+		{"no email codes", func(cfg *Config) { cfg.Mail.MaxCodes = -1 }, false},
+		{"no sms purge batch", func(cfg *Config) { cfg.SMS.PurgeBatchSize = 0 }, false},
+		{"no email purge batch", func(cfg *Config) { cfg.Mail.PurgeBatchSize = 0 }, false},
+		{"no session purge interval", func(cfg *Config) { cfg.Session.PurgeInterval = 0 }, false},
+		{"negative outbox interval", func(cfg *Config) { cfg.SMS.OutboxInterval = -time.Second }, false},
+		{"no revocation poll interval", func(cfg *Config) { cfg.Session.RevocationPollInterval = 0 }, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := valid()
+			tc.modify(cfg)
+
+			err := cfg.validate()
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package jwt
+
+import (
+	"account/internal/domain"
+	"account/internal/infrastructure/configuration"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type key struct {
+	id      string
+	private ed25519.PrivateKey
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+	Kid string `json:"kid"`
+}
+
+type claims struct {
+	Iss  string `json:"iss"`
+	Sub  string `json:"sub"`
+	Sid  int    `json:"sid"`
+	Role int    `json:"role"`
+	Iat  int64  `json:"iat"`
+	Exp  int64  `json:"exp"`
+	Act  *actor `json:"act,omitempty"`
+}
+
+// actor is the party acting on behalf of the subject (RFC 8693)
+type actor struct {
+	Sub string `json:"sub"`
+}
+
+// signer issues EdDSA signed JWTs
+type signer struct {
+	issuer string
+	keys   []key
+}
+
+func New(cfg *configuration.JWTConfig) (*signer, error) {
+	if len(cfg.SigningKeys) == 0 {
+		return nil, errors.New("no jwt signing keys configured")
+	}
+
+	keys := make([]key, 0, len(cfg.SigningKeys))
+	for _, value := range cfg.SigningKeys {
+		id, encoded, ok := strings.Cut(value, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("jwt signing key must be in form key_id:base64_seed")
+		}
+
+		seed, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode jwt signing key %s: %v", id, err)
+		}
+		if len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("jwt signing key %s must be a %d bytes seed", id, ed25519.SeedSize)
+		}
+
+		keys = append(keys, key{id: id, private: ed25519.NewKeyFromSeed(seed)})
+	}
+
+	return &signer{issuer: cfg.Issuer, keys: keys}, nil
+}
+
+// Sign issues a token with the first configured key
+func (s *signer) Sign(tokenClaims *domain.TokenClaims) (string, error) {
+	key := s.keys[0]
+
+	h, err := json.Marshal(header{Alg: "EdDSA", Typ: "JWT", Kid: key.id})
+	if err != nil {
+		return "", err
+	}
+
+	payload := claims{
+		Iss:  s.issuer,
+		Sub:  strconv.Itoa(tokenClaims.UserID),
+		Sid:  tokenClaims.SessionID,
+		Role: int(tokenClaims.RoleID),
+		Iat:  tokenClaims.IssuedAt.Unix(),
+		Exp:  tokenClaims.ExpiresAt.Unix(),
+	}
+	if tokenClaims.ImpersonatorID != 0 {
+		payload.Act = &actor{Sub: strconv.Itoa(tokenClaims.ImpersonatorID)}
+	}
+
+	p, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := encode(h) + "." + encode(p)
+	signature := ed25519.Sign(key.private, []byte(unsigned))
+
+	return unsigned + "." + encode(signature), nil
+}
+
+// Verify checks the signature with any of the configured keys, the issuer and the expiry of the token
+func (s *signer) Verify(token string, now time.Time) (*domain.TokenClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+
+	var h header
+	if err := decode(parts[0], &h); err != nil || h.Alg != "EdDSA" {
+		return nil, ErrInvalidToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	verified := false
+	for _, key := range s.keys {
+		if key.id == h.Kid {
+			verified = ed25519.Verify(key.private.Public().(ed25519.PublicKey), []byte(parts[0]+"."+parts[1]), signature)
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrInvalidToken
+	}
+
+	var p claims
+	if err := decode(parts[1], &p); err != nil || p.Iss != s.issuer {
+		return nil, ErrInvalidToken
+	}
+
+	userId, err := strconv.Atoi(p.Sub)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	output := &domain.TokenClaims{
+		SessionID: p.Sid,
+		UserID:    userId,
+		RoleID:    domain.RoleID(p.Role),
+		IssuedAt:  time.Unix(p.Iat, 0).UTC(),
+		ExpiresAt: time.Unix(p.Exp, 0).UTC(),
+	}
+	if p.Act != nil {
+		if output.ImpersonatorID, err = strconv.Atoi(p.Act.Sub); err != nil {
+			return nil, ErrInvalidToken
+		}
+	}
+	if now.After(output.ExpiresAt) {
+		return nil, domain.ErrAccessTokenExpired
+	}
+
+	return output, nil
+}
+
+// PublicKeys returns every configured key, including ones that no longer sign tokens
+func (s *signer) PublicKeys() []domain.PublicKey {
+	output := make([]domain.PublicKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		output = append(output, domain.PublicKey{
+			ID:  key.id,
+			Key: key.private.Public().(ed25519.PublicKey),
+		})
+	}
+	return output
+}
+
+func encode(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decode(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,89 @@
+package jwt
+
+import (
+	"account/internal/domain"
+	"account/internal/infrastructure/configuration"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func seed(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func TestSign(t *testing.T) {
+	now := time.Now().UTC()
+	tokenClaims := &domain.TokenClaims{
+		SessionID: 10,
+		UserID:    1,
+		RoleID:    domain.ModeratorRole,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(time.Minute * 15),
+	}
+
+	t.Run("success", func(t *testing.T) {
+		signer, err := New(&configuration.JWTConfig{Issuer: "test", SigningKeys: []string{"first:" + seed('a')}})
+		assert.NoError(t, err)
+
+		token, err := signer.Sign(tokenClaims)
+		assert.NoError(t, err)
+
+		output, err := signer.Verify(token, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 10, output.SessionID)
+		assert.Equal(t, 1, output.UserID)
+		assert.Equal(t, domain.ModeratorRole, output.RoleID)
+	})
+
+	t.Run("impersonated", func(t *testing.T) {
+		signer, _ := New(&configuration.JWTConfig{Issuer: "test", SigningKeys: []string{"first:" + seed('a')}})
+
+		impersonated := *tokenClaims
+		impersonated.ImpersonatorID = 5
+		token, err := signer.Sign(&impersonated)
+		assert.NoError(t, err)
+
+		output, err := signer.Verify(token, now)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, output.ImpersonatorID)
+	})
+
+	t.Run("rotated key", func(t *testing.T) {
+		old, _ := New(&configuration.JWTConfig{Issuer: "test", SigningKeys: []string{"first:" + seed('a')}})
+		token, _ := old.Sign(tokenClaims)
+
+		rotated, _ := New(&configuration.JWTConfig{Issuer: "test", SigningKeys: []string{"second:" + seed('b'), "first:" + seed('a')}})
+
+		_, err := rotated.Verify(token, now)
+		assert.NoError(t, err)
+		assert.Len(t, rotated.PublicKeys(), 2)
+		assert.Equal(t, "second", rotated.PublicKeys()[0].ID)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		signer, _ := New(&configuration.JWTConfig{Issuer: "test", SigningKeys: []string{"first:" + seed('a')}})
+		token, _ := signer.Sign(tokenClaims)
+
+		other, _ := New(&configuration.JWTConfig{Issuer: "test", SigningKeys: []string{"first:" + seed('b')}})
+
+		_, err := other.Verify(token, now)
+		assert.ErrorIs(t, err, ErrInvalidToken)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		signer, _ := New(&configuration.JWTConfig{Issuer: "test", SigningKeys: []string{"first:" + seed('a')}})
+		token, _ := signer.Sign(tokenClaims)
+
+		_, err := signer.Verify(token, now.Add(time.Hour))
+		assert.ErrorIs(t, err, domain.ErrAccessTokenExpired)
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		_, err := New(&configuration.JWTConfig{SigningKeys: []string{"first:c2hvcnQ="}})
+		assert.Error(t, err)
+	})
+}
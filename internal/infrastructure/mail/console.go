@@ -0,0 +1,28 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// console writes emails instead of sending them, for local development
+type console struct {
+	mu   sync.Mutex
+	out  io.Writer
+	from string
+}
+
+func NewConsole(out io.Writer, from string) *console {
+	return &console{out: out, from: from}
+}
+
+func (m *console) Send(c context.Context, to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.out, "%s email from %s to %s:\n%s\n\n%s\n\n", time.Now().Format(time.RFC3339), m.from, to, subject, body)
+	return err
+}
@@ -0,0 +1,66 @@
+package mail
+
+import (
+	"account/internal/infrastructure/configuration"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"os"
+	"strconv"
+	"time"
+)
+
+type Mailer interface {
+	Send(c context.Context, to, subject, body string) error
+}
+
+// New builds the mailer chosen by cfg.Mailer, it's nil if emails are disabled
+func New(cfg *configuration.MailConfig) (Mailer, error) {
+	switch cfg.Mailer {
+	case "":
+		return nil, nil
+	case "smtp":
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("no smtp host configured")
+		}
+		return NewSMTP(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From, cfg.SMTPInsecure), nil
+	case "console":
+		var out io.Writer = os.Stdout
+		if cfg.ConsoleFile != "" {
+			file, err := os.OpenFile(cfg.ConsoleFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+			if err != nil {
+				return nil, err
+			}
+			out = file
+		}
+		return NewConsole(out, cfg.From), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", cfg.Mailer)
+	}
+}
+
+// message is a plain text email in utf-8
+func message(from, to, subject, body string, date time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", to)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s.%s>\r\n", strconv.FormatInt(date.UnixNano(), 36), from)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(&buf)
+	if _, err := w.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,138 @@
+package mail
+
+import (
+	"account/internal/infrastructure/configuration"
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage(t *testing.T) {
+	msg, err := message("no-reply@mangahana.com", "john@example.com", "Растау коды", "Код: 123456", time.Now())
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	parsed, err := mail.ReadMessage(bytes.NewReader(msg))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	assert.NoError(t, err)
+	assert.Equal(t, "Растау коды", subject)
+	assert.Equal(t, "john@example.com", parsed.Header.Get("To"))
+
+	body, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	assert.NoError(t, err)
+	assert.Equal(t, "Код: 123456", string(body))
+}
+
+func TestConsole(t *testing.T) {
+	var out bytes.Buffer
+
+	err := NewConsole(&out, "no-reply@mangahana.com").Send(context.Background(), "john@example.com", "subject", "123456")
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "john@example.com")
+	assert.Contains(t, out.String(), "123456")
+}
+
+// plainSMTP serves one smtp session without STARTTLS and returns the received data
+func plainSMTP(t *testing.T) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost")
+		var data string
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				break
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "EHLO":
+				text.PrintfLine("250 localhost")
+			case "DATA":
+				text.PrintfLine("354 go ahead")
+				lines, _ := text.ReadDotLines()
+				data = strings.Join(lines, "\n")
+				text.PrintfLine("250 ok")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+			default:
+				text.PrintfLine("250 ok")
+			}
+		}
+		received <- data
+	}()
+
+	return ln.Addr().String(), received
+}
+
+func TestSMTP(t *testing.T) {
+	t.Run("no starttls", func(t *testing.T) {
+		addr, received := plainSMTP(t)
+		host, port, _ := net.SplitHostPort(addr)
+		portNum, _ := strconv.Atoi(port)
+
+		err := NewSMTP(host, portNum, "", "", "no-reply@mangahana.com", false).Send(context.Background(), "john@example.com", "subject", "123456")
+		assert.Error(t, err)
+		assert.NotContains(t, <-received, "123456")
+	})
+
+	t.Run("insecure relay", func(t *testing.T) {
+		addr, received := plainSMTP(t)
+		host, port, _ := net.SplitHostPort(addr)
+		portNum, _ := strconv.Atoi(port)
+
+		err := NewSMTP(host, portNum, "", "", "no-reply@mangahana.com", true).Send(context.Background(), "john@example.com", "subject", "123456")
+		assert.NoError(t, err)
+		assert.Contains(t, <-received, "123456")
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Run("smtp", func(t *testing.T) {
+		_, err := New(&configuration.MailConfig{Mailer: "smtp", SMTPHost: "smtp.example.com", SMTPPort: 587})
+		assert.NoError(t, err)
+	})
+
+	t.Run("no smtp host", func(t *testing.T) {
+		_, err := New(&configuration.MailConfig{Mailer: "smtp"})
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown mailer", func(t *testing.T) {
+		_, err := New(&configuration.MailConfig{Mailer: "pigeon"})
+		assert.Error(t, err)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		mailer, err := New(&configuration.MailConfig{})
+		assert.NoError(t, err)
+		assert.Nil(t, mailer)
+	})
+}
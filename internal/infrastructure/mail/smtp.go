@@ -0,0 +1,86 @@
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// smtpMailer sends emails through an smtp server, emails carry codes,
+// so a server which doesn't offer STARTTLS is refused unless it's allowed to be insecure
+type smtpMailer struct {
+	host     string
+	addr     string
+	auth     smtp.Auth
+	from     string
+	insecure bool
+}
+
+func NewSMTP(host string, port int, username, password, from string, insecure bool) *smtpMailer {
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return &smtpMailer{host: host, addr: net.JoinHostPort(host, strconv.Itoa(port)), auth: auth, from: from, insecure: insecure}
+}
+
+func (m *smtpMailer) Send(c context.Context, to, subject, body string) error {
+	msg, err := message(m.from, to, subject, body, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(c, "tcp", m.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to smtp server: %v", err)
+	}
+	if deadline, ok := c.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return err
+		}
+	} else if !m.insecure {
+		return errors.New("smtp server doesn't offer STARTTLS")
+	}
+	if m.auth != nil {
+		if err := client.Auth(m.auth); err != nil {
+			return err
+		}
+	}
+
+	if err := client.Mail(m.from); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
@@ -0,0 +1,58 @@
+package code
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// texts and codes of queued messages are encrypted with a key derived from the code secret,
+// so codes can't be read from the database while they wait for delivery
+
+func newCipher(secret []byte) cipher.AEAD {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("sms outbox"))
+
+	// a 32 byte key is always valid for aes-256-gcm
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+// encrypt seals the text to the recipient, so it can't be moved to another message
+func (r *repo) encrypt(recipient, text string) (string, error) {
+	nonce := make([]byte, r.cipher.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := r.cipher.Seal(nonce, nonce, []byte(text), []byte(recipient))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (r *repo) decrypt(recipient, text string) (string, error) {
+	sealed, err := base64.StdEncoding.DecodeString(text)
+	if err != nil {
+		return "", err
+	}
+	if len(sealed) < r.cipher.NonceSize() {
+		return "", errors.New("encrypted text is too short")
+	}
+
+	nonce, sealed := sealed[:r.cipher.NonceSize()], sealed[r.cipher.NonceSize():]
+	plain, err := r.cipher.Open(nil, nonce, sealed, []byte(recipient))
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
@@ -3,31 +3,34 @@ package code
 import (
 	"account/internal/domain"
 	"context"
+	"crypto/cipher"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type repo struct {
-	db *pgxpool.Pool
+	db     *pgxpool.Pool
+	cipher cipher.AEAD
 }
 
-func New(db *pgxpool.Pool) *repo {
-	return &repo{db: db}
+// New takes the code secret, texts of queued messages are encrypted with a key derived from it
+func New(db *pgxpool.Pool, secret []byte) *repo {
+	return &repo{db: db, cipher: newCipher(secret)}
 }
 
-func (r *repo) FindLatestByIP(c context.Context, ip string, timestamp time.Time) ([]domain.Code, error) {
+func (r *repo) FindLatestByIP(c context.Context, ip string, purpose domain.CodePurpose, timestamp time.Time) ([]domain.Code, error) {
 	output := []domain.Code{}
 
-	sql := "SELECT code, phone, ip, created_at FROM codes WHERE ip = $1 AND created_at > $2;"
-	rows, err := r.db.Query(c, sql, ip, timestamp.UTC())
+	sql := "SELECT id, code_hash, salt, phone, ip, purpose, channel, created_at, expires_at, attempts FROM codes WHERE ip = $1 AND purpose = $2 AND created_at > $3;"
+	rows, err := r.db.Query(c, sql, ip, purpose, timestamp.UTC())
 	if err != nil {
 		return output, nil
 	}
 
 	for rows.Next() {
 		var c domain.Code
-		if err := rows.Scan(&c.Code, &c.Phone, &c.IP, &c.CreatedAt); err != nil {
+		if err := rows.Scan(&c.ID, &c.Hash, &c.Salt, &c.Phone, &c.IP, &c.Purpose, &c.Channel, &c.CreatedAt, &c.ExpiresAt, &c.Attempts); err != nil {
 			return output, nil
 		}
 		output = append(output, c)
@@ -36,32 +39,122 @@ func (r *repo) FindLatestByIP(c context.Context, ip string, timestamp time.Time)
 	return output, nil
 }
 
-func (r *repo) FindOneByCredentials(c context.Context, phone, code string) (*domain.Code, error) {
+// FindLatestByPhone returns codes for the purpose sent to the phone after the timestamp, newest first
+func (r *repo) FindLatestByPhone(c context.Context, phone string, purpose domain.CodePurpose, timestamp time.Time) ([]domain.Code, error) {
+	output := []domain.Code{}
+
+	sql := "SELECT id, code_hash, salt, phone, ip, purpose, channel, created_at, expires_at, attempts FROM codes WHERE phone = $1 AND purpose = $2 AND created_at > $3 ORDER BY created_at DESC;"
+	rows, err := r.db.Query(c, sql, phone, purpose, timestamp.UTC())
+	if err != nil {
+		return output, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c domain.Code
+		if err := rows.Scan(&c.ID, &c.Hash, &c.Salt, &c.Phone, &c.IP, &c.Purpose, &c.Channel, &c.CreatedAt, &c.ExpiresAt, &c.Attempts); err != nil {
+			return output, err
+		}
+		output = append(output, c)
+	}
+
+	return output, rows.Err()
+}
+
+// FindNewestByPhone returns the last code for the purpose sent to the phone, older codes are no longer valid
+func (r *repo) FindNewestByPhone(c context.Context, phone string, purpose domain.CodePurpose) (*domain.Code, error) {
 	var output domain.Code
 
-	sql := "SELECT code, phone, ip, created_at FROM codes WHERE phone = $1 AND code = $2;"
-	err := r.db.QueryRow(c, sql, phone, code).Scan(&output.Code, &output.Phone, &output.IP, &output.CreatedAt)
+	sql := "SELECT id, code_hash, salt, phone, ip, purpose, channel, created_at, expires_at, attempts FROM codes WHERE phone = $1 AND purpose = $2 ORDER BY created_at DESC LIMIT 1;"
+	err := r.db.QueryRow(c, sql, phone, purpose).Scan(&output.ID, &output.Hash, &output.Salt, &output.Phone, &output.IP, &output.Purpose, &output.Channel, &output.CreatedAt, &output.ExpiresAt, &output.Attempts)
 
 	return &output, err
 }
 
-func (r *repo) FindOneByPhoneAndIP(c context.Context, phone, ip string) (*domain.Code, error) {
+func (r *repo) FindOneByPhoneAndIP(c context.Context, phone, ip string, purpose domain.CodePurpose) (*domain.Code, error) {
 	var output domain.Code
 
-	sql := "SELECT code, phone, ip, created_at FROM codes WHERE phone = $1 AND ip = $2;"
-	err := r.db.QueryRow(c, sql, phone, ip).Scan(&output.Code, &output.Phone, &output.IP, &output.CreatedAt)
+	sql := "SELECT id, code_hash, salt, phone, ip, purpose, channel, created_at, expires_at, attempts FROM codes WHERE phone = $1 AND ip = $2 AND purpose = $3 ORDER BY created_at DESC LIMIT 1;"
+	err := r.db.QueryRow(c, sql, phone, ip, purpose).Scan(&output.ID, &output.Hash, &output.Salt, &output.Phone, &output.IP, &output.Purpose, &output.Channel, &output.CreatedAt, &output.ExpiresAt, &output.Attempts)
 
 	return &output, err
 }
 
-func (r *repo) Save(c context.Context, code *domain.Code) error {
-	sql := "INSERT INTO codes (code, phone, ip) VALUES ($1, $2, $3);"
-	_, err := r.db.Exec(c, sql, code.Code, code.Phone, code.IP)
+// Save stores the code and queues the sms with it, so a code is never stored without its message.
+// The text and the code of the message are stored encrypted.
+func (r *repo) Save(c context.Context, code *domain.Code, message *domain.SMS) error {
+	text, err := r.encrypt(message.Recipient, message.Text)
+	if err != nil {
+		return err
+	}
+	sealedCode, err := r.encrypt(message.Recipient, message.Code)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin(c)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(c)
+
+	sql := "INSERT INTO codes (code_hash, salt, phone, ip, purpose, channel, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8);"
+	if _, err := tx.Exec(c, sql, code.Hash, code.Salt, code.Phone, code.IP, code.Purpose, code.Channel, code.CreatedAt.UTC(), code.ExpiresAt.UTC()); err != nil {
+		return err
+	}
+
+	sql = "INSERT INTO sms_outbox (recipient, text, code, channel, locale, created_at, next_attempt_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $6, $7);"
+	if _, err := tx.Exec(c, sql, message.Recipient, text, sealedCode, message.Channel, message.Locale, message.CreatedAt.UTC(), message.ExpiresAt.UTC()); err != nil {
+		return err
+	}
+
+	return tx.Commit(c)
+}
+
+// AddAttempt counts a verification attempt of the code,
+// it returns pgx.ErrNoRows if the code already has maxAttempts attempts
+func (r *repo) AddAttempt(c context.Context, id, maxAttempts int) error {
+	var attempts int
+	sql := "UPDATE codes SET attempts = attempts + 1 WHERE id = $1 AND attempts < $2 RETURNING attempts;"
+	return r.db.QueryRow(c, sql, id, maxAttempts).Scan(&attempts)
+}
+
+// RemoveAttempt takes back an attempt that turned out to be successful
+func (r *repo) RemoveAttempt(c context.Context, id int) error {
+	sql := "UPDATE codes SET attempts = attempts - 1 WHERE id = $1 AND attempts > 0;"
+	_, err := r.db.Exec(c, sql, id)
 	return err
 }
 
-func (r *repo) RemoveAll(c context.Context, phone string) error {
-	sql := "DELETE FROM codes WHERE phone = $1;"
-	_, err := r.db.Exec(c, sql, phone)
+func (r *repo) SaveFailure(c context.Context, phone string, purpose domain.CodePurpose) error {
+	sql := "INSERT INTO code_failures (phone, purpose) VALUES ($1, $2);"
+	_, err := r.db.Exec(c, sql, phone, purpose)
 	return err
 }
+
+func (r *repo) CountFailures(c context.Context, phone string, purpose domain.CodePurpose, since time.Time) (int, error) {
+	var count int
+	sql := "SELECT count(*) FROM code_failures WHERE phone = $1 AND purpose = $2 AND created_at > $3;"
+	err := r.db.QueryRow(c, sql, phone, purpose, since.UTC()).Scan(&count)
+	return count, err
+}
+
+func (r *repo) RemoveAll(c context.Context, phone string, purpose domain.CodePurpose) error {
+	sql := "DELETE FROM codes WHERE phone = $1 AND purpose = $2;"
+	_, err := r.db.Exec(c, sql, phone, purpose)
+	return err
+}
+
+// RemoveExpired removes up to limit codes which expired before the time
+func (r *repo) RemoveExpired(c context.Context, before time.Time, limit int) (int64, error) {
+	sql := "DELETE FROM codes WHERE id IN (SELECT id FROM codes WHERE expires_at < $1 LIMIT $2);"
+	tag, err := r.db.Exec(c, sql, before.UTC(), limit)
+	return tag.RowsAffected(), err
+}
+
+// RemoveFailures removes up to limit failures made before the time
+func (r *repo) RemoveFailures(c context.Context, before time.Time, limit int) (int64, error) {
+	sql := "DELETE FROM code_failures WHERE ctid IN (SELECT ctid FROM code_failures WHERE created_at < $1 LIMIT $2);"
+	tag, err := r.db.Exec(c, sql, before.UTC(), limit)
+	return tag.RowsAffected(), err
+}
@@ -19,7 +19,7 @@ func setup(t *testing.T) (context.Context, *pgxpool.Pool) {
 		t.Fatal(err)
 	}
 
-	db.Exec(c, "TRUNCATE TABLE codes;")
+	db.Exec(c, "TRUNCATE TABLE codes, code_failures, sms_outbox;")
 
 	return c, db
 }
@@ -28,53 +28,116 @@ func TestFindLatestByIP(t *testing.T) {
 	c, db := setup(t)
 
 	t.Run("success", func(t *testing.T) {
-		repo := New(db)
+		repo := New(db, []byte("secret"))
 
 		// setup
-		db.Exec(c, "INSERT INTO codes (code, phone, ip) VALUES('7823', '7779995566', '127.0.0.1');")
+		db.Exec(c, "INSERT INTO codes (code_hash, phone, ip) VALUES('7823', '7779995566', '127.0.0.1');")
 
-		codes, err := repo.FindLatestByIP(c, "127.0.0.1", time.Now().UTC().Add(time.Minute*-30))
+		codes, err := repo.FindLatestByIP(c, "127.0.0.1", domain.PurposeRegister, time.Now().UTC().Add(time.Minute*-30))
 		assert.NoError(t, err)
 		assert.Len(t, codes, 1)
 	})
 }
 
+func TestFindLatestByPhone(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, []byte("secret"))
+
+		db.Exec(c, "INSERT INTO codes (code_hash, phone, ip, created_at) VALUES ('1111', '7779995566', '127.0.0.1', NOW() - INTERVAL '1 hour');")
+		db.Exec(c, "INSERT INTO codes (code_hash, phone, ip, created_at) VALUES ('2222', '7779995566', '127.0.0.2', NOW() - INTERVAL '5 minutes');")
+		db.Exec(c, "INSERT INTO codes (code_hash, phone, ip) VALUES ('3333', '7779995566', '127.0.0.3');")
+
+		codes, err := repo.FindLatestByPhone(c, "7779995566", domain.PurposeRegister, time.Now().UTC().Add(time.Minute*-30))
+		assert.NoError(t, err)
+		if assert.Len(t, codes, 2) {
+			assert.Equal(t, "3333", codes[0].Hash)
+		}
+	})
+}
+
 func TestSave(t *testing.T) {
 	c, db := setup(t)
 
 	t.Run("success", func(t *testing.T) {
-		repo := New(db)
+		repo := New(db, []byte("secret"))
 
 		code := &domain.Code{
-			Code:      "1234",
+			Hash:      "hash",
+			Salt:      "salt",
 			Phone:     "8889995566",
 			IP:        "127.0.0.1",
+			Purpose:   domain.PurposeRegister,
 			CreatedAt: time.Now().UTC(),
+			ExpiresAt: time.Now().UTC().Add(time.Minute * 5),
+		}
+		message := domain.NewSMS("78889995566", "1234", code.ExpiresAt)
+		err := repo.Save(c, code, message)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("code is not stored in plain", func(t *testing.T) {
+		db.Exec(c, "TRUNCATE TABLE codes, sms_outbox;")
+		repo := New(db, []byte("secret"))
+
+		code := &domain.Code{Hash: "hash", Salt: "salt", Phone: "8889995566", IP: "127.0.0.1", Purpose: domain.PurposeRegister, CreatedAt: time.Now().UTC(), ExpiresAt: time.Now().UTC().Add(time.Minute * 5)}
+		message := domain.NewSMS("78889995566", "Your code 573916", code.ExpiresAt)
+		message.Code = "573916"
+		assert.NoError(t, repo.Save(c, code, message))
+
+		var rows int
+		db.QueryRow(c, "SELECT COUNT(*) FROM sms_outbox s, codes c WHERE s::text LIKE '%573916%' OR c::text LIKE '%573916%';").Scan(&rows)
+		assert.Equal(t, 0, rows)
+
+		// another secret can't read it
+		messages, err := New(db, []byte("other")).ClaimQueued(c, 10, 0)
+		assert.NoError(t, err)
+		if assert.Len(t, messages, 1) {
+			assert.Empty(t, messages[0].Text)
+			assert.Empty(t, messages[0].Code)
 		}
-		err := repo.Save(c, code)
 
+		db.Exec(c, "UPDATE sms_outbox SET next_attempt_at = NOW() - INTERVAL '1 minute';")
+		messages, err = repo.ClaimQueued(c, 10, time.Minute)
 		assert.NoError(t, err)
+		if assert.Len(t, messages, 1) {
+			assert.Equal(t, "Your code 573916", messages[0].Text)
+			assert.Equal(t, "573916", messages[0].Code)
+		}
 	})
 }
 
-func TestFindOneByCredentials(t *testing.T) {
+func TestFindNewestByPhone(t *testing.T) {
 	c, db := setup(t)
 
 	t.Run("success", func(t *testing.T) {
-		repo := New(db)
+		repo := New(db, []byte("secret"))
 
-		db.Exec(c, "INSERT INTO codes (code, phone, ip) VALUES ('1234','7778889966','127.0.0.1')")
+		db.Exec(c, "INSERT INTO codes (code_hash, phone, ip, created_at) VALUES ('1234','7778889966','127.0.0.1', NOW() - INTERVAL '1 minute')")
+		db.Exec(c, "INSERT INTO codes (code_hash, phone, ip) VALUES ('5678','7778889966','127.0.0.1')")
 
-		code, err := repo.FindOneByCredentials(c, "7778889966", "1234")
+		code, err := repo.FindNewestByPhone(c, "7778889966", domain.PurposeRegister)
 
 		assert.NoError(t, err)
-		assert.NotZero(t, code)
+		assert.Equal(t, "5678", code.Hash)
 	})
 
-	t.Run("success", func(t *testing.T) {
-		repo := New(db)
+	t.Run("fail", func(t *testing.T) {
+		repo := New(db, []byte("secret"))
+
+		_, err := repo.FindNewestByPhone(c, "wrongnumber", domain.PurposeRegister)
+
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+	})
+
+	t.Run("other purpose", func(t *testing.T) {
+		repo := New(db, []byte("secret"))
 
-		_, err := repo.FindOneByCredentials(c, "wrongnumber", "1234")
+		db.Exec(c, "INSERT INTO codes (code_hash, phone, ip, purpose) VALUES ('4321','7778880000','127.0.0.1','login')")
+
+		_, err := repo.FindNewestByPhone(c, "7778880000", domain.PurposePasswordReset)
 
 		assert.ErrorIs(t, err, pgx.ErrNoRows)
 	})
@@ -84,25 +147,102 @@ func TestFindOneByPhoneAndIP(t *testing.T) {
 	c, db := setup(t)
 
 	t.Run("success", func(t *testing.T) {
-		repo := New(db)
+		repo := New(db, []byte("secret"))
 
-		db.Exec(c, "INSERT INTO codes (code, phone, ip) VALUES ('1234','7778889966','127.0.0.1')")
+		db.Exec(c, "INSERT INTO codes (code_hash, phone, ip) VALUES ('1234','7778889966','127.0.0.1')")
 
-		code, err := repo.FindOneByPhoneAndIP(c, "7778889966", "127.0.0.1")
+		code, err := repo.FindOneByPhoneAndIP(c, "7778889966", "127.0.0.1", domain.PurposeRegister)
 
 		assert.NoError(t, err)
 		assert.NotZero(t, code)
 	})
 }
 
+func TestAddAttempt(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, []byte("secret"))
+
+		db.Exec(c, "INSERT INTO codes (code_hash, phone, ip) VALUES ('1234','7778889966','127.0.0.1')")
+		code, err := repo.FindNewestByPhone(c, "7778889966", domain.PurposeRegister)
+		assert.NoError(t, err)
+
+		assert.NoError(t, repo.AddAttempt(c, code.ID, 2))
+		assert.NoError(t, repo.AddAttempt(c, code.ID, 2))
+		assert.ErrorIs(t, repo.AddAttempt(c, code.ID, 2), pgx.ErrNoRows)
+
+		assert.NoError(t, repo.RemoveAttempt(c, code.ID))
+		assert.NoError(t, repo.AddAttempt(c, code.ID, 2))
+	})
+}
+
+func TestCountFailures(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, []byte("secret"))
+
+		db.Exec(c, "INSERT INTO code_failures (phone, created_at) VALUES ('7778889966', NOW() - INTERVAL '2 hours')")
+		assert.NoError(t, repo.SaveFailure(c, "7778889966", domain.PurposeRegister))
+
+		count, err := repo.CountFailures(c, "7778889966", domain.PurposeRegister, time.Now().Add(-time.Hour))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+}
+
 func TestRemoveAll(t *testing.T) {
 	c, db := setup(t)
 
 	t.Run("success", func(t *testing.T) {
-		repo := New(db)
+		repo := New(db, []byte("secret"))
+
+		err := repo.RemoveAll(c, "7778889966", domain.PurposeRegister)
+
+		assert.NoError(t, err)
+	})
+}
+
+func TestRemoveExpired(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, []byte("secret"))
+
+		for range 3 {
+			db.Exec(c, "INSERT INTO codes (code_hash, phone, ip, expires_at) VALUES ('1234','7778889966','127.0.0.1', NOW() - INTERVAL '2 days')")
+		}
+		db.Exec(c, "INSERT INTO codes (code_hash, phone, ip, expires_at) VALUES ('5678','7778889966','127.0.0.1', NOW() + INTERVAL '5 minutes')")
+
+		before := time.Now().Add(-time.Hour * 24)
+
+		count, err := repo.RemoveExpired(c, before, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+
+		count, err = repo.RemoveExpired(c, before, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		code, err := repo.FindNewestByPhone(c, "7778889966", domain.PurposeRegister)
+		assert.NoError(t, err)
+		assert.Equal(t, "5678", code.Hash)
+	})
+}
+
+func TestRemoveFailures(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, []byte("secret"))
 
-		err := repo.RemoveAll(c, "7778889966")
+		db.Exec(c, "INSERT INTO code_failures (phone, created_at) VALUES ('7778889966', NOW() - INTERVAL '2 days')")
+		assert.NoError(t, repo.SaveFailure(c, "7778889966", domain.PurposeRegister))
 
+		count, err := repo.RemoveFailures(c, time.Now().Add(-time.Hour*24), 100)
 		assert.NoError(t, err)
+		assert.Equal(t, int64(1), count)
 	})
 }
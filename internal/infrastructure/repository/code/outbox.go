@@ -0,0 +1,137 @@
+package code
+
+import (
+	"account/internal/domain"
+	"context"
+	"time"
+)
+
+// ClaimQueued returns up to limit queued messages due for delivery and hides them
+// from other workers for lease, so a message is retried if its worker dies.
+// A text or a code which can't be decrypted is returned empty.
+func (r *repo) ClaimQueued(c context.Context, limit int, lease time.Duration) ([]domain.SMS, error) {
+	output := []domain.SMS{}
+	now := time.Now().UTC()
+
+	sql := `UPDATE sms_outbox SET next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM sms_outbox
+			WHERE status = 'queued' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, recipient, text, code, channel, locale, status, attempts, created_at, expires_at;`
+	rows, err := r.db.Query(c, sql, now, now.Add(lease), limit)
+	if err != nil {
+		return output, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m domain.SMS
+		if err := rows.Scan(&m.ID, &m.Recipient, &m.Text, &m.Code, &m.Channel, &m.Locale, &m.Status, &m.Attempts, &m.CreatedAt, &m.ExpiresAt); err != nil {
+			return output, err
+		}
+		m.Text, _ = r.decrypt(m.Recipient, m.Text)
+		m.Code, _ = r.decrypt(m.Recipient, m.Code)
+		output = append(output, m)
+	}
+
+	return output, rows.Err()
+}
+
+// MarkSent saves the channel which delivered the message and its id at the provider
+// which accepted it, and clears the text and the code
+func (r *repo) MarkSent(c context.Context, message *domain.SMS) error {
+	sql := `UPDATE sms_outbox SET status = 'sent', text = '', code = '', channel = $2, provider = $3, provider_message_id = $4, sent_at = $5, status_at = $5
+		WHERE id = $1;`
+	_, err := r.db.Exec(c, sql, message.ID, message.Channel, message.Provider, message.ProviderMessageID, time.Now().UTC())
+	return err
+}
+
+// MarkFailed counts a failed attempt and schedules the next one
+func (r *repo) MarkFailed(c context.Context, id int, nextAttemptAt time.Time, lastError string) error {
+	sql := "UPDATE sms_outbox SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1;"
+	_, err := r.db.Exec(c, sql, id, lastError, nextAttemptAt.UTC())
+	return err
+}
+
+// MarkDead gives up delivery of the message after the attempts made
+func (r *repo) MarkDead(c context.Context, id, attempts int, lastError string) error {
+	sql := "UPDATE sms_outbox SET status = 'dead', text = '', code = '', attempts = $2, last_error = $3, status_at = $4 WHERE id = $1;"
+	_, err := r.db.Exec(c, sql, id, attempts, lastError, time.Now().UTC())
+	return err
+}
+
+// FindUnconfirmed returns messages sent after sentAfter which have no final status
+// and weren't polled since checkedBefore, the oldest first
+func (r *repo) FindUnconfirmed(c context.Context, sentAfter, checkedBefore time.Time, limit int) ([]domain.SMS, error) {
+	output := []domain.SMS{}
+
+	sql := `SELECT ` + smsColumns + ` FROM sms_outbox
+		WHERE status = 'sent' AND sent_at > $1 AND COALESCE(checked_at, sent_at) < $2
+		ORDER BY sent_at
+		LIMIT $3;`
+	rows, err := r.db.Query(c, sql, sentAfter.UTC(), checkedBefore.UTC(), limit)
+	if err != nil {
+		return output, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m domain.SMS
+		if err := rows.Scan(smsFields(&m)...); err != nil {
+			return output, err
+		}
+		output = append(output, m)
+	}
+
+	return output, rows.Err()
+}
+
+// UpdateStatus saves a polled status of a sent message, a final status is never changed
+func (r *repo) UpdateStatus(c context.Context, id int, status domain.SMSStatus) error {
+	sql := `UPDATE sms_outbox SET
+			checked_at = $3,
+			status_at = CASE WHEN status <> $2 THEN $3 ELSE status_at END,
+			status = $2
+		WHERE id = $1 AND status = 'sent';`
+	_, err := r.db.Exec(c, sql, id, status, time.Now().UTC())
+	return err
+}
+
+// UpdateStatusByProviderID saves a status reported by the provider of the message,
+// it returns pgx.ErrNoRows if there is no such message
+func (r *repo) UpdateStatusByProviderID(c context.Context, provider, providerMessageId string, status domain.SMSStatus) error {
+	var id int
+	sql := `UPDATE sms_outbox SET
+			status_at = CASE WHEN status = 'sent' AND status <> $3 THEN $4 ELSE status_at END,
+			status = CASE WHEN status = 'sent' THEN $3 ELSE status END
+		WHERE provider = $1 AND provider_message_id = $2
+		RETURNING id;`
+	return r.db.QueryRow(c, sql, provider, providerMessageId, status, time.Now().UTC()).Scan(&id)
+}
+
+// FindLatestByRecipient returns the last message queued for the recipient
+func (r *repo) FindLatestByRecipient(c context.Context, recipient string) (*domain.SMS, error) {
+	var output domain.SMS
+
+	sql := `SELECT ` + smsColumns + ` FROM sms_outbox WHERE recipient = $1 ORDER BY created_at DESC LIMIT 1;`
+	err := r.db.QueryRow(c, sql, recipient).Scan(smsFields(&output)...)
+
+	return &output, err
+}
+
+// RemoveFinished removes up to limit messages which are no longer queued and expired before the time
+func (r *repo) RemoveFinished(c context.Context, before time.Time, limit int) (int64, error) {
+	sql := "DELETE FROM sms_outbox WHERE id IN (SELECT id FROM sms_outbox WHERE status <> 'queued' AND expires_at < $1 LIMIT $2);"
+	tag, err := r.db.Exec(c, sql, before.UTC(), limit)
+	return tag.RowsAffected(), err
+}
+
+const smsColumns = "id, recipient, channel, status, attempts, created_at, expires_at, provider, provider_message_id, status_at"
+
+func smsFields(m *domain.SMS) []any {
+	return []any{&m.ID, &m.Recipient, &m.Channel, &m.Status, &m.Attempts, &m.CreatedAt, &m.ExpiresAt, &m.Provider, &m.ProviderMessageID, &m.StatusAt}
+}
@@ -0,0 +1,117 @@
+package code
+
+import (
+	"account/internal/domain"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutbox(t *testing.T) {
+	c, db := setup(t)
+	repo := New(db, []byte("secret"))
+
+	code := &domain.Code{Hash: "hash", Salt: "salt", Phone: "8889995566", IP: "127.0.0.1", Purpose: domain.PurposeRegister, CreatedAt: time.Now().UTC(), ExpiresAt: time.Now().UTC().Add(time.Minute * 5)}
+	message := domain.NewSMS("78889995566", "1234", code.ExpiresAt)
+	message.Code = "1234"
+	message.Channel = domain.ChannelTelegram
+	assert.NoError(t, repo.Save(c, code, message))
+
+	t.Run("claim", func(t *testing.T) {
+		messages, err := repo.ClaimQueued(c, 10, time.Minute)
+		assert.NoError(t, err)
+		if !assert.Len(t, messages, 1) {
+			return
+		}
+		assert.Equal(t, "1234", messages[0].Text)
+		assert.Equal(t, "1234", messages[0].Code)
+		assert.Equal(t, domain.ChannelTelegram, messages[0].Channel)
+		assert.Equal(t, domain.DefaultLocale, messages[0].Locale)
+
+		// the claimed message is leased to this worker
+		messages, err = repo.ClaimQueued(c, 10, time.Minute)
+		assert.NoError(t, err)
+		assert.Len(t, messages, 0)
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		var id int
+		db.QueryRow(c, "SELECT id FROM sms_outbox;").Scan(&id)
+
+		assert.NoError(t, repo.MarkFailed(c, id, time.Now().Add(-time.Second), "unavailable"))
+
+		messages, err := repo.ClaimQueued(c, 10, time.Minute)
+		assert.NoError(t, err)
+		if assert.Len(t, messages, 1) {
+			assert.Equal(t, 1, messages[0].Attempts)
+		}
+	})
+
+	t.Run("sent", func(t *testing.T) {
+		var id int
+		db.QueryRow(c, "SELECT id FROM sms_outbox;").Scan(&id)
+
+		// telegram failed and the code was sent by sms
+		assert.NoError(t, repo.MarkSent(c, &domain.SMS{ID: id, Channel: domain.ChannelSMS, Provider: "smsc", ProviderMessageID: "7"}))
+
+		var status, text, code, channel string
+		db.QueryRow(c, "SELECT status, text, code, channel FROM sms_outbox WHERE id = $1;", id).Scan(&status, &text, &code, &channel)
+		assert.Equal(t, "sent", status)
+		assert.Empty(t, text)
+		assert.Empty(t, code)
+		assert.Equal(t, "sms", channel)
+	})
+
+	t.Run("delivery status", func(t *testing.T) {
+		db.Exec(c, "UPDATE sms_outbox SET sent_at = NOW() - INTERVAL '5 minutes';")
+
+		messages, err := repo.FindUnconfirmed(c, time.Now().Add(-time.Hour), time.Now().Add(-time.Minute), 10)
+		assert.NoError(t, err)
+		if !assert.Len(t, messages, 1) {
+			return
+		}
+		assert.Equal(t, "7", messages[0].ProviderMessageID)
+
+		assert.NoError(t, repo.UpdateStatus(c, messages[0].ID, domain.SMSSent))
+		// it was checked just now
+		messages, err = repo.FindUnconfirmed(c, time.Now().Add(-time.Hour), time.Now().Add(-time.Minute), 10)
+		assert.NoError(t, err)
+		assert.Len(t, messages, 0)
+
+		assert.NoError(t, repo.UpdateStatusByProviderID(c, "smsc", "7", domain.SMSDelivered))
+		assert.ErrorIs(t, repo.UpdateStatusByProviderID(c, "smsc", "8", domain.SMSDelivered), pgx.ErrNoRows)
+
+		message, err := repo.FindLatestByRecipient(c, "78889995566")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SMSDelivered, message.Status)
+
+		// a final status isn't changed by a late report
+		assert.NoError(t, repo.UpdateStatusByProviderID(c, "smsc", "7", domain.SMSFailed))
+		message, _ = repo.FindLatestByRecipient(c, "78889995566")
+		assert.Equal(t, domain.SMSDelivered, message.Status)
+	})
+
+	t.Run("dead", func(t *testing.T) {
+		var id int
+		db.QueryRow(c, "SELECT id FROM sms_outbox;").Scan(&id)
+
+		assert.NoError(t, repo.MarkDead(c, id, 3, "unavailable"))
+
+		var status string
+		db.QueryRow(c, "SELECT status FROM sms_outbox WHERE id = $1;", id).Scan(&status)
+		assert.Equal(t, "dead", status)
+	})
+
+	t.Run("remove finished", func(t *testing.T) {
+		// the message is kept until it has expired before the time
+		count, err := repo.RemoveFinished(c, time.Now(), 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(0), count)
+
+		count, err = repo.RemoveFinished(c, time.Now().Add(time.Hour), 10)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+}
@@ -0,0 +1,76 @@
+package email
+
+import (
+	"account/internal/domain"
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type repo struct {
+	db *pgxpool.Pool
+}
+
+func New(db *pgxpool.Pool) *repo {
+	return &repo{db: db}
+}
+
+// FindLatestByUser returns codes sent to the user after the timestamp, newest first
+func (r *repo) FindLatestByUser(c context.Context, userId int, timestamp time.Time) ([]domain.EmailCode, error) {
+	output := []domain.EmailCode{}
+
+	sql := "SELECT id, user_id, email, code_hash, salt, created_at, expires_at, attempts FROM email_codes WHERE user_id = $1 AND created_at > $2 ORDER BY created_at DESC;"
+	rows, err := r.db.Query(c, sql, userId, timestamp.UTC())
+	if err != nil {
+		return output, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c domain.EmailCode
+		if err := rows.Scan(&c.ID, &c.UserID, &c.Email, &c.Hash, &c.Salt, &c.CreatedAt, &c.ExpiresAt, &c.Attempts); err != nil {
+			return output, err
+		}
+		output = append(output, c)
+	}
+
+	return output, rows.Err()
+}
+
+// FindNewestByUser returns the last code sent to the user, older codes are no longer valid
+func (r *repo) FindNewestByUser(c context.Context, userId int) (*domain.EmailCode, error) {
+	var output domain.EmailCode
+
+	sql := "SELECT id, user_id, email, code_hash, salt, created_at, expires_at, attempts FROM email_codes WHERE user_id = $1 ORDER BY created_at DESC LIMIT 1;"
+	err := r.db.QueryRow(c, sql, userId).Scan(&output.ID, &output.UserID, &output.Email, &output.Hash, &output.Salt, &output.CreatedAt, &output.ExpiresAt, &output.Attempts)
+
+	return &output, err
+}
+
+func (r *repo) Save(c context.Context, code *domain.EmailCode) error {
+	sql := "INSERT INTO email_codes (user_id, email, code_hash, salt, created_at, expires_at) VALUES ($1, $2, $3, $4, $5, $6);"
+	_, err := r.db.Exec(c, sql, code.UserID, code.Email, code.Hash, code.Salt, code.CreatedAt.UTC(), code.ExpiresAt.UTC())
+	return err
+}
+
+// AddAttempt counts a verification attempt of the code,
+// it returns pgx.ErrNoRows if the code already has maxAttempts attempts
+func (r *repo) AddAttempt(c context.Context, id, maxAttempts int) error {
+	var attempts int
+	sql := "UPDATE email_codes SET attempts = attempts + 1 WHERE id = $1 AND attempts < $2 RETURNING attempts;"
+	return r.db.QueryRow(c, sql, id, maxAttempts).Scan(&attempts)
+}
+
+func (r *repo) RemoveAll(c context.Context, userId int) error {
+	sql := "DELETE FROM email_codes WHERE user_id = $1;"
+	_, err := r.db.Exec(c, sql, userId)
+	return err
+}
+
+// RemoveExpired removes up to limit codes which expired before the time
+func (r *repo) RemoveExpired(c context.Context, before time.Time, limit int) (int64, error) {
+	sql := "DELETE FROM email_codes WHERE id IN (SELECT id FROM email_codes WHERE expires_at < $1 LIMIT $2);"
+	tag, err := r.db.Exec(c, sql, before.UTC(), limit)
+	return tag.RowsAffected(), err
+}
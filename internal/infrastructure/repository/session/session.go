@@ -1,21 +1,470 @@
 package session
 
 import (
+	"account/internal/domain"
+	"account/internal/infrastructure/cache"
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
+// revokedChannel is notified with the access token hash of every deleted session,
+// of every session whose access token was replaced, see migrations/0006_up.sql,
+// and of sessions whose user changed, an empty payload means a role changed, see migrations/0022_up.sql
+const revokedChannel = "session_revoked"
+
 type repo struct {
-	db *pgxpool.Pool
+	db    *pgxpool.Pool
+	cache *cache.Cache[string, domain.Session]
+}
+
+// New creates the session repository, sessions found by access token are kept
+// in cache if it's not nil. Listen must be running to keep the cache in sync
+// with other instances.
+func New(db *pgxpool.Pool, cache *cache.Cache[string, domain.Session]) *repo {
+	return &repo{db: db, cache: cache}
+}
+
+// NextID reserves an id for a new session, so it can be put into the token before the session is stored
+func (r *repo) NextID(c context.Context) (int, error) {
+	var id int
+	sql := "SELECT nextval(pg_get_serial_sequence('sessions', 'id'));"
+	err := r.db.QueryRow(c, sql).Scan(&id)
+	return id, err
+}
+
+// Create stores the session with id from NextID together with its first refresh token.
+// With limit above 0 the user may have at most limit sessions: the least recently used ones
+// are revoked and returned if evict is set, otherwise domain.ErrTooManySessions is returned.
+// Sessions of staff acting as the user aren't counted. The user row is locked while sessions
+// are counted, so concurrent logins can't exceed the limit.
+func (r *repo) Create(c context.Context, session *domain.Session, refreshTokenHash string, limit int, evict bool) ([]domain.Session, error) {
+	tx, err := r.db.Begin(c)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(c)
+
+	var evicted []domain.Session
+	if limit > 0 {
+		if evicted, err = r.enforceLimit(c, tx, session.UserID, limit, evict); err != nil {
+			return nil, err
+		}
+	}
+
+	sql := `
+	INSERT INTO sessions (id, user_id, access_token_hash, access_expires_at, ip, user_agent, platform, device_name)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8);
+	`
+	_, err = tx.Exec(c, sql,
+		session.ID, session.UserID, session.AccessTokenHash, session.AccessExpiresAt.UTC(),
+		session.Device.IP, session.Device.UserAgent, session.Device.Platform, session.Device.Name,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	sql = "INSERT INTO refresh_tokens (token_hash, session_id) VALUES ($1, $2);"
+	if _, err := tx.Exec(c, sql, refreshTokenHash, session.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(c); err != nil {
+		return nil, err
+	}
+
+	for _, session := range evicted {
+		r.forget(session.AccessTokenHash)
+	}
+	return evicted, nil
+}
+
+// enforceLimit makes room for one more session of the user within the transaction
+func (r *repo) enforceLimit(c context.Context, tx pgx.Tx, userId, limit int, evict bool) ([]domain.Session, error) {
+	// concurrent logins of the user wait here until this one is committed
+	if _, err := tx.Exec(c, "SELECT id FROM users WHERE id = $1 FOR UPDATE;", userId); err != nil {
+		return nil, err
+	}
+
+	sql := "SELECT " + sessionColumns + " FROM sessions WHERE user_id = $1 AND impersonator_id IS NULL ORDER BY last_seen_at DESC;"
+	rows, err := tx.Query(c, sql, userId)
+	if err != nil {
+		return nil, err
+	}
+	sessions, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (domain.Session, error) {
+		session, err := r.scan(row)
+		return *session, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sessions) < limit {
+		return nil, nil
+	}
+	if !evict {
+		return nil, domain.ErrTooManySessions
+	}
+
+	evicted := sessions[limit-1:]
+	ids := make([]int, len(evicted))
+	for i, session := range evicted {
+		ids[i] = session.ID
+	}
+
+	sql = revokeQuery("id = ANY($1)", 2)
+	if _, err := tx.Exec(c, sql, ids, domain.RevokedByLimit); err != nil {
+		return nil, err
+	}
+
+	return evicted, nil
+}
+
+// CreateImpersonated stores the session with id from NextID started by a staff member
+// and records it in the audit log, such sessions have no refresh token
+func (r *repo) CreateImpersonated(c context.Context, session *domain.Session) error {
+	tx, err := r.db.Begin(c)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(c)
+
+	sql := `
+	INSERT INTO sessions (id, user_id, access_token_hash, access_expires_at, ip, user_agent, platform, device_name, impersonator_id, impersonation_reason)
+	VALUES($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
+	`
+	_, err = tx.Exec(c, sql,
+		session.ID, session.UserID, session.AccessTokenHash, session.AccessExpiresAt.UTC(),
+		session.Device.IP, session.Device.UserAgent, session.Device.Platform, session.Device.Name,
+		session.ImpersonatorID, session.ImpersonationReason,
+	)
+	if err != nil {
+		return err
+	}
+
+	sql = "INSERT INTO impersonations (session_id, staff_id, target_user_id, reason, ip) VALUES ($1, $2, $3, $4, $5);"
+	_, err = tx.Exec(c, sql, session.ID, session.ImpersonatorID, session.UserID, session.ImpersonationReason, session.Device.IP)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(c)
 }
 
-func New(db *pgxpool.Pool) *repo {
-	return &repo{db: db}
+// sessionColumns also selects the current username and role of the session user, so tokens
+// issued on refresh carry the actual role and cached sessions carry the user.
+// Cached sessions are dropped when the user or the role changes, see migrations/0022_up.sql
+const sessionColumns = `
+	id, access_token_hash, access_expires_at, user_id,
+	COALESCE((SELECT username FROM users WHERE id = user_id), '') as username,
+	COALESCE((SELECT role_id FROM users WHERE id = user_id), 0) as role_id,
+	COALESCE((SELECT name FROM roles WHERE id = (SELECT role_id FROM users WHERE id = user_id)), '') as role_name,
+	COALESCE((SELECT permissions FROM roles WHERE id = (SELECT role_id FROM users WHERE id = user_id)), '{}') as role_permissions,
+	ip, user_agent, platform, device_name, created_at, last_seen_at,
+	COALESCE(impersonator_id, 0), impersonation_reason
+`
+
+func (r *repo) FindOneByAccessToken(c context.Context, accessTokenHash string) (*domain.Session, error) {
+	if r.cache == nil {
+		return r.findOneByAccessToken(c, accessTokenHash)
+	}
+
+	if session, ok := r.cache.Get(accessTokenHash); ok {
+		return &session, nil
+	}
+
+	generation := r.cache.Generation()
+
+	session, err := r.findOneByAccessToken(c, accessTokenHash)
+	if err != nil {
+		return session, err
+	}
+
+	r.cache.SetIfCurrent(accessTokenHash, *session, generation)
+
+	return session, nil
+}
+
+func (r *repo) findOneByAccessToken(c context.Context, accessTokenHash string) (*domain.Session, error) {
+	sql := "SELECT " + sessionColumns + " FROM sessions WHERE access_token_hash = $1;"
+	return r.scan(r.db.QueryRow(c, sql, accessTokenHash))
+}
+
+// forget removes sessions from the local cache right away,
+// other instances are notified by the database trigger
+func (r *repo) forget(accessTokenHashes ...string) {
+	if r.cache == nil {
+		return
+	}
+	for _, hash := range accessTokenHashes {
+		r.cache.Delete(hash)
+	}
+}
+
+// Listen keeps the cache in sync with sessions revoked by any instance until c is done.
+// Notifications sent while not listening are lost, so the cache is cleared
+// when listening starts and when it fails.
+func (r *repo) Listen(c context.Context) error {
+	conn, err := r.db.Acquire(c)
+	if err != nil {
+		return err
+	}
+
+	// the listening connection is closed instead of being returned to the pool
+	pgConn := conn.Hijack()
+	defer pgConn.Close(context.Background())
+
+	if _, err := pgConn.Exec(c, "LISTEN "+revokedChannel+";"); err != nil {
+		return err
+	}
+
+	r.cache.Purge()
+	defer r.cache.Purge()
+
+	for {
+		notification, err := pgConn.WaitForNotification(c)
+		if err != nil {
+			return err
+		}
+		if notification.Payload == "" {
+			// a role changed, it may be cached with any session
+			r.cache.Purge()
+			continue
+		}
+		r.cache.Delete(notification.Payload)
+	}
+}
+
+// CacheStats returns usage of the session cache
+func (r *repo) CacheStats() cache.Stats {
+	if r.cache == nil {
+		return cache.Stats{}
+	}
+	return r.cache.Stats()
+}
+
+func (r *repo) FindOneByID(c context.Context, id int) (*domain.Session, error) {
+	sql := "SELECT " + sessionColumns + " FROM sessions WHERE id = $1;"
+	return r.scan(r.db.QueryRow(c, sql, id))
+}
+
+func (r *repo) FindAllByUserID(c context.Context, userId int) ([]domain.Session, error) {
+	output := []domain.Session{}
+
+	sql := "SELECT " + sessionColumns + " FROM sessions WHERE user_id = $1 ORDER BY last_seen_at DESC;"
+	rows, err := r.db.Query(c, sql, userId)
+	if err != nil {
+		return output, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		session, err := r.scan(rows)
+		if err != nil {
+			return output, err
+		}
+		output = append(output, *session)
+	}
+
+	return output, rows.Err()
+}
+
+func (r *repo) scan(row pgx.Row) (*domain.Session, error) {
+	var output domain.Session
+	err := row.Scan(
+		&output.ID, &output.AccessTokenHash, &output.AccessExpiresAt, &output.UserID,
+		&output.Username, &output.Role.ID, &output.Role.Name, &output.Role.Permissions,
+		&output.Device.IP, &output.Device.UserAgent, &output.Device.Platform, &output.Device.Name,
+		&output.CreatedAt, &output.LastSeenAt,
+		&output.ImpersonatorID, &output.ImpersonationReason,
+	)
+	return &output, err
+}
+
+func (r *repo) FindRefreshToken(c context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	var output domain.RefreshToken
+
+	sql := "SELECT token_hash, session_id, used_at, created_at FROM refresh_tokens WHERE token_hash = $1;"
+	err := r.db.QueryRow(c, sql, tokenHash).Scan(&output.TokenHash, &output.SessionID, &output.UsedAt, &output.CreatedAt)
+
+	return &output, err
+}
+
+// Rotate marks the used refresh token as used, replaces the access token of the session
+// and issues a new refresh token in its place. It returns pgx.ErrNoRows if the used token
+// was already used, e.g. by a concurrent request.
+func (r *repo) Rotate(c context.Context, session *domain.Session, usedRefreshTokenHash, refreshTokenHash string) error {
+	tx, err := r.db.Begin(c)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(c)
+
+	var sessionId int
+	sql := "UPDATE refresh_tokens SET used_at = $2 WHERE token_hash = $1 AND used_at IS NULL RETURNING session_id;"
+	if err := tx.QueryRow(c, sql, usedRefreshTokenHash, session.LastSeenAt.UTC()).Scan(&sessionId); err != nil {
+		return err
+	}
+
+	var replacedAccessTokenHash string
+	sql = `
+	UPDATE sessions s SET access_token_hash = $2, access_expires_at = $3, last_seen_at = $4
+	FROM sessions old WHERE s.id = $1 AND old.id = s.id
+	RETURNING old.access_token_hash;
+	`
+	err = tx.QueryRow(c, sql, session.ID, session.AccessTokenHash, session.AccessExpiresAt.UTC(), session.LastSeenAt.UTC()).Scan(&replacedAccessTokenHash)
+	if err != nil {
+		return err
+	}
+
+	sql = "INSERT INTO refresh_tokens (token_hash, session_id) VALUES ($1, $2);"
+	if _, err := tx.Exec(c, sql, refreshTokenHash, session.ID); err != nil {
+		return err
+	}
+
+	sql = "INSERT INTO session_revocations (session_id, user_id, reason) VALUES ($1, $2, $3);"
+	if _, err := tx.Exec(c, sql, session.ID, session.UserID, domain.RevokedByRotation); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(c); err != nil {
+		return err
+	}
+
+	r.forget(replacedAccessTokenHash)
+	return nil
+}
+
+// Touch updates last_seen_at of the session. The update doesn't notify other instances,
+// so they keep a cached session with the previous last_seen_at for up to the cache ttl,
+// which may make them touch it once more. Only the local cache entry is dropped.
+func (r *repo) Touch(c context.Context, accessTokenHash string, lastSeenAt time.Time) error {
+	sql := "UPDATE sessions SET last_seen_at = $2 WHERE access_token_hash = $1;"
+	_, err := r.db.Exec(c, sql, accessTokenHash, lastSeenAt.UTC())
+	if err != nil {
+		return err
+	}
+
+	// the cached session has the previous last_seen_at
+	r.forget(accessTokenHash)
+	return nil
+}
+
+// revokeQuery deletes sessions matching the condition and writes them to the revocation feed
+// with reason from the last query argument, it returns access token hashes of removed sessions
+func revokeQuery(condition string, reasonArg int) string {
+	return fmt.Sprintf(`
+	WITH removed AS (
+		DELETE FROM sessions WHERE %s RETURNING id, user_id, access_token_hash
+	), revoked AS (
+		INSERT INTO session_revocations (session_id, user_id, reason) SELECT id, user_id, $%d FROM removed
+	)
+	SELECT access_token_hash FROM removed;
+	`, condition, reasonArg)
+}
+
+func (r *repo) Remove(c context.Context, accessTokenHash string, reason domain.RevocationReason) error {
+	var removed string
+	sql := revokeQuery("access_token_hash = $1", 2)
+	if err := r.db.QueryRow(c, sql, accessTokenHash, reason).Scan(&removed); err != nil {
+		return err
+	}
+
+	r.forget(removed)
+	return nil
+}
+
+func (r *repo) RemoveByID(c context.Context, id int, reason domain.RevocationReason) error {
+	sql := revokeQuery("id = $1", 2)
+	_, err := r.removeMany(c, sql, id, reason)
+	return err
+}
+
+func (r *repo) RemoveOneByUserID(c context.Context, userId, id int, reason domain.RevocationReason) error {
+	var removed string
+	sql := revokeQuery("id = $1 AND user_id = $2", 3)
+	if err := r.db.QueryRow(c, sql, id, userId, reason).Scan(&removed); err != nil {
+		return err
+	}
+
+	r.forget(removed)
+	return nil
+}
+
+func (r *repo) RemoveAllByUserID(c context.Context, userId int, exceptAccessTokenHash string, reason domain.RevocationReason) error {
+	sql := revokeQuery("user_id = $1 AND access_token_hash <> $2", 3)
+	_, err := r.removeMany(c, sql, userId, exceptAccessTokenHash, reason)
+	return err
+}
+
+// removeMany runs a revokeQuery and forgets removed sessions, it returns how many were removed
+func (r *repo) removeMany(c context.Context, sql string, args ...any) (int64, error) {
+	rows, err := r.db.Query(c, sql, args...)
+	if err != nil {
+		return 0, err
+	}
+
+	hashes, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, err
+	}
+
+	r.forget(hashes...)
+	return int64(len(hashes)), nil
+}
+
+func (r *repo) RemoveExpired(c context.Context, createdBefore, lastSeenBefore time.Time) (int64, error) {
+	sql := revokeQuery("created_at < $1 OR last_seen_at < $2", 3)
+	return r.removeMany(c, sql, createdBefore.UTC(), lastSeenBefore.UTC(), domain.RevokedByExpiry)
+}
+
+// CurrentRevocationCursor returns the position in the revocation feed after
+// every revocation that is already visible
+func (r *repo) CurrentRevocationCursor(c context.Context) (domain.RevocationCursor, error) {
+	var output domain.RevocationCursor
+	sql := "SELECT pg_snapshot_xmin(pg_current_snapshot())::text::bigint;"
+	err := r.db.QueryRow(c, sql).Scan(&output.TxID)
+	return output, err
+}
+
+// FindRevocationsAfter returns up to limit revocations after the cursor. Only revocations
+// written by transactions older than any running one are returned, so a revocation
+// that isn't committed yet can't end up before the cursor of a reader.
+func (r *repo) FindRevocationsAfter(c context.Context, cursor domain.RevocationCursor, limit int) ([]domain.Revocation, error) {
+	output := []domain.Revocation{}
+
+	sql := `
+	SELECT txid::text::bigint, id, session_id, user_id, reason, created_at FROM session_revocations
+	WHERE (txid, id) > ($1::bigint::text::xid8, $2) AND txid < pg_snapshot_xmin(pg_current_snapshot())
+	ORDER BY txid, id LIMIT $3;
+	`
+	rows, err := r.db.Query(c, sql, cursor.TxID, cursor.ID, limit)
+	if err != nil {
+		return output, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var revocation domain.Revocation
+		err := rows.Scan(
+			&revocation.Cursor.TxID, &revocation.Cursor.ID,
+			&revocation.SessionID, &revocation.UserID, &revocation.Reason, &revocation.CreatedAt,
+		)
+		if err != nil {
+			return output, err
+		}
+		output = append(output, revocation)
+	}
+
+	return output, rows.Err()
 }
 
-func (r *repo) Create(c context.Context, userId int, accessToken string) error {
-	sql := "INSERT INTO sessions (user_id, access_token) VALUES($1, $2);"
-	_, err := r.db.Exec(c, sql, userId, accessToken)
+// RemoveRevocations removes revocations created before the time
+func (r *repo) RemoveRevocations(c context.Context, createdBefore time.Time) error {
+	sql := "DELETE FROM session_revocations WHERE created_at < $1;"
+	_, err := r.db.Exec(c, sql, createdBefore.UTC())
 	return err
 }
@@ -1,9 +1,14 @@
 package session
 
 import (
+	"account/internal/domain"
+	"account/internal/infrastructure/cache"
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/assert"
 )
@@ -16,7 +21,7 @@ func setup(t *testing.T) (context.Context, *pgxpool.Pool) {
 		t.Fatal(err)
 	}
 
-	db.Exec(c, "TRUNCATE TABLE sessions;")
+	db.Exec(c, "TRUNCATE TABLE sessions, session_revocations CASCADE;")
 
 	return c, db
 }
@@ -25,8 +30,489 @@ func TestCreate(t *testing.T) {
 	c, db := setup(t)
 
 	t.Run("success", func(t *testing.T) {
-		repo := New(db)
-		err := repo.Create(c, 1, "random token")
+		repo := New(db, nil)
+
+		sessionId, err := repo.NextID(c)
+		assert.NoError(t, err)
+
+		session := &domain.Session{
+			ID:              sessionId,
+			UserID:          1,
+			AccessTokenHash: "random token",
+			AccessExpiresAt: time.Now().Add(time.Minute * 15),
+			Device:          domain.NewDevice("127.0.0.1", "Mozilla/5.0", "web", "Firefox"),
+		}
+		_, err = repo.Create(c, session, "refresh token", 0, false)
+		assert.NoError(t, err)
+
+		stored, err := repo.FindOneByID(c, sessionId)
+		assert.NoError(t, err)
+		assert.Equal(t, session.Device, stored.Device)
+	})
+}
+
+func TestCreateWithinLimit(t *testing.T) {
+	c, db := setup(t)
+	repo := New(db, nil)
+
+	var userId int
+	db.Exec(c, "DELETE FROM users WHERE phone = '7770001122';")
+	err := db.QueryRow(c, "INSERT INTO users (username, phone, password) VALUES('limited', '7770001122', '12345678') RETURNING id").Scan(&userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	create := func(token string, limit int, evict bool) ([]domain.Session, error) {
+		sessionId, err := repo.NextID(c)
+		if err != nil {
+			return nil, err
+		}
+		session := &domain.Session{ID: sessionId, UserID: userId, AccessTokenHash: token, AccessExpiresAt: time.Now().Add(time.Minute * 15)}
+		return repo.Create(c, session, token+" refresh", limit, evict)
+	}
+
+	t.Run("evict oldest", func(t *testing.T) {
+		_, err := create("first", 2, true)
+		assert.NoError(t, err)
+		db.Exec(c, "UPDATE sessions SET last_seen_at = NOW() - INTERVAL '1 hour' WHERE access_token_hash = 'first';")
+		_, err = create("second", 2, true)
+		assert.NoError(t, err)
+
+		evicted, err := create("third", 2, true)
+		assert.NoError(t, err)
+		if assert.Len(t, evicted, 1) {
+			assert.Equal(t, "first", evicted[0].AccessTokenHash)
+		}
+
+		sessions, _ := repo.FindAllByUserID(c, userId)
+		assert.Len(t, sessions, 2)
+	})
+
+	t.Run("refuse", func(t *testing.T) {
+		_, err := create("fourth", 2, false)
+		assert.ErrorIs(t, err, domain.ErrTooManySessions)
+	})
+
+	t.Run("impersonated sessions are not counted", func(t *testing.T) {
+		db.Exec(c, "UPDATE sessions SET impersonator_id = $1 WHERE user_id = $1;", userId)
+
+		_, err := create("fifth", 1, false)
+		assert.NoError(t, err)
+	})
+
+	t.Run("concurrent logins", func(t *testing.T) {
+		db.Exec(c, "DELETE FROM sessions WHERE user_id = $1;", userId)
+
+		errs := make(chan error, 5)
+		for i := range 5 {
+			go func() {
+				_, err := create(fmt.Sprintf("concurrent %d", i), 1, false)
+				errs <- err
+			}()
+		}
+
+		var created int
+		for range 5 {
+			if err := <-errs; err == nil {
+				created++
+			} else {
+				assert.ErrorIs(t, err, domain.ErrTooManySessions)
+			}
+		}
+		assert.Equal(t, 1, created)
+	})
+}
+
+func TestCreateImpersonated(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, nil)
+
+		sessionId, err := repo.NextID(c)
+		assert.NoError(t, err)
+
+		session := &domain.Session{
+			ID:                  sessionId,
+			UserID:              1,
+			AccessTokenHash:     "impersonated token",
+			AccessExpiresAt:     time.Now().Add(time.Minute * 30),
+			ImpersonatorID:      2,
+			ImpersonationReason: "report #42",
+		}
+		err = repo.CreateImpersonated(c, session)
+		assert.NoError(t, err)
+
+		stored, err := repo.FindOneByID(c, sessionId)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, stored.ImpersonatorID)
+		assert.Equal(t, "report #42", stored.ImpersonationReason)
+
+		var audited int
+		db.QueryRow(c, "SELECT count(*) FROM impersonations WHERE session_id = $1 AND staff_id = 2;", sessionId).Scan(&audited)
+		assert.Equal(t, 1, audited)
+	})
+}
+
+func TestFindAllByUserID(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, nil)
+
+		db.Exec(c, "INSERT INTO sessions (user_id, access_token_hash) VALUES (1, 'first'), (1, 'second'), (2, 'third');")
+
+		sessions, err := repo.FindAllByUserID(c, 1)
+
+		assert.NoError(t, err)
+		assert.Len(t, sessions, 2)
+	})
+}
+
+func TestFindOneByID(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, nil)
+
+		var sessionId int
+		db.QueryRow(c, "INSERT INTO sessions (user_id, access_token_hash) VALUES (1, 'random token') RETURNING id;").Scan(&sessionId)
+
+		session, err := repo.FindOneByID(c, sessionId)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "random token", session.AccessTokenHash)
+	})
+}
+
+func TestFindRefreshToken(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, nil)
+
+		sessionId, _ := repo.NextID(c)
+		session := &domain.Session{ID: sessionId, UserID: 1, AccessTokenHash: "random token", AccessExpiresAt: time.Now().Add(time.Minute * 15)}
+		_, err := repo.Create(c, session, "refresh token", 0, false)
+		assert.NoError(t, err)
+
+		token, err := repo.FindRefreshToken(c, "refresh token")
+
+		assert.NoError(t, err)
+		assert.Equal(t, sessionId, token.SessionID)
+		assert.False(t, token.IsUsed())
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		repo := New(db, nil)
+
+		_, err := repo.FindRefreshToken(c, "wrong token")
+
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+	})
+}
+
+func TestRotate(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, nil)
+
+		sessionId, _ := repo.NextID(c)
+		session := &domain.Session{ID: sessionId, UserID: 1, AccessTokenHash: "first access", AccessExpiresAt: time.Now().Add(time.Minute * 15)}
+		_, err := repo.Create(c, session, "first refresh", 0, false)
+		assert.NoError(t, err)
+
+		now := time.Now().UTC()
+		session = &domain.Session{ID: sessionId, AccessTokenHash: "second access", AccessExpiresAt: now.Add(time.Minute * 15), LastSeenAt: now}
+		err = repo.Rotate(c, session, "first refresh", "second refresh")
+		assert.NoError(t, err)
+
+		used, err := repo.FindRefreshToken(c, "first refresh")
+		assert.NoError(t, err)
+		assert.True(t, used.IsUsed())
+
+		_, err = repo.FindOneByAccessToken(c, "second access")
+		assert.NoError(t, err)
+
+		// the same refresh token can't be used twice
+		err = repo.Rotate(c, session, "first refresh", "third refresh")
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+	})
+}
+
+func TestFindOneByAccessToken(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, nil)
+
+		db.Exec(c, "INSERT INTO sessions (user_id, access_token_hash) VALUES (1, 'random token');")
+
+		session, err := repo.FindOneByAccessToken(c, "random token")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, session.UserID)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		repo := New(db, nil)
+
+		_, err := repo.FindOneByAccessToken(c, "wrong token")
+
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+	})
+
+	t.Run("cached", func(t *testing.T) {
+		repo := New(db, cache.New[string, domain.Session](10, time.Minute))
+
+		db.Exec(c, "INSERT INTO sessions (user_id, access_token_hash) VALUES (1, 'cached token');")
+
+		_, err := repo.FindOneByAccessToken(c, "cached token")
 		assert.NoError(t, err)
+		_, err = repo.FindOneByAccessToken(c, "cached token")
+		assert.NoError(t, err)
+
+		stats := repo.CacheStats()
+		assert.Equal(t, uint64(1), stats.Hits)
+		assert.Equal(t, uint64(1), stats.Misses)
+
+		err = repo.Remove(c, "cached token", domain.RevokedByLogout)
+		assert.NoError(t, err)
+
+		_, err = repo.FindOneByAccessToken(c, "cached token")
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+	})
+}
+
+func TestListen(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("revoked by another instance", func(t *testing.T) {
+		repo := New(db, cache.New[string, domain.Session](10, time.Minute))
+
+		listenCtx, stop := context.WithCancel(c)
+		defer stop()
+		go repo.Listen(listenCtx)
+		time.Sleep(time.Millisecond * 100)
+
+		db.Exec(c, "INSERT INTO sessions (user_id, access_token_hash) VALUES (1, 'listened token');")
+
+		_, err := repo.FindOneByAccessToken(c, "listened token")
+		assert.NoError(t, err)
+
+		// another instance doesn't share the cache
+		err = New(db, nil).Remove(c, "listened token", domain.RevokedByLogout)
+		assert.NoError(t, err)
+
+		time.Sleep(time.Millisecond * 100)
+
+		_, err = repo.FindOneByAccessToken(c, "listened token")
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+	})
+
+	t.Run("user changed", func(t *testing.T) {
+		repo := New(db, cache.New[string, domain.Session](10, time.Minute))
+
+		listenCtx, stop := context.WithCancel(c)
+		defer stop()
+		go repo.Listen(listenCtx)
+		time.Sleep(time.Millisecond * 100)
+
+		var userId int
+		db.Exec(c, "DELETE FROM users WHERE phone = '7770003344';")
+		err := db.QueryRow(c, "INSERT INTO users (username, phone, password) VALUES('cached', '7770003344', '12345678') RETURNING id").Scan(&userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db.Exec(c, "INSERT INTO sessions (user_id, access_token_hash) VALUES ($1, 'cached user token');", userId)
+
+		session, err := repo.FindOneByAccessToken(c, "cached user token")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "cached", session.Username)
+			assert.Equal(t, domain.UserRole, session.Role.ID)
+		}
+
+		db.Exec(c, "UPDATE users SET username = 'renamed', role_id = $2 WHERE id = $1;", userId, domain.ModeratorRole)
+		time.Sleep(time.Millisecond * 100)
+
+		session, err = repo.FindOneByAccessToken(c, "cached user token")
+		if assert.NoError(t, err) {
+			assert.Equal(t, "renamed", session.Username)
+			assert.Equal(t, domain.ModeratorRole, session.Role.ID)
+		}
+	})
+}
+
+func TestRemove(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, nil)
+
+		db.Exec(c, "INSERT INTO sessions (user_id, access_token_hash) VALUES (1, 'random token');")
+
+		err := repo.Remove(c, "random token", domain.RevokedByLogout)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		repo := New(db, nil)
+
+		err := repo.Remove(c, "wrong token", domain.RevokedByLogout)
+
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+	})
+}
+
+func TestRemoveByID(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, nil)
+
+		sessionId, _ := repo.NextID(c)
+		session := &domain.Session{ID: sessionId, UserID: 1, AccessTokenHash: "random token", AccessExpiresAt: time.Now().Add(time.Minute * 15)}
+		_, err := repo.Create(c, session, "refresh token", 0, false)
+		assert.NoError(t, err)
+
+		err = repo.RemoveByID(c, sessionId, domain.RevokedByTokenReuse)
+		assert.NoError(t, err)
+
+		_, err = repo.FindRefreshToken(c, "refresh token")
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+	})
+}
+
+func TestRemoveOneByUserID(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, nil)
+
+		var sessionId int
+		db.QueryRow(c, "INSERT INTO sessions (user_id, access_token_hash) VALUES (1, 'random token') RETURNING id;").Scan(&sessionId)
+
+		err := repo.RemoveOneByUserID(c, 1, sessionId, domain.RevokedByUser)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("someone else's session", func(t *testing.T) {
+		repo := New(db, nil)
+
+		var sessionId int
+		db.QueryRow(c, "INSERT INTO sessions (user_id, access_token_hash) VALUES (2, 'other token') RETURNING id;").Scan(&sessionId)
+
+		err := repo.RemoveOneByUserID(c, 1, sessionId, domain.RevokedByUser)
+
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+	})
+}
+
+func TestRemoveAllByUserID(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, nil)
+
+		db.Exec(c, "INSERT INTO sessions (user_id, access_token_hash) VALUES (1, 'first'), (1, 'second'), (2, 'third');")
+
+		err := repo.RemoveAllByUserID(c, 1, "second", domain.RevokedByLogoutAll)
+		assert.NoError(t, err)
+
+		_, err = repo.FindOneByAccessToken(c, "first")
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+
+		_, err = repo.FindOneByAccessToken(c, "second")
+		assert.NoError(t, err)
+
+		_, err = repo.FindOneByAccessToken(c, "third")
+		assert.NoError(t, err)
+	})
+}
+
+func TestTouch(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, nil)
+
+		db.Exec(c, "INSERT INTO sessions (user_id, access_token_hash) VALUES (1, 'random token');")
+
+		lastSeenAt := time.Now().UTC().Add(time.Hour).Truncate(time.Second)
+		err := repo.Touch(c, "random token", lastSeenAt)
+		assert.NoError(t, err)
+
+		session, err := repo.FindOneByAccessToken(c, "random token")
+		assert.NoError(t, err)
+		assert.True(t, lastSeenAt.Equal(session.LastSeenAt))
+	})
+}
+
+func TestRemoveExpired(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, nil)
+
+		db.Exec(c, "INSERT INTO sessions (user_id, access_token_hash, created_at) VALUES (1, 'old', NOW() - INTERVAL '60 days');")
+		db.Exec(c, "INSERT INTO sessions (user_id, access_token_hash, last_seen_at) VALUES (1, 'idle', NOW() - INTERVAL '10 days');")
+		db.Exec(c, "INSERT INTO sessions (user_id, access_token_hash) VALUES (1, 'active');")
+
+		now := time.Now().UTC()
+		count, err := repo.RemoveExpired(c, now.Add(-time.Hour*24*30), now.Add(-time.Hour*24*7))
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), count)
+	})
+}
+
+func TestFindRevocationsAfter(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, nil)
+
+		cursor, err := repo.CurrentRevocationCursor(c)
+		assert.NoError(t, err)
+
+		db.Exec(c, "INSERT INTO sessions (user_id, access_token_hash) VALUES (1, 'first'), (1, 'second');")
+
+		err = repo.Remove(c, "first", domain.RevokedByLogout)
+		assert.NoError(t, err)
+		err = repo.RemoveAllByUserID(c, 1, "", domain.RevokedByLogoutAll)
+		assert.NoError(t, err)
+
+		revocations, err := repo.FindRevocationsAfter(c, cursor, 10)
+		assert.NoError(t, err)
+		if !assert.Len(t, revocations, 2) {
+			return
+		}
+		assert.Equal(t, domain.RevokedByLogout, revocations[0].Reason)
+		assert.Equal(t, domain.RevokedByLogoutAll, revocations[1].Reason)
+
+		revocations, err = repo.FindRevocationsAfter(c, revocations[0].Cursor, 10)
+		assert.NoError(t, err)
+		assert.Len(t, revocations, 1)
+	})
+}
+
+func TestRemoveRevocations(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db, nil)
+
+		db.Exec(c, "INSERT INTO session_revocations (session_id, user_id, reason, created_at) VALUES (1, 1, 'logout', NOW() - INTERVAL '2 days');")
+		db.Exec(c, "INSERT INTO session_revocations (session_id, user_id, reason) VALUES (2, 1, 'logout');")
+
+		err := repo.RemoveRevocations(c, time.Now().Add(-time.Hour*24))
+		assert.NoError(t, err)
+
+		var count int
+		db.QueryRow(c, "SELECT count(*) FROM session_revocations;").Scan(&count)
+		assert.Equal(t, 1, count)
 	})
 }
@@ -3,7 +3,9 @@ package user
 import (
 	"account/internal/domain"
 	"context"
+	"errors"
 
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -21,7 +23,7 @@ func (r *repo) FindOneByPhone(c context.Context, phone string) (*domain.User, er
 	var role domain.Role
 
 	sql := `
-	SELECT id, username, phone, password, photo, description, created_at, role_id,
+	SELECT id, username, phone, email, password, photo, description, locale, created_at, role_id,
 		(SELECT name FROM roles WHERE id = role_id) as role_name,
 		(SELECT permissions FROM roles WHERE id = role_id) as role_permissions
 	FROM users WHERE phone = $1
@@ -29,8 +31,35 @@ func (r *repo) FindOneByPhone(c context.Context, phone string) (*domain.User, er
 
 	row := r.db.QueryRow(c, sql, phone)
 	err := row.Scan(
-		&u.ID, &u.Username, &u.Phone, &u.Password,
-		&u.Photo, &u.Description, &u.CreatedAt,
+		&u.ID, &u.Username, &u.Phone, &u.Email, &u.Password,
+		&u.Photo, &u.Description, &u.Locale, &u.CreatedAt,
+		&role.ID, &role.Name, &role.Permissions,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Role = &role
+
+	return &u, nil
+}
+
+func (r *repo) FindOneByID(c context.Context, id int) (*domain.User, error) {
+	var u domain.User
+
+	var role domain.Role
+
+	sql := `
+	SELECT id, username, phone, email, password, photo, description, locale, created_at, role_id,
+		(SELECT name FROM roles WHERE id = role_id) as role_name,
+		(SELECT permissions FROM roles WHERE id = role_id) as role_permissions
+	FROM users WHERE id = $1
+	`
+
+	row := r.db.QueryRow(c, sql, id)
+	err := row.Scan(
+		&u.ID, &u.Username, &u.Phone, &u.Email, &u.Password,
+		&u.Photo, &u.Description, &u.Locale, &u.CreatedAt,
 		&role.ID, &role.Name, &role.Permissions,
 	)
 	if err != nil {
@@ -48,7 +77,7 @@ func (r *repo) FindOneByUsername(c context.Context, username string) (*domain.Us
 	var role domain.Role
 
 	sql := `
-	SELECT id, username, phone, password, photo, description, created_at, role_id,
+	SELECT id, username, phone, email, password, photo, description, locale, created_at, role_id,
 		(SELECT name FROM roles WHERE id = role_id) as role_name,
 		(SELECT permissions FROM roles WHERE id = role_id) as role_permissions
 	FROM users WHERE username = $1
@@ -56,8 +85,8 @@ func (r *repo) FindOneByUsername(c context.Context, username string) (*domain.Us
 
 	row := r.db.QueryRow(c, sql, username)
 	err := row.Scan(
-		&u.ID, &u.Username, &u.Phone, &u.Password,
-		&u.Photo, &u.Description, &u.CreatedAt,
+		&u.ID, &u.Username, &u.Phone, &u.Email, &u.Password,
+		&u.Photo, &u.Description, &u.Locale, &u.CreatedAt,
 		&role.ID, &role.Name, &role.Permissions,
 	)
 	if err != nil {
@@ -69,9 +98,53 @@ func (r *repo) FindOneByUsername(c context.Context, username string) (*domain.Us
 	return &u, nil
 }
 
+// FindOneByEmail finds the user with the verified email
+func (r *repo) FindOneByEmail(c context.Context, email string) (*domain.User, error) {
+	var u domain.User
+
+	var role domain.Role
+
+	sql := `
+	SELECT id, username, phone, email, password, photo, description, locale, created_at, role_id,
+		(SELECT name FROM roles WHERE id = role_id) as role_name,
+		(SELECT permissions FROM roles WHERE id = role_id) as role_permissions
+	FROM users WHERE email = $1
+	`
+
+	row := r.db.QueryRow(c, sql, email)
+	err := row.Scan(
+		&u.ID, &u.Username, &u.Phone, &u.Email, &u.Password,
+		&u.Photo, &u.Description, &u.Locale, &u.CreatedAt,
+		&role.ID, &role.Name, &role.Permissions,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Role = &role
+
+	return &u, nil
+}
+
+// SetEmail sets a verified email of the user,
+// it returns domain.ErrEmailAlreadyInUse if another user has the email
+func (r *repo) SetEmail(c context.Context, id int, email string) error {
+	sql := "UPDATE users SET email = $2 WHERE id = $1;"
+	_, err := r.db.Exec(c, sql, id, email)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == "users_email_idx" {
+		return domain.ErrEmailAlreadyInUse
+	}
+	return err
+}
+
+// uniqueViolation is the sqlstate of a unique index violation
+const uniqueViolation = "23505"
+
 func (r *repo) Create(c context.Context, user *domain.User) (int, error) {
 	var userId int
-	sql := "INSERT INTO users (username, phone, password) VALUES ($1,$2,$3) RETURNING id;"
-	err := r.db.QueryRow(c, sql, user.Username, user.Phone, user.Password).Scan(&userId)
+	sql := "INSERT INTO users (username, phone, password, locale) VALUES ($1,$2,$3,$4) RETURNING id;"
+	err := r.db.QueryRow(c, sql, user.Username, user.Phone, user.Password, user.Locale).Scan(&userId)
 	return userId, err
 }
@@ -47,6 +47,31 @@ func TestFindOneByPhone(t *testing.T) {
 	})
 }
 
+func TestFindOneByID(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db)
+
+		var userId int
+		err := db.QueryRow(c, "INSERT INTO users (username, phone, password) VALUES('doe', '7775556699', '12345678') RETURNING id").Scan(&userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		user, err := repo.FindOneByID(c, userId)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "doe", user.Username)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		repo := New(db)
+		_, err := repo.FindOneByID(c, -1)
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+	})
+}
+
 func TestFindOneByUsername(t *testing.T) {
 	c, db := setup(t)
 
@@ -77,9 +102,50 @@ func TestCreate(t *testing.T) {
 	t.Run("success", func(t *testing.T) {
 		repo := New(db)
 
-		userId, err := repo.Create(c, &domain.User{Username: "john", Phone: "7776668844", Password: "12345678"})
+		userId, err := repo.Create(c, &domain.User{Username: "john", Phone: "7776668844", Password: "12345678", Locale: domain.LocaleRussian})
 
 		assert.NoError(t, err)
 		assert.NotZero(t, userId)
 	})
 }
+
+func TestSetEmail(t *testing.T) {
+	c, db := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo := New(db)
+
+		var userId int
+		err := db.QueryRow(c, "INSERT INTO users (username, phone, password) VALUES('doe', '7775556699', '12345678') RETURNING id").Scan(&userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.NoError(t, repo.SetEmail(c, userId, "doe@example.com"))
+
+		user, err := repo.FindOneByEmail(c, "doe@example.com")
+		if assert.NoError(t, err) {
+			assert.Equal(t, userId, user.ID)
+			assert.Equal(t, "doe@example.com", *user.Email)
+		}
+	})
+
+	t.Run("email of another user", func(t *testing.T) {
+		repo := New(db)
+
+		var userId int
+		err := db.QueryRow(c, "INSERT INTO users (username, phone, password) VALUES('john', '7775556600', '12345678') RETURNING id").Scan(&userId)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = repo.SetEmail(c, userId, "doe@example.com")
+		assert.ErrorIs(t, err, domain.ErrEmailAlreadyInUse)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		repo := New(db)
+		_, err := repo.FindOneByEmail(c, "nobody@example.com")
+		assert.ErrorIs(t, err, pgx.ErrNoRows)
+	})
+}
@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type Job func(c context.Context)
+
+type scheduler struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func New() *scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &scheduler{ctx: ctx, cancel: cancel}
+}
+
+// Every runs the job in background once per interval until the scheduler is stopped
+func (s *scheduler) Every(interval time.Duration, job Job) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+				job(s.ctx)
+			}
+		}
+	}()
+}
+
+// Go runs a long-living job in background, the job must return when its context is done
+func (s *scheduler) Go(job Job) {
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+		job(s.ctx)
+	}()
+}
+
+// Stop cancels running jobs and waits until all of them return
+func (s *scheduler) Stop() {
+	s.cancel()
+	s.wg.Wait()
+}
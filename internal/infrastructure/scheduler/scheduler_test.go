@@ -0,0 +1,47 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvery(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		var runs atomic.Int32
+
+		scheduler := New()
+		scheduler.Every(time.Millisecond*10, func(c context.Context) {
+			runs.Add(1)
+		})
+
+		time.Sleep(time.Millisecond * 55)
+		scheduler.Stop()
+
+		stopped := runs.Load()
+		assert.GreaterOrEqual(t, stopped, int32(2))
+
+		time.Sleep(time.Millisecond * 30)
+		assert.Equal(t, stopped, runs.Load())
+	})
+}
+
+func TestGo(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		var stopped atomic.Bool
+
+		scheduler := New()
+		scheduler.Go(func(c context.Context) {
+			<-c.Done()
+			time.Sleep(time.Millisecond * 10)
+			stopped.Store(true)
+		})
+
+		scheduler.Stop()
+
+		assert.True(t, stopped.Load())
+	})
+}
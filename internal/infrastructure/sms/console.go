@@ -0,0 +1,45 @@
+package sms
+
+import (
+	"account/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// console writes messages instead of sending them, for local development
+type console struct {
+	mu     sync.Mutex
+	out    io.Writer
+	lastId int
+}
+
+func NewConsole(out io.Writer) *console {
+	return &console{out: out}
+}
+
+func (s *console) Send(c context.Context, message *domain.SMS) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := fmt.Fprintf(s.out, "%s sms to %s:\n%s\n\n", time.Now().Format(time.RFC3339), message.Recipient, message.Text); err != nil {
+		return "", err
+	}
+
+	s.lastId++
+	return strconv.Itoa(s.lastId), nil
+}
+
+// Status reports every written message as delivered
+func (s *console) Status(c context.Context, messageId, recipient string) (domain.SMSStatus, error) {
+	return domain.SMSDelivered, nil
+}
+
+func (s *console) ParseCallback(r *http.Request) ([]domain.SMSReport, error) {
+	return nil, errors.New("console sender has no callbacks")
+}
@@ -0,0 +1,132 @@
+package sms
+
+import (
+	"account/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// mobizon sends messages through mobizon.kz
+type mobizon struct {
+	baseUrl     string
+	accessToken string
+	client      *http.Client
+}
+
+func NewMobizon(baseUrl, accessToken string) *mobizon {
+	return &mobizon{baseUrl: baseUrl, accessToken: accessToken, client: http.DefaultClient}
+}
+
+func (m *mobizon) Send(c context.Context, message *domain.SMS) (string, error) {
+	data := url.Values{
+		"recipient": []string{message.Recipient},
+		"text":      []string{message.Text},
+	}
+
+	var res struct {
+		MessageID messageID `json:"messageId"`
+	}
+	if err := m.call(c, http.MethodPost, "/service/Message/SendSmsMessage", data, &res); err != nil {
+		return "", err
+	}
+
+	return string(res.MessageID), nil
+}
+
+func (m *mobizon) Status(c context.Context, messageId, recipient string) (domain.SMSStatus, error) {
+	data := url.Values{"ids[]": []string{messageId}}
+
+	var res []struct {
+		ID     messageID `json:"id"`
+		Status string    `json:"status"`
+	}
+	if err := m.call(c, http.MethodGet, "/service/Message/GetSMSStatus", data, &res); err != nil {
+		return "", err
+	}
+
+	for _, message := range res {
+		if string(message.ID) == messageId {
+			return mobizonStatus(message.Status), nil
+		}
+	}
+
+	return "", fmt.Errorf("message %s not found", messageId)
+}
+
+// ParseCallback reads a delivery report, mobizon passes it as query parameters
+func (m *mobizon) ParseCallback(r *http.Request) ([]domain.SMSReport, error) {
+	messageId := r.FormValue("messageId")
+	if messageId == "" {
+		return nil, errors.New("no message id in mobizon callback")
+	}
+	return []domain.SMSReport{{MessageID: messageId, Status: mobizonStatus(r.FormValue("status"))}}, nil
+}
+
+func mobizonStatus(status string) domain.SMSStatus {
+	switch status {
+	case "DELIVRD":
+		return domain.SMSDelivered
+	case "UNDELIV", "REJECTD", "EXPIRED", "DELETED":
+		return domain.SMSFailed
+	default:
+		return domain.SMSSent
+	}
+}
+
+func (m *mobizon) call(c context.Context, method, path string, data url.Values, output any) error {
+	path = fmt.Sprintf("%s%s?apiKey=%s", m.baseUrl, path, m.accessToken)
+
+	var body io.Reader
+	if method == http.MethodGet {
+		path += "&" + data.Encode()
+	} else {
+		body = strings.NewReader(data.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(c, method, path, body)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	resBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	var res struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(resBody, &res); err != nil {
+		return fmt.Errorf("failed to unmarshal json from body: %v\nbody:%v", err, string(resBody))
+	}
+
+	if res.Code != 0 {
+		return errors.New(res.Message)
+	}
+
+	if output == nil || len(res.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(res.Data, output); err != nil {
+		return fmt.Errorf("failed to unmarshal json from data: %v\ndata:%v", err, string(res.Data))
+	}
+
+	return nil
+}
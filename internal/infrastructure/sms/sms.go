@@ -0,0 +1,254 @@
+package sms
+
+import (
+	"account/internal/domain"
+	"account/internal/infrastructure/configuration"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+type Sender interface {
+	// Send returns the id of the message at the provider
+	Send(c context.Context, message *domain.SMS) (string, error)
+	Status(c context.Context, messageId, recipient string) (domain.SMSStatus, error)
+	// ParseCallback reads delivery reports posted by the provider
+	ParseCallback(r *http.Request) ([]domain.SMSReport, error)
+}
+
+// CallbackVerifier is a Sender which asks to confirm the callback url before posting reports
+type CallbackVerifier interface {
+	// VerifyCallback returns the response confirming the url
+	VerifyCallback(r *http.Request) (string, error)
+}
+
+type provider struct {
+	name   string
+	sender Sender
+}
+
+// messageID is an id which providers return either as a string or as a number
+type messageID string
+
+func (id *messageID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = messageID(s)
+		return nil
+	}
+
+	var n json.Number
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&n); err != nil {
+		return err
+	}
+	*id = messageID(n.String())
+	return nil
+}
+
+// failover sends a message with the first provider that accepts it
+type failover struct {
+	providers []provider
+	logger    *zap.Logger
+}
+
+// router sends a message through its channel and falls back to sms
+// when the channel is disabled or fails
+type router struct {
+	sms      *failover
+	channels map[domain.Channel]provider
+	logger   *zap.Logger
+}
+
+// New builds senders listed in cfg.Senders, they are tried in that order,
+// and senders of channels listed in cfg.Channels
+func New(cfg *configuration.SMSConfig, logger *zap.Logger) (*router, error) {
+	sms, err := newFailover(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	channels := make(map[domain.Channel]provider, len(cfg.Channels))
+	for _, name := range cfg.Channels {
+		var p provider
+		switch channel := domain.Channel(name); channel {
+		case domain.ChannelTelegram:
+			p = provider{name: "telegram", sender: NewTelegram(cfg.TelegramDomain, cfg.TelegramToken)}
+		case domain.ChannelWhatsApp:
+			p = provider{name: "whatsapp", sender: NewWhatsApp(cfg.WhatsAppDomain, cfg.WhatsAppToken, cfg.WhatsAppPhoneID, cfg.WhatsAppTemplate, cfg.WhatsAppVerifyToken, cfg.WhatsAppAppSecret)}
+		case domain.ChannelCall:
+			// the code is dialed as the last digits of the calling number
+			if cfg.CodeLength != 4 || strings.Trim(cfg.CodeAlphabet, "0123456789") != "" {
+				return nil, errors.New("flash calls need codes of 4 digits, check SMS_CODE_LENGTH and SMS_CODE_ALPHABET")
+			}
+			p = provider{name: "smsc_call", sender: NewSMSCCall(cfg.SMSCDomain, cfg.SMSCLogin, cfg.SMSCPassword)}
+		default:
+			return nil, fmt.Errorf("unknown code channel %q", name)
+		}
+		channels[domain.Channel(name)] = p
+	}
+
+	return &router{sms: sms, channels: channels, logger: logger}, nil
+}
+
+// Send sets the channel and the provider which accepted the message and the id of the message there
+func (r *router) Send(c context.Context, message *domain.SMS) error {
+	if message.Channel != domain.ChannelSMS {
+		if p, ok := r.channels[message.Channel]; ok {
+			messageId, err := p.sender.Send(c, message)
+			if err == nil {
+				message.Provider = p.name
+				message.ProviderMessageID = messageId
+				return nil
+			}
+
+			r.logger.Warn("code channel failed, falling back to sms", zap.String("channel", string(message.Channel)), zap.Error(err))
+			if c.Err() != nil {
+				return err
+			}
+		} else {
+			r.logger.Warn("code channel is disabled, falling back to sms", zap.String("channel", string(message.Channel)))
+		}
+	}
+
+	name, messageId, err := r.sms.Send(c, message)
+	if err != nil {
+		return err
+	}
+
+	message.Channel = domain.ChannelSMS
+	message.Provider = name
+	message.ProviderMessageID = messageId
+	return nil
+}
+
+func (r *router) Status(c context.Context, provider, messageId, recipient string) (domain.SMSStatus, error) {
+	if sender, ok := r.channel(provider); ok {
+		return sender.Status(c, messageId, recipient)
+	}
+	return r.sms.Status(c, provider, messageId, recipient)
+}
+
+func (r *router) ParseCallback(provider string, req *http.Request) ([]domain.SMSReport, error) {
+	if sender, ok := r.channel(provider); ok {
+		return sender.ParseCallback(req)
+	}
+	return r.sms.ParseCallback(provider, req)
+}
+
+func (r *router) VerifyCallback(provider string, req *http.Request) (string, error) {
+	if sender, ok := r.channel(provider); ok {
+		return verifyCallback(provider, sender, req)
+	}
+	return r.sms.VerifyCallback(provider, req)
+}
+
+func (r *router) channel(name string) (Sender, bool) {
+	for _, p := range r.channels {
+		if p.name == name {
+			return p.sender, true
+		}
+	}
+	return nil, false
+}
+
+func newFailover(cfg *configuration.SMSConfig, logger *zap.Logger) (*failover, error) {
+	if len(cfg.Senders) == 0 {
+		return nil, errors.New("no sms senders configured")
+	}
+
+	providers := make([]provider, 0, len(cfg.Senders))
+	for _, name := range cfg.Senders {
+		var sender Sender
+		switch name {
+		case "mobizon":
+			sender = NewMobizon(cfg.ApiDomain, cfg.ApiKey)
+		case "smsc":
+			sender = NewSMSC(cfg.SMSCDomain, cfg.SMSCLogin, cfg.SMSCPassword)
+		case "console":
+			var out io.Writer = os.Stdout
+			if cfg.ConsoleFile != "" {
+				file, err := os.OpenFile(cfg.ConsoleFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+				if err != nil {
+					return nil, err
+				}
+				out = file
+			}
+			sender = NewConsole(out)
+		default:
+			return nil, fmt.Errorf("unknown sms sender %q", name)
+		}
+		providers = append(providers, provider{name: name, sender: sender})
+	}
+
+	return &failover{providers: providers, logger: logger}, nil
+}
+
+// Send returns the name of the provider which accepted the message and the id of the message there
+func (f *failover) Send(c context.Context, message *domain.SMS) (string, string, error) {
+	var errs []error
+	for _, p := range f.providers {
+		messageId, err := p.sender.Send(c, message)
+		if err == nil {
+			return p.name, messageId, nil
+		}
+
+		f.logger.Warn("sms provider failed", zap.String("provider", p.name), zap.Error(err))
+		errs = append(errs, fmt.Errorf("%s: %w", p.name, err))
+
+		if c.Err() != nil {
+			break
+		}
+	}
+	return "", "", errors.Join(errs...)
+}
+
+func (f *failover) Status(c context.Context, provider, messageId, recipient string) (domain.SMSStatus, error) {
+	sender, err := f.provider(provider)
+	if err != nil {
+		return "", err
+	}
+	return sender.Status(c, messageId, recipient)
+}
+
+func (f *failover) ParseCallback(provider string, r *http.Request) ([]domain.SMSReport, error) {
+	sender, err := f.provider(provider)
+	if err != nil {
+		return nil, err
+	}
+	return sender.ParseCallback(r)
+}
+
+func (f *failover) VerifyCallback(provider string, r *http.Request) (string, error) {
+	sender, err := f.provider(provider)
+	if err != nil {
+		return "", err
+	}
+	return verifyCallback(provider, sender, r)
+}
+
+func verifyCallback(provider string, sender Sender, r *http.Request) (string, error) {
+	verifier, ok := sender.(CallbackVerifier)
+	if !ok {
+		return "", fmt.Errorf("sms provider %s doesn't verify callbacks", provider)
+	}
+	return verifier.VerifyCallback(r)
+}
+
+func (f *failover) provider(name string) (Sender, error) {
+	for _, p := range f.providers {
+		if p.name == name {
+			return p.sender, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown sms provider %q", name)
+}
@@ -0,0 +1,466 @@
+package sms
+
+import (
+	"account/internal/domain"
+	"account/internal/infrastructure/configuration"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type fakeSender struct {
+	id   string
+	err  error
+	sent []string
+}
+
+func (f *fakeSender) Send(c context.Context, message *domain.SMS) (string, error) {
+	if f.err != nil {
+		return "", f.err
+	}
+	f.sent = append(f.sent, message.Recipient)
+	return f.id, nil
+}
+
+func (f *fakeSender) Status(c context.Context, messageId, recipient string) (domain.SMSStatus, error) {
+	return domain.SMSDelivered, f.err
+}
+
+func (f *fakeSender) ParseCallback(r *http.Request) ([]domain.SMSReport, error) {
+	return []domain.SMSReport{{MessageID: r.FormValue("id"), Status: domain.SMSFailed}}, f.err
+}
+
+func testMessage() *domain.SMS {
+	message := domain.NewSMS("77778889966", "hello", time.Now().Add(5*time.Minute))
+	message.Code = "1234"
+	message.Locale = domain.LocaleRussian
+	return message
+}
+
+func callback(values url.Values) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(values.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return r
+}
+
+func TestMobizon(t *testing.T) {
+	c := context.Background()
+	message := testMessage()
+
+	t.Run("success", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/service/Message/SendSmsMessage", r.URL.Path)
+			assert.Equal(t, "sometoken", r.URL.Query().Get("apiKey"))
+			assert.Equal(t, "77778889966", r.FormValue("recipient"))
+			assert.Equal(t, "hello", r.FormValue("text"))
+			w.Write([]byte(`{"code":0,"data":{"campaignId":"1","messageId":"25","status":1}}`))
+		}))
+		defer server.Close()
+
+		id, err := NewMobizon(server.URL, "sometoken").Send(c, message)
+		assert.NoError(t, err)
+		assert.Equal(t, "25", id)
+	})
+
+	t.Run("provider error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"code":1,"message":"not enough money"}`))
+		}))
+		defer server.Close()
+
+		_, err := NewMobizon(server.URL, "sometoken").Send(c, message)
+		assert.EqualError(t, err, "not enough money")
+	})
+
+	t.Run("status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/service/Message/GetSMSStatus", r.URL.Path)
+			assert.Equal(t, "25", r.URL.Query().Get("ids[]"))
+			w.Write([]byte(`{"code":0,"data":[{"id":25,"status":"DELIVRD"}]}`))
+		}))
+		defer server.Close()
+
+		status, err := NewMobizon(server.URL, "sometoken").Status(c, "25", "77778889966")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SMSDelivered, status)
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		reports, err := NewMobizon("", "").ParseCallback(callback(url.Values{"messageId": {"25"}, "status": {"UNDELIV"}}))
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.SMSReport{{MessageID: "25", Status: domain.SMSFailed}}, reports)
+	})
+}
+
+func TestSMSC(t *testing.T) {
+	c := context.Background()
+	message := testMessage()
+
+	t.Run("success", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/sys/send.php", r.URL.Path)
+			assert.Equal(t, "login", r.FormValue("login"))
+			assert.Equal(t, "password", r.FormValue("psw"))
+			assert.Equal(t, "77778889966", r.FormValue("phones"))
+			assert.Equal(t, "hello", r.FormValue("mes"))
+			w.Write([]byte(`{"id":7,"cnt":1}`))
+		}))
+		defer server.Close()
+
+		id, err := NewSMSC(server.URL, "login", "password").Send(c, message)
+		assert.NoError(t, err)
+		assert.Equal(t, "7", id)
+	})
+
+	t.Run("provider error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"error":"authorise error","error_code":2}`))
+		}))
+		defer server.Close()
+
+		_, err := NewSMSC(server.URL, "login", "password").Send(c, message)
+		assert.Error(t, err)
+	})
+
+	t.Run("status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/sys/status.php", r.URL.Path)
+			assert.Equal(t, "7", r.FormValue("id"))
+			assert.Equal(t, "77778889966", r.FormValue("phone"))
+			w.Write([]byte(`{"status":20,"err":1}`))
+		}))
+		defer server.Close()
+
+		status, err := NewSMSC(server.URL, "login", "password").Status(c, "7", "77778889966")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SMSFailed, status)
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		reports, err := NewSMSC("", "", "").ParseCallback(callback(url.Values{"id": {"7"}, "phone": {"77778889966"}, "status": {"1"}}))
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.SMSReport{{MessageID: "7", Status: domain.SMSDelivered}}, reports)
+	})
+}
+
+func TestSMSCCall(t *testing.T) {
+	c := context.Background()
+	message := testMessage()
+
+	t.Run("success", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/sys/send.php", r.URL.Path)
+			assert.Equal(t, "1", r.FormValue("call"))
+			assert.Equal(t, "code:1234", r.FormValue("mes"))
+			w.Write([]byte(`{"id":8,"cnt":1}`))
+		}))
+		defer server.Close()
+
+		id, err := NewSMSCCall(server.URL, "login", "password").Send(c, message)
+		assert.NoError(t, err)
+		assert.Equal(t, "8", id)
+	})
+
+	t.Run("code is not 4 digits", func(t *testing.T) {
+		message := testMessage()
+		message.Code = "12345"
+
+		_, err := NewSMSCCall("", "login", "password").Send(c, message)
+		assert.Error(t, err)
+	})
+}
+
+func TestTelegram(t *testing.T) {
+	c := context.Background()
+	message := testMessage()
+
+	t.Run("success", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/sendVerificationMessage", r.URL.Path)
+			assert.Equal(t, "Bearer sometoken", r.Header.Get("Authorization"))
+			assert.Equal(t, "+77778889966", r.FormValue("phone_number"))
+			assert.Equal(t, "1234", r.FormValue("code"))
+			w.Write([]byte(`{"ok":true,"result":{"request_id":"abc","delivery_status":{"status":"sent"}}}`))
+		}))
+		defer server.Close()
+
+		id, err := NewTelegram(server.URL, "sometoken").Send(c, message)
+		assert.NoError(t, err)
+		assert.Equal(t, "abc", id)
+	})
+
+	t.Run("provider error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"ok":false,"error":"PHONE_NUMBER_NOT_AVAILABLE"}`))
+		}))
+		defer server.Close()
+
+		_, err := NewTelegram(server.URL, "sometoken").Send(c, message)
+		assert.ErrorContains(t, err, "PHONE_NUMBER_NOT_AVAILABLE")
+	})
+
+	t.Run("status", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/checkVerificationStatus", r.URL.Path)
+			assert.Equal(t, "abc", r.FormValue("request_id"))
+			w.Write([]byte(`{"ok":true,"result":{"request_id":"abc","delivery_status":{"status":"read"}}}`))
+		}))
+		defer server.Close()
+
+		status, err := NewTelegram(server.URL, "sometoken").Status(c, "abc", "77778889966")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SMSDelivered, status)
+	})
+
+	t.Run("callback", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"request_id":"abc","delivery_status":{"status":"expired"}}`))
+
+		reports, err := NewTelegram("", "").ParseCallback(r)
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.SMSReport{{MessageID: "abc", Status: domain.SMSFailed}}, reports)
+	})
+}
+
+func TestWhatsApp(t *testing.T) {
+	c := context.Background()
+	message := testMessage()
+
+	t.Run("success", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/phoneid/messages", r.URL.Path)
+			assert.Equal(t, "Bearer sometoken", r.Header.Get("Authorization"))
+			body, _ := io.ReadAll(r.Body)
+			assert.Contains(t, string(body), `"name":"verification_code"`)
+			assert.Contains(t, string(body), `"language":{"code":"ru"}`)
+			assert.Contains(t, string(body), `"text":"1234"`)
+			w.Write([]byte(`{"messaging_product":"whatsapp","messages":[{"id":"wamid.1"}]}`))
+		}))
+		defer server.Close()
+
+		id, err := NewWhatsApp(server.URL, "sometoken", "phoneid", "verification_code", "", "").Send(c, message)
+		assert.NoError(t, err)
+		assert.Equal(t, "wamid.1", id)
+	})
+
+	t.Run("provider error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"error":{"message":"template not found"}}`))
+		}))
+		defer server.Close()
+
+		_, err := NewWhatsApp(server.URL, "sometoken", "phoneid", "verification_code", "", "").Send(c, message)
+		assert.ErrorContains(t, err, "template not found")
+	})
+
+	whatsapp := NewWhatsApp("", "", "", "", "verify", "appsecret")
+	notification := func(body, signature string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("X-Hub-Signature-256", signature)
+		return r
+	}
+	sign := func(body string) string {
+		mac := hmac.New(sha256.New, []byte("appsecret"))
+		mac.Write([]byte(body))
+		return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+
+	t.Run("callback", func(t *testing.T) {
+		body := `{"entry":[{"changes":[{"value":{"statuses":[{"id":"wamid.1","status":"delivered"},{"id":"wamid.2","status":"failed"}]}}]}]}`
+
+		reports, err := whatsapp.ParseCallback(notification(body, sign(body)))
+		assert.NoError(t, err)
+		assert.Equal(t, []domain.SMSReport{
+			{MessageID: "wamid.1", Status: domain.SMSDelivered},
+			{MessageID: "wamid.2", Status: domain.SMSFailed},
+		}, reports)
+	})
+
+	t.Run("callback without statuses", func(t *testing.T) {
+		body := `{"entry":[{"changes":[{"value":{"messages":[{"id":"wamid.3"}]}}]}]}`
+
+		reports, err := whatsapp.ParseCallback(notification(body, sign(body)))
+		assert.NoError(t, err)
+		assert.Empty(t, reports)
+	})
+
+	t.Run("callback with wrong signature", func(t *testing.T) {
+		body := `{"entry":[{"changes":[{"value":{"statuses":[{"id":"wamid.1","status":"delivered"}]}}]}]}`
+
+		_, err := whatsapp.ParseCallback(notification(body, sign(body+" ")))
+		assert.Error(t, err)
+
+		_, err = whatsapp.ParseCallback(notification(body, ""))
+		assert.Error(t, err)
+
+		_, err = NewWhatsApp("", "", "", "", "", "").ParseCallback(notification(body, sign(body)))
+		assert.Error(t, err)
+	})
+
+	t.Run("verify callback", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/?hub.mode=subscribe&hub.verify_token=verify&hub.challenge=1158201444", nil)
+
+		challenge, err := whatsapp.VerifyCallback(r)
+		assert.NoError(t, err)
+		assert.Equal(t, "1158201444", challenge)
+	})
+
+	t.Run("verify callback with wrong token", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/?hub.mode=subscribe&hub.verify_token=wrong&hub.challenge=1158201444", nil)
+
+		_, err := whatsapp.VerifyCallback(r)
+		assert.Error(t, err)
+	})
+}
+
+func TestConsole(t *testing.T) {
+	var out bytes.Buffer
+	console := NewConsole(&out)
+	message := testMessage()
+
+	id, err := console.Send(context.Background(), message)
+	assert.NoError(t, err)
+	assert.Equal(t, "1", id)
+	assert.Contains(t, out.String(), "77778889966")
+	assert.Contains(t, out.String(), "hello")
+}
+
+func TestFailover(t *testing.T) {
+	c := context.Background()
+	message := testMessage()
+
+	t.Run("falls back to the next provider", func(t *testing.T) {
+		ok := &fakeSender{id: "42"}
+		f := &failover{providers: []provider{{"first", &fakeSender{err: errors.New("unavailable")}}, {"second", ok}}, logger: zap.NewNop()}
+
+		name, id, err := f.Send(c, message)
+		assert.NoError(t, err)
+		assert.Equal(t, "second", name)
+		assert.Equal(t, "42", id)
+		assert.Equal(t, []string{"77778889966"}, ok.sent)
+	})
+
+	t.Run("all providers failed", func(t *testing.T) {
+		failing := &fakeSender{err: errors.New("unavailable")}
+		f := &failover{providers: []provider{{"first", failing}, {"second", failing}}, logger: zap.NewNop()}
+
+		_, _, err := f.Send(c, message)
+		assert.ErrorContains(t, err, "first: unavailable")
+		assert.ErrorContains(t, err, "second: unavailable")
+	})
+
+	t.Run("status and callback go to the provider of the message", func(t *testing.T) {
+		f := &failover{providers: []provider{{"first", &fakeSender{err: errors.New("unavailable")}}, {"second", &fakeSender{}}}, logger: zap.NewNop()}
+
+		status, err := f.Status(c, "second", "42", "77778889966")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SMSDelivered, status)
+
+		reports, err := f.ParseCallback("second", callback(url.Values{"id": {"42"}}))
+		assert.NoError(t, err)
+		assert.Equal(t, "42", reports[0].MessageID)
+
+		_, err = f.VerifyCallback("second", callback(url.Values{}))
+		assert.Error(t, err)
+
+		_, err = f.Status(c, "third", "42", "77778889966")
+		assert.Error(t, err)
+	})
+}
+
+func TestRouter(t *testing.T) {
+	c := context.Background()
+	sms := &failover{providers: []provider{{"smsc", &fakeSender{id: "7"}}}, logger: zap.NewNop()}
+
+	t.Run("sends through the channel", func(t *testing.T) {
+		r := &router{sms: sms, channels: map[domain.Channel]provider{domain.ChannelTelegram: {"telegram", &fakeSender{id: "abc"}}}, logger: zap.NewNop()}
+		message := testMessage()
+		message.Channel = domain.ChannelTelegram
+
+		assert.NoError(t, r.Send(c, message))
+		assert.Equal(t, domain.ChannelTelegram, message.Channel)
+		assert.Equal(t, "telegram", message.Provider)
+		assert.Equal(t, "abc", message.ProviderMessageID)
+	})
+
+	t.Run("falls back to sms when the channel fails", func(t *testing.T) {
+		r := &router{sms: sms, channels: map[domain.Channel]provider{domain.ChannelTelegram: {"telegram", &fakeSender{err: errors.New("unavailable")}}}, logger: zap.NewNop()}
+		message := testMessage()
+		message.Channel = domain.ChannelTelegram
+
+		assert.NoError(t, r.Send(c, message))
+		assert.Equal(t, domain.ChannelSMS, message.Channel)
+		assert.Equal(t, "smsc", message.Provider)
+		assert.Equal(t, "7", message.ProviderMessageID)
+	})
+
+	t.Run("falls back to sms when the channel is disabled", func(t *testing.T) {
+		r := &router{sms: sms, channels: map[domain.Channel]provider{}, logger: zap.NewNop()}
+		message := testMessage()
+		message.Channel = domain.ChannelWhatsApp
+
+		assert.NoError(t, r.Send(c, message))
+		assert.Equal(t, domain.ChannelSMS, message.Channel)
+		assert.Equal(t, "smsc", message.Provider)
+	})
+
+	t.Run("status goes to the provider of the message", func(t *testing.T) {
+		r := &router{sms: sms, channels: map[domain.Channel]provider{domain.ChannelTelegram: {"telegram", &fakeSender{}}}, logger: zap.NewNop()}
+
+		status, err := r.Status(c, "telegram", "abc", "77778889966")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SMSDelivered, status)
+
+		status, err = r.Status(c, "smsc", "7", "77778889966")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SMSDelivered, status)
+
+		_, err = r.Status(c, "whatsapp", "abc", "77778889966")
+		assert.Error(t, err)
+	})
+}
+
+func TestNew(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		r, err := New(&configuration.SMSConfig{Senders: []string{"mobizon", "smsc", "console"}, Channels: []string{"telegram", "whatsapp", "call"}, CodeLength: 4, CodeAlphabet: "0123456789"}, zap.NewNop())
+		if assert.NoError(t, err) {
+			assert.Len(t, r.sms.providers, 3)
+			assert.Len(t, r.channels, 3)
+		}
+	})
+
+	t.Run("unknown channel", func(t *testing.T) {
+		_, err := New(&configuration.SMSConfig{Senders: []string{"smsc"}, Channels: []string{"pigeon"}}, zap.NewNop())
+		assert.Error(t, err)
+	})
+
+	t.Run("flash call with long codes", func(t *testing.T) {
+		_, err := New(&configuration.SMSConfig{Senders: []string{"smsc"}, Channels: []string{"call"}, CodeLength: 6, CodeAlphabet: "0123456789"}, zap.NewNop())
+		assert.Error(t, err)
+
+		_, err = New(&configuration.SMSConfig{Senders: []string{"smsc"}, Channels: []string{"call"}, CodeLength: 4, CodeAlphabet: "ABCDEF"}, zap.NewNop())
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown sender", func(t *testing.T) {
+		_, err := New(&configuration.SMSConfig{Senders: []string{"pigeon"}}, zap.NewNop())
+		assert.Error(t, err)
+	})
+
+	t.Run("no senders", func(t *testing.T) {
+		_, err := New(&configuration.SMSConfig{}, zap.NewNop())
+		assert.Error(t, err)
+	})
+}
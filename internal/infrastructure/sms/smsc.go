@@ -0,0 +1,153 @@
+package sms
+
+import (
+	"account/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// smsc sends messages through smsc.kz
+type smsc struct {
+	baseUrl  string
+	login    string
+	password string
+	client   *http.Client
+}
+
+func NewSMSC(baseUrl, login, password string) *smsc {
+	return &smsc{baseUrl: baseUrl, login: login, password: password, client: http.DefaultClient}
+}
+
+func (s *smsc) Send(c context.Context, message *domain.SMS) (string, error) {
+	data := url.Values{
+		"phones":  []string{message.Recipient},
+		"mes":     []string{message.Text},
+		"charset": []string{"utf-8"},
+	}
+
+	var res struct {
+		ID messageID `json:"id"`
+	}
+	if err := s.call(c, "/sys/send.php", data, &res); err != nil {
+		return "", err
+	}
+
+	return string(res.ID), nil
+}
+
+// Status of a message, smsc identifies messages by the id and the phone together
+func (s *smsc) Status(c context.Context, messageId, recipient string) (domain.SMSStatus, error) {
+	data := url.Values{
+		"phone": []string{recipient},
+		"id":    []string{messageId},
+	}
+
+	var res struct {
+		Status int `json:"status"`
+	}
+	if err := s.call(c, "/sys/status.php", data, &res); err != nil {
+		return "", err
+	}
+
+	return smscStatus(res.Status), nil
+}
+
+// ParseCallback reads a delivery report, smsc posts it as a form
+func (s *smsc) ParseCallback(r *http.Request) ([]domain.SMSReport, error) {
+	messageId := r.FormValue("id")
+	if messageId == "" {
+		return nil, errors.New("no message id in smsc callback")
+	}
+
+	var status int
+	if _, err := fmt.Sscan(r.FormValue("status"), &status); err != nil {
+		return nil, fmt.Errorf("invalid status in smsc callback: %v", err)
+	}
+
+	return []domain.SMSReport{{MessageID: messageId, Status: smscStatus(status)}}, nil
+}
+
+func smscStatus(status int) domain.SMSStatus {
+	switch status {
+	case 1, 2, 4: // delivered, read, link opened
+		return domain.SMSDelivered
+	case 3, 20, 22, 23, 24, 25: // expired, undeliverable, wrong or prohibited number, no funds, unavailable
+		return domain.SMSFailed
+	default:
+		return domain.SMSSent
+	}
+}
+
+// smscCall makes flash calls through smsc.kz, the phone gets a dropped call
+// from a number ending with the code
+type smscCall struct {
+	*smsc
+}
+
+func NewSMSCCall(baseUrl, login, password string) *smscCall {
+	return &smscCall{smsc: NewSMSC(baseUrl, login, password)}
+}
+
+func (s *smscCall) Send(c context.Context, message *domain.SMS) (string, error) {
+	if len(message.Code) != 4 || strings.Trim(message.Code, "0123456789") != "" {
+		return "", fmt.Errorf("flash call needs a code of 4 digits")
+	}
+
+	data := url.Values{
+		"phones": []string{message.Recipient},
+		"mes":    []string{"code:" + message.Code},
+		"call":   []string{"1"},
+	}
+
+	var res struct {
+		ID messageID `json:"id"`
+	}
+	if err := s.call(c, "/sys/send.php", data, &res); err != nil {
+		return "", err
+	}
+
+	return string(res.ID), nil
+}
+
+func (s *smsc) call(c context.Context, path string, data url.Values, output any) error {
+	data.Set("login", s.login)
+	data.Set("psw", s.password)
+	data.Set("fmt", "3") // json response
+
+	req, err := http.NewRequestWithContext(c, http.MethodPost, s.baseUrl+path, strings.NewReader(data.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	var res struct {
+		Error     string `json:"error"`
+		ErrorCode int    `json:"error_code"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return fmt.Errorf("failed to unmarshal json from body: %v\nbody:%v", err, string(body))
+	}
+
+	if res.ErrorCode != 0 {
+		return fmt.Errorf("smsc error %d: %s", res.ErrorCode, res.Error)
+	}
+
+	return json.Unmarshal(body, output)
+}
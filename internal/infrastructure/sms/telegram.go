@@ -0,0 +1,118 @@
+package sms
+
+import (
+	"account/internal/domain"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// telegram sends codes through Telegram Gateway, it formats messages itself
+type telegram struct {
+	baseUrl     string
+	accessToken string
+	client      *http.Client
+}
+
+func NewTelegram(baseUrl, accessToken string) *telegram {
+	return &telegram{baseUrl: baseUrl, accessToken: accessToken, client: http.DefaultClient}
+}
+
+type telegramRequestStatus struct {
+	RequestID      string `json:"request_id"`
+	DeliveryStatus struct {
+		Status string `json:"status"`
+	} `json:"delivery_status"`
+}
+
+func (t *telegram) Send(c context.Context, message *domain.SMS) (string, error) {
+	data := url.Values{
+		"phone_number": []string{"+" + message.Recipient},
+		"code":         []string{message.Code},
+	}
+	// telegram accepts ttl from 30 seconds to an hour
+	if ttl := int(time.Until(message.ExpiresAt).Seconds()); ttl >= 30 && ttl <= 3600 {
+		data.Set("ttl", strconv.Itoa(ttl))
+	}
+
+	var res telegramRequestStatus
+	if err := t.call(c, "/sendVerificationMessage", data, &res); err != nil {
+		return "", err
+	}
+
+	return res.RequestID, nil
+}
+
+func (t *telegram) Status(c context.Context, messageId, recipient string) (domain.SMSStatus, error) {
+	var res telegramRequestStatus
+	if err := t.call(c, "/checkVerificationStatus", url.Values{"request_id": []string{messageId}}, &res); err != nil {
+		return "", err
+	}
+
+	return telegramStatus(res.DeliveryStatus.Status), nil
+}
+
+// ParseCallback reads a delivery report, telegram posts the request status as json
+func (t *telegram) ParseCallback(r *http.Request) ([]domain.SMSReport, error) {
+	var res telegramRequestStatus
+	if err := json.NewDecoder(r.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("invalid telegram callback: %v", err)
+	}
+	if res.RequestID == "" {
+		return nil, errors.New("no request id in telegram callback")
+	}
+	return []domain.SMSReport{{MessageID: res.RequestID, Status: telegramStatus(res.DeliveryStatus.Status)}}, nil
+}
+
+func telegramStatus(status string) domain.SMSStatus {
+	switch status {
+	case "delivered", "read":
+		return domain.SMSDelivered
+	case "expired", "revoked":
+		return domain.SMSFailed
+	default:
+		return domain.SMSSent
+	}
+}
+
+func (t *telegram) call(c context.Context, method string, data url.Values, output any) error {
+	req, err := http.NewRequestWithContext(c, http.MethodPost, t.baseUrl+method, strings.NewReader(data.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+t.accessToken)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	var res struct {
+		OK     bool            `json:"ok"`
+		Error  string          `json:"error"`
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.Unmarshal(body, &res); err != nil {
+		return fmt.Errorf("failed to unmarshal json from body: %v\nbody:%v", err, string(body))
+	}
+
+	if !res.OK {
+		return fmt.Errorf("telegram error: %s", res.Error)
+	}
+
+	return json.Unmarshal(res.Result, output)
+}
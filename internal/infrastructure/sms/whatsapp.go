@@ -0,0 +1,203 @@
+package sms
+
+import (
+	"account/internal/domain"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// whatsapp sends codes through WhatsApp Business with an authentication template
+type whatsapp struct {
+	baseUrl     string
+	accessToken string
+	phoneId     string
+	template    string
+	client      *http.Client
+
+	// verifyToken confirms the webhook url when it's subscribed,
+	// appSecret signs notifications posted to it
+	verifyToken string
+	appSecret   string
+}
+
+func NewWhatsApp(baseUrl, accessToken, phoneId, template, verifyToken, appSecret string) *whatsapp {
+	return &whatsapp{
+		baseUrl:     baseUrl,
+		accessToken: accessToken,
+		phoneId:     phoneId,
+		template:    template,
+		client:      http.DefaultClient,
+		verifyToken: verifyToken,
+		appSecret:   appSecret,
+	}
+}
+
+type whatsappParameter struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type whatsappComponent struct {
+	Type       string              `json:"type"`
+	SubType    string              `json:"sub_type,omitempty"`
+	Index      string              `json:"index,omitempty"`
+	Parameters []whatsappParameter `json:"parameters"`
+}
+
+func (w *whatsapp) Send(c context.Context, message *domain.SMS) (string, error) {
+	code := []whatsappParameter{{Type: "text", Text: message.Code}}
+
+	body := map[string]any{
+		"messaging_product": "whatsapp",
+		"to":                message.Recipient,
+		"type":              "template",
+		"template": map[string]any{
+			"name":     w.template,
+			"language": map[string]string{"code": string(message.Locale)},
+			"components": []whatsappComponent{
+				{Type: "body", Parameters: code},
+				{Type: "button", SubType: "url", Index: "0", Parameters: code},
+			},
+		},
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(c, http.MethodPost, w.baseUrl+"/"+w.phoneId+"/messages", bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+w.accessToken)
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to post request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	resBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	var res struct {
+		Messages []struct {
+			ID string `json:"id"`
+		} `json:"messages"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(resBody, &res); err != nil {
+		return "", fmt.Errorf("failed to unmarshal json from body: %v\nbody:%v", err, string(resBody))
+	}
+
+	if res.Error != nil {
+		return "", fmt.Errorf("whatsapp error: %s", res.Error.Message)
+	}
+	if len(res.Messages) == 0 {
+		return "", errors.New("whatsapp accepted no messages")
+	}
+
+	return res.Messages[0].ID, nil
+}
+
+// Status can't be polled, whatsapp only reports it with webhooks
+func (w *whatsapp) Status(c context.Context, messageId, recipient string) (domain.SMSStatus, error) {
+	return domain.SMSSent, nil
+}
+
+// VerifyCallback answers the subscription request of the webhook with its challenge
+func (w *whatsapp) VerifyCallback(r *http.Request) (string, error) {
+	query := r.URL.Query()
+	if query.Get("hub.mode") != "subscribe" {
+		return "", fmt.Errorf("unknown whatsapp webhook mode %q", query.Get("hub.mode"))
+	}
+	if w.verifyToken == "" || subtle.ConstantTimeCompare([]byte(query.Get("hub.verify_token")), []byte(w.verifyToken)) != 1 {
+		return "", errors.New("invalid whatsapp verify token")
+	}
+	return query.Get("hub.challenge"), nil
+}
+
+// ParseCallback reads statuses of a webhook notification signed with the app secret,
+// one notification may carry statuses of many messages
+func (w *whatsapp) ParseCallback(r *http.Request) ([]domain.SMSReport, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read whatsapp callback: %v", err)
+	}
+	if err := w.verifySignature(r.Header.Get("X-Hub-Signature-256"), body); err != nil {
+		return nil, err
+	}
+
+	var notification struct {
+		Entry []struct {
+			Changes []struct {
+				Value struct {
+					Statuses []struct {
+						ID     string `json:"id"`
+						Status string `json:"status"`
+					} `json:"statuses"`
+				} `json:"value"`
+			} `json:"changes"`
+		} `json:"entry"`
+	}
+	if err := json.Unmarshal(body, &notification); err != nil {
+		return nil, fmt.Errorf("invalid whatsapp callback: %v", err)
+	}
+
+	var reports []domain.SMSReport
+	for _, entry := range notification.Entry {
+		for _, change := range entry.Changes {
+			for _, status := range change.Value.Statuses {
+				reports = append(reports, domain.SMSReport{MessageID: status.ID, Status: whatsappStatus(status.Status)})
+			}
+		}
+	}
+
+	return reports, nil
+}
+
+// verifySignature checks the signature header, "sha256=" and hex HMAC-SHA256 of the body
+func (w *whatsapp) verifySignature(header string, body []byte) error {
+	if w.appSecret == "" {
+		return errors.New("whatsapp app secret isn't configured")
+	}
+
+	hexSignature, ok := strings.CutPrefix(header, "sha256=")
+	signature, err := hex.DecodeString(hexSignature)
+	if !ok || err != nil {
+		return errors.New("invalid whatsapp callback signature")
+	}
+
+	mac := hmac.New(sha256.New, []byte(w.appSecret))
+	mac.Write(body)
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return errors.New("invalid whatsapp callback signature")
+	}
+	return nil
+}
+
+func whatsappStatus(status string) domain.SMSStatus {
+	switch status {
+	case "delivered", "read":
+		return domain.SMSDelivered
+	case "failed":
+		return domain.SMSFailed
+	default:
+		return domain.SMSSent
+	}
+}
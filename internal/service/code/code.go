@@ -4,12 +4,8 @@ import (
 	"account/internal/domain"
 	"account/internal/infrastructure/configuration"
 	"context"
-	"encoding/json"
+	"crypto/hmac"
 	"errors"
-	"fmt"
-	"io"
-	"net/http"
-	"net/url"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -17,65 +13,164 @@ import (
 
 //go:generate mockgen -source ./code.go -destination ./mock/mock.go -package mock
 type Repository interface {
-	FindOneByPhoneAndIP(c context.Context, phone, ip string) (*domain.Code, error)
-	FindLatestByIP(c context.Context, ip string, timestamp time.Time) ([]domain.Code, error)
-	FindOneByCredentials(c context.Context, phone, code string) (*domain.Code, error)
+	FindOneByPhoneAndIP(c context.Context, phone, ip string, purpose domain.CodePurpose) (*domain.Code, error)
+	FindLatestByIP(c context.Context, ip string, purpose domain.CodePurpose, timestamp time.Time) ([]domain.Code, error)
+	FindLatestByPhone(c context.Context, phone string, purpose domain.CodePurpose, timestamp time.Time) ([]domain.Code, error)
+	FindNewestByPhone(c context.Context, phone string, purpose domain.CodePurpose) (*domain.Code, error)
 
-	Save(c context.Context, code *domain.Code) error
+	Save(c context.Context, code *domain.Code, message *domain.SMS) error
+	AddAttempt(c context.Context, id, maxAttempts int) error
+	RemoveAttempt(c context.Context, id int) error
 
-	RemoveAll(c context.Context, phone string) error
+	SaveFailure(c context.Context, phone string, purpose domain.CodePurpose) error
+	CountFailures(c context.Context, phone string, purpose domain.CodePurpose, since time.Time) (int, error)
+
+	RemoveAll(c context.Context, phone string, purpose domain.CodePurpose) error
+
+	// ClaimQueued returns messages with decrypted texts and codes, they are empty if they can't be decrypted
+	ClaimQueued(c context.Context, limit int, lease time.Duration) ([]domain.SMS, error)
+	// MarkSent saves the channel and the provider which accepted the message
+	MarkSent(c context.Context, message *domain.SMS) error
+	MarkFailed(c context.Context, id int, nextAttemptAt time.Time, lastError string) error
+	MarkDead(c context.Context, id, attempts int, lastError string) error
+
+	FindUnconfirmed(c context.Context, sentAfter, checkedBefore time.Time, limit int) ([]domain.SMS, error)
+	UpdateStatus(c context.Context, id int, status domain.SMSStatus) error
+	UpdateStatusByProviderID(c context.Context, provider, providerMessageId string, status domain.SMSStatus) error
+	FindLatestByRecipient(c context.Context, recipient string) (*domain.SMS, error)
+
+	RemoveExpired(c context.Context, before time.Time, limit int) (int64, error)
+	RemoveFailures(c context.Context, before time.Time, limit int) (int64, error)
+	RemoveFinished(c context.Context, before time.Time, limit int) (int64, error)
+}
+
+type Sender interface {
+	// Send delivers the message through its channel or by sms, and sets the channel,
+	// the provider which accepted the message and the id of the message there
+	Send(c context.Context, message *domain.SMS) error
+	Status(c context.Context, provider, messageId, recipient string) (domain.SMSStatus, error)
 }
 
 type service struct {
-	baseUrl     string
-	accessToken string
+	secret     []byte
+	codePolicy domain.CodePolicy
+
+	appDomain      string
+	androidAppHash string
+
+	codeMaxAttempts  int
+	phoneMaxFailures int
+	phoneLockWindow  time.Duration
+
+	phoneMaxCodes int
+	phoneWindow   time.Duration
+	phoneCooldown time.Duration
 
-	repo Repository
+	channelMaxCodes map[string]int
+
+	outboxBatchSize   int
+	outboxLease       time.Duration
+	outboxMaxAttempts int
+	outboxBackoff     time.Duration
+	outboxMaxBackoff  time.Duration
+
+	statusPollInterval time.Duration
+	statusPollWindow   time.Duration
+
+	codeRetention  time.Duration
+	purgeBatchSize int
+
+	repo   Repository
+	sender Sender
 }
 
-func New(cfg *configuration.SMSConfig, repo Repository) *service {
+func New(cfg *configuration.SMSConfig, repo Repository, sender Sender) *service {
 	return &service{
-		baseUrl:     cfg.ApiDomain,
-		accessToken: cfg.ApiKey,
-		repo:        repo,
+		secret:     []byte(cfg.CodeSecret),
+		codePolicy: Policy(cfg),
+
+		appDomain:      cfg.AppDomain,
+		androidAppHash: cfg.AndroidAppHash,
+
+		codeMaxAttempts:  cfg.CodeMaxAttempts,
+		phoneMaxFailures: cfg.PhoneMaxFailures,
+		phoneLockWindow:  cfg.PhoneLockWindow,
+
+		phoneMaxCodes: cfg.PhoneMaxCodes,
+		phoneWindow:   cfg.PhoneWindow,
+		phoneCooldown: cfg.PhoneCooldown,
+
+		channelMaxCodes: cfg.ChannelMaxCodes,
+
+		outboxBatchSize:   cfg.OutboxBatchSize,
+		outboxLease:       cfg.OutboxLease,
+		outboxMaxAttempts: cfg.OutboxMaxAttempts,
+		outboxBackoff:     cfg.OutboxBackoff,
+		outboxMaxBackoff:  cfg.OutboxMaxBackoff,
+
+		statusPollInterval: cfg.StatusPollInterval,
+		statusPollWindow:   cfg.StatusPollWindow,
+
+		codeRetention:  cfg.CodeRetention,
+		purgeBatchSize: cfg.PurgeBatchSize,
+
+		repo:   repo,
+		sender: sender,
 	}
 }
 
-func (s *service) Send(c context.Context, phone, ip string) error {
-	if err := s.spamProtect(c, phone, ip); err != nil {
+// Policy is the code generation policy of the configuration, it should be validated on start
+func Policy(cfg *configuration.SMSConfig) domain.CodePolicy {
+	return domain.CodePolicy{
+		Length:   cfg.CodeLength,
+		Alphabet: cfg.CodeAlphabet,
+		TTL:      cfg.CodeTTL,
+	}
+}
+
+// Send queues a code for the purpose to the phone in the locale, the message is delivered
+// through the channel by DeliverQueued. Sending limits are counted per purpose.
+func (s *service) Send(c context.Context, phone, ip string, purpose domain.CodePurpose, channel domain.Channel, locale domain.Locale) error {
+	if err := s.spamProtect(c, phone, ip, purpose, channel); err != nil {
 		return err
 	}
 
-	code, err := domain.NewCode(phone, ip)
+	code, err := domain.NewCode(phone, ip, purpose, s.codePolicy)
 	if err != nil {
 		return err
 	}
-
-	if err := s.repo.Save(c, code); err != nil {
+	if err := s.hash(code); err != nil {
 		return err
 	}
 
-	return s.sendSMS("7"+phone, "mangahana.com\nРастау коды: "+code.Code)
+	code.Channel = channel
+
+	message := domain.NewSMS("7"+phone, s.codeMessage(locale, code.Code), code.ExpiresAt)
+	message.Code = code.Code
+	message.Channel = channel
+	message.Locale = locale
+
+	return s.repo.Save(c, code, message)
 }
 
-func (s *service) spamProtect(c context.Context, phone, ip string) error {
-	code, err := s.repo.FindOneByPhoneAndIP(c, phone, ip)
+// ipWindow is the period in which at most 5 codes can be sent from an IP
+const ipWindow = time.Minute * 30
+
+func (s *service) spamProtect(c context.Context, phone, ip string, purpose domain.CodePurpose, channel domain.Channel) error {
+	code, err := s.repo.FindOneByPhoneAndIP(c, phone, ip, purpose)
 	if err != nil {
 		if !errors.Is(err, pgx.ErrNoRows) {
 			return err
 		}
-	}
-
-	if code.CreatedAt.UTC().Unix() > time.Now().UTC().Add(time.Minute*-3).Unix() {
+	} else if code.CreatedAt.UTC().Unix() > time.Now().UTC().Add(time.Minute*-3).Unix() {
 		return domain.ErrCodeSendingLimit
 	}
 
-	// еще стоит добавить защиту спама на определенный номер,
-	// допустим если на один номер за последние пол часа отправилось 5 смс то блокаем смс на этот номер
-
-	lastHalfHour := time.Now().Add(time.Minute * -30)
+	if err := s.phoneProtect(c, phone, purpose, channel); err != nil {
+		return err
+	}
 
-	codes, err := s.repo.FindLatestByIP(c, ip, lastHalfHour)
+	codes, err := s.repo.FindLatestByIP(c, ip, purpose, time.Now().Add(-ipWindow))
 	if err != nil {
 		return err
 	}
@@ -86,43 +181,116 @@ func (s *service) spamProtect(c context.Context, phone, ip string) error {
 	return nil
 }
 
-func (s *service) sendSMS(recipient, text string) error {
-	path := fmt.Sprintf("%s%s?apiKey=%s", s.baseUrl, "/service/Message/SendSmsMessage", s.accessToken)
+// phoneProtect limits codes sent to the phone from any IP: at most phoneMaxCodes
+// within phoneWindow, and the pause before the next code doubles with every code sent
+// up to phoneWindow.
+// Channels listed in channelMaxCodes have their own limit within the same window.
+func (s *service) phoneProtect(c context.Context, phone string, purpose domain.CodePurpose, channel domain.Channel) error {
+	now := time.Now().UTC()
 
-	data := url.Values{
-		"recipient": []string{recipient},
-		"text":      []string{text},
+	codes, err := s.repo.FindLatestByPhone(c, phone, purpose, now.Add(-s.phoneWindow))
+	if err != nil {
+		return err
 	}
-	resp, err := http.PostForm(path, data)
+
+	if len(codes) >= s.phoneMaxCodes {
+		return domain.ErrTooManyCodesSent
+	}
+
+	if limit := s.channelMaxCodes[string(channel)]; limit > 0 {
+		var sent int
+		for _, code := range codes {
+			if code.Channel == channel {
+				sent++
+			}
+		}
+		if sent >= limit {
+			return domain.ErrTooManyCodesSent
+		}
+	}
+
+	if len(codes) > 0 {
+		if now.Sub(codes[0].CreatedAt.UTC()) < s.cooldown(len(codes)) {
+			return domain.ErrCodeSendingLimit
+		}
+	}
+
+	return nil
+}
+
+// cooldown is the pause after the given number of codes sent within phoneWindow
+func (s *service) cooldown(sent int) time.Duration {
+	delay := s.phoneCooldown
+	for i := 1; i < sent && delay < s.phoneWindow; i++ {
+		delay *= 2
+	}
+	return min(delay, s.phoneWindow)
+}
+
+// Verify checks the code against the last code for the purpose sent to the phone.
+// A code can be checked only codeMaxAttempts times, and the phone is locked
+// for phoneLockWindow after phoneMaxFailures wrong codes.
+func (s *service) Verify(c context.Context, phone string, purpose domain.CodePurpose, code string) error {
+	now := time.Now().UTC()
+
+	failures, err := s.repo.CountFailures(c, phone, purpose, now.Add(-s.phoneLockWindow))
 	if err != nil {
-		return fmt.Errorf("failed to post request: %v", err)
+		return err
+	}
+	if failures >= s.phoneMaxFailures {
+		return domain.ErrTooManyAttempts
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	newest, err := s.repo.FindNewestByPhone(c, phone, purpose)
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %v", err)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ErrInvalidCode
+		}
+		return err
 	}
 
-	var res struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
+	// the attempt is counted before the check, so concurrent guesses can't exceed the limit
+	if err := s.repo.AddAttempt(c, newest.ID, s.codeMaxAttempts); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ErrTooManyAttempts
+		}
+		return err
 	}
-	if err := json.Unmarshal(body, &res); err != nil {
-		return fmt.Errorf("failed to unmarshal json from body: %v\nbody:%v", err, string(body))
+
+	if !hmac.Equal([]byte(newest.Hash), []byte(domain.HashCode(s.secret, newest.Salt, code))) {
+		if err := s.repo.SaveFailure(c, phone, purpose); err != nil {
+			return err
+		}
+		if newest.Attempts+1 >= s.codeMaxAttempts {
+			return domain.ErrTooManyAttempts
+		}
+		return domain.ErrInvalidCode
 	}
 
-	if res.Code != 0 {
-		return errors.New(res.Message)
+	// the right code is checked once more on register, it shouldn't use up attempts
+	if err := s.repo.RemoveAttempt(c, newest.ID); err != nil {
+		return err
+	}
+
+	if newest.IsExpired(now) {
+		return domain.ErrCodeExpired
 	}
 
 	return nil
 }
 
-func (s *service) Verify(c context.Context, phone, code string) error {
-	_, err := s.repo.FindOneByCredentials(c, phone, code)
-	return err
+func (s *service) RemoveAll(c context.Context, phone string, purpose domain.CodePurpose) error {
+	return s.repo.RemoveAll(c, phone, purpose)
 }
 
-func (s *service) RemoveAll(c context.Context, phone string) error {
-	return s.repo.RemoveAll(c, phone)
+// hash sets a keyed digest of the code with a random salt, see domain.HashCode
+func (s *service) hash(code *domain.Code) error {
+	salt, err := domain.NewSalt()
+	if err != nil {
+		return err
+	}
+
+	code.Salt = salt
+	code.Hash = domain.HashCode(s.secret, code.Salt, code.Code)
+	return nil
 }
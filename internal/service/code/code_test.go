@@ -5,11 +5,11 @@ import (
 	"account/internal/infrastructure/configuration"
 	"account/internal/service/code/mock"
 	"context"
-	"net/http"
-	"net/http/httptest"
+	"errors"
 	"testing"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 )
@@ -26,62 +26,514 @@ func TestSend(t *testing.T) {
 
 	t.Run("success", func(t *testing.T) {
 		// setup mocks
-		code := domain.Code{Code: "1212", Phone: "7778885566", IP: "127.0.0.1", CreatedAt: time.Now()}
-		repo.EXPECT().FindLatestByIP(c, "127.0.0.1", gomock.Any()).Return([]domain.Code{code}, nil)
-		repo.EXPECT().FindOneByPhoneAndIP(c, "7778889966", "127.0.0.1").Return(&domain.Code{}, nil)
-		repo.EXPECT().Save(c, gomock.Any()).Return(nil)
-
-		mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(`{"code":0}`))
-		}))
-		defer mockServer.Close()
+		code := domain.Code{Hash: "hash", Phone: "7778885566", IP: "127.0.0.1", CreatedAt: time.Now()}
+		repo.EXPECT().FindLatestByIP(c, "127.0.0.1", domain.PurposeRegister, gomock.Any()).Return([]domain.Code{code}, nil)
+		repo.EXPECT().FindOneByPhoneAndIP(c, "7778889966", "127.0.0.1", domain.PurposeRegister).Return(&domain.Code{}, nil)
+		repo.EXPECT().FindLatestByPhone(c, "7778889966", domain.PurposeRegister, gomock.Any()).Return([]domain.Code{}, nil)
+		repo.EXPECT().Save(c, gomock.Any(), gomock.Any()).DoAndReturn(func(c context.Context, code *domain.Code, message *domain.SMS) error {
+			assert.Equal(t, domain.PurposeRegister, code.Purpose)
+			assert.Equal(t, domain.HashCode(nil, code.Salt, code.Code), code.Hash)
+			assert.Len(t, code.Salt, 32)
+			assert.Len(t, code.Code, 6)
+			assert.Equal(t, "77778889966", message.Recipient)
+			assert.Contains(t, message.Text, code.Code)
+			assert.Equal(t, code.ExpiresAt, message.ExpiresAt)
+			assert.Equal(t, domain.ChannelSMS, code.Channel)
+			assert.Equal(t, code.Code, message.Code)
+			assert.Equal(t, domain.ChannelSMS, message.Channel)
+			assert.Equal(t, domain.LocaleKazakh, message.Locale)
+			return nil
+		})
 
 		cfg := &configuration.SMSConfig{
-			ApiKey:    "sometoken",
-			ApiDomain: mockServer.URL,
+			CodeLength:    6,
+			CodeAlphabet:  "0123456789",
+			CodeTTL:       time.Minute * 5,
+			PhoneMaxCodes: 5,
+			PhoneWindow:   time.Minute * 30,
+			PhoneCooldown: time.Minute,
 		}
-		service := New(cfg, repo)
+		service := New(cfg, repo, nil)
 
-		err := service.Send(c, "7778889966", "127.0.0.1")
+		err := service.Send(c, "7778889966", "127.0.0.1", domain.PurposeRegister, domain.ChannelSMS, domain.LocaleKazakh)
 		assert.NoError(t, err)
 	})
 
 	t.Run("fail", func(t *testing.T) {
-		repo.EXPECT().FindOneByPhoneAndIP(c, "7778889966", "127.0.0.1").Return(&domain.Code{CreatedAt: time.Now()}, nil)
+		repo.EXPECT().FindOneByPhoneAndIP(c, "7778889966", "127.0.0.1", domain.PurposeRegister).Return(&domain.Code{CreatedAt: time.Now()}, nil)
 
 		cfg := &configuration.SMSConfig{}
-		service := New(cfg, repo)
+		service := New(cfg, repo, nil)
+
+		err := service.Send(c, "7778889966", "127.0.0.1", domain.PurposeRegister, domain.ChannelSMS, domain.LocaleKazakh)
+		assert.ErrorIs(t, err, domain.ErrCodeSendingLimit)
+	})
+
+	phoneLimits := &configuration.SMSConfig{
+		PhoneMaxCodes: 5,
+		PhoneWindow:   time.Minute * 30,
+		PhoneCooldown: time.Minute,
+	}
 
-		err := service.Send(c, "7778889966", "127.0.0.1")
+	t.Run("phone cooldown", func(t *testing.T) {
+		now := time.Now().UTC()
+		repo.EXPECT().FindOneByPhoneAndIP(c, "7778889966", "127.0.0.5", domain.PurposeRegister).Return(nil, pgx.ErrNoRows)
+		// the third code needs a 2 minute pause after the second one
+		repo.EXPECT().FindLatestByPhone(c, "7778889966", domain.PurposeRegister, gomock.Any()).Return([]domain.Code{
+			{Phone: "7778889966", IP: "127.0.0.2", CreatedAt: now.Add(-time.Second * 90)},
+			{Phone: "7778889966", IP: "127.0.0.1", CreatedAt: now.Add(-time.Minute * 10)},
+		}, nil)
+
+		service := New(phoneLimits, repo, nil)
+
+		err := service.Send(c, "7778889966", "127.0.0.5", domain.PurposeRegister, domain.ChannelSMS, domain.LocaleKazakh)
 		assert.ErrorIs(t, err, domain.ErrCodeSendingLimit)
 	})
+
+	t.Run("phone limit", func(t *testing.T) {
+		now := time.Now().UTC()
+		repo.EXPECT().FindOneByPhoneAndIP(c, "7778889966", "127.0.0.5", domain.PurposeRegister).Return(nil, pgx.ErrNoRows)
+		codes := []domain.Code{}
+		for i := range 5 {
+			codes = append(codes, domain.Code{Phone: "7778889966", CreatedAt: now.Add(-time.Minute * time.Duration(20+i))})
+		}
+		repo.EXPECT().FindLatestByPhone(c, "7778889966", domain.PurposeRegister, gomock.Any()).Return(codes, nil)
+
+		service := New(phoneLimits, repo, nil)
+
+		err := service.Send(c, "7778889966", "127.0.0.5", domain.PurposeRegister, domain.ChannelSMS, domain.LocaleKazakh)
+		assert.ErrorIs(t, err, domain.ErrTooManyCodesSent)
+	})
+
+	t.Run("channel limit", func(t *testing.T) {
+		now := time.Now().UTC()
+		repo.EXPECT().FindOneByPhoneAndIP(c, "7778889966", "127.0.0.5", domain.PurposeRegister).Return(nil, pgx.ErrNoRows)
+		repo.EXPECT().FindLatestByPhone(c, "7778889966", domain.PurposeRegister, gomock.Any()).Return([]domain.Code{
+			{Phone: "7778889966", Channel: domain.ChannelCall, CreatedAt: now.Add(-time.Minute * 10)},
+			{Phone: "7778889966", Channel: domain.ChannelCall, CreatedAt: now.Add(-time.Minute * 20)},
+		}, nil)
+
+		cfg := *phoneLimits
+		cfg.ChannelMaxCodes = configuration.ChannelLimits{"call": 2}
+		service := New(&cfg, repo, nil)
+
+		err := service.Send(c, "7778889966", "127.0.0.5", domain.PurposeRegister, domain.ChannelCall, domain.LocaleKazakh)
+		assert.ErrorIs(t, err, domain.ErrTooManyCodesSent)
+	})
 }
 
 func TestVerify(t *testing.T) {
 	c, repo := setup(t)
 
+	cfg := &configuration.SMSConfig{
+		CodeSecret:       "secret",
+		CodeMaxAttempts:  5,
+		PhoneMaxFailures: 10,
+		PhoneLockWindow:  time.Hour,
+	}
+	// stored is the code "1234" as it's kept in the database
+	stored := func(attempts int, expiresAt time.Time) *domain.Code {
+		return &domain.Code{ID: 1, Hash: domain.HashCode([]byte("secret"), "salt", "1234"), Salt: "salt", Attempts: attempts, ExpiresAt: expiresAt}
+	}
+
 	t.Run("success", func(t *testing.T) {
-		repo.EXPECT().FindOneByCredentials(c, "7778889955", "1234").Return(&domain.Code{Code: "1234"}, nil)
+		repo.EXPECT().CountFailures(c, "7778889955", domain.PurposeRegister, gomock.Any()).Return(0, nil)
+		repo.EXPECT().FindNewestByPhone(c, "7778889955", domain.PurposeRegister).Return(stored(0, time.Now().Add(time.Minute)), nil)
+		repo.EXPECT().AddAttempt(c, 1, 5).Return(nil)
+		repo.EXPECT().RemoveAttempt(c, 1).Return(nil)
 
-		service := New(&configuration.SMSConfig{}, repo)
+		service := New(cfg, repo, nil)
 
-		err := service.Verify(c, "7778889955", "1234")
+		err := service.Verify(c, "7778889955", domain.PurposeRegister, "1234")
 
 		assert.NoError(t, err)
 	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		repo.EXPECT().CountFailures(c, "7778889955", domain.PurposeRegister, gomock.Any()).Return(0, nil)
+		repo.EXPECT().FindNewestByPhone(c, "7778889955", domain.PurposeRegister).Return(stored(0, time.Now().Add(time.Minute)), nil)
+		repo.EXPECT().AddAttempt(c, 1, 5).Return(nil)
+		repo.EXPECT().SaveFailure(c, "7778889955", domain.PurposeRegister).Return(nil)
+
+		service := New(cfg, repo, nil)
+
+		err := service.Verify(c, "7778889955", domain.PurposeRegister, "4321")
+
+		assert.ErrorIs(t, err, domain.ErrInvalidCode)
+	})
+
+	t.Run("last attempt", func(t *testing.T) {
+		repo.EXPECT().CountFailures(c, "7778889955", domain.PurposeRegister, gomock.Any()).Return(4, nil)
+		repo.EXPECT().FindNewestByPhone(c, "7778889955", domain.PurposeRegister).Return(stored(4, time.Now().Add(time.Minute)), nil)
+		repo.EXPECT().AddAttempt(c, 1, 5).Return(nil)
+		repo.EXPECT().SaveFailure(c, "7778889955", domain.PurposeRegister).Return(nil)
+
+		service := New(cfg, repo, nil)
+
+		err := service.Verify(c, "7778889955", domain.PurposeRegister, "4321")
+
+		assert.ErrorIs(t, err, domain.ErrTooManyAttempts)
+	})
+
+	t.Run("code attempts exhausted", func(t *testing.T) {
+		repo.EXPECT().CountFailures(c, "7778889955", domain.PurposeRegister, gomock.Any()).Return(5, nil)
+		repo.EXPECT().FindNewestByPhone(c, "7778889955", domain.PurposeRegister).Return(stored(5, time.Now().Add(time.Minute)), nil)
+		repo.EXPECT().AddAttempt(c, 1, 5).Return(pgx.ErrNoRows)
+
+		service := New(cfg, repo, nil)
+
+		err := service.Verify(c, "7778889955", domain.PurposeRegister, "1234")
+
+		assert.ErrorIs(t, err, domain.ErrTooManyAttempts)
+	})
+
+	t.Run("phone locked", func(t *testing.T) {
+		repo.EXPECT().CountFailures(c, "7778889955", domain.PurposeRegister, gomock.Any()).Return(10, nil)
+
+		service := New(cfg, repo, nil)
+
+		err := service.Verify(c, "7778889955", domain.PurposeRegister, "1234")
+
+		assert.ErrorIs(t, err, domain.ErrTooManyAttempts)
+	})
+
+	t.Run("code of another purpose", func(t *testing.T) {
+		repo.EXPECT().CountFailures(c, "7778889955", domain.PurposePasswordReset, gomock.Any()).Return(0, nil)
+		repo.EXPECT().FindNewestByPhone(c, "7778889955", domain.PurposePasswordReset).Return(nil, pgx.ErrNoRows)
+
+		service := New(cfg, repo, nil)
+
+		err := service.Verify(c, "7778889955", domain.PurposePasswordReset, "1234")
+
+		assert.ErrorIs(t, err, domain.ErrInvalidCode)
+	})
+
+	t.Run("no code", func(t *testing.T) {
+		repo.EXPECT().CountFailures(c, "7778889955", domain.PurposeRegister, gomock.Any()).Return(0, nil)
+		repo.EXPECT().FindNewestByPhone(c, "7778889955", domain.PurposeRegister).Return(nil, pgx.ErrNoRows)
+
+		service := New(cfg, repo, nil)
+
+		err := service.Verify(c, "7778889955", domain.PurposeRegister, "1234")
+
+		assert.ErrorIs(t, err, domain.ErrInvalidCode)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		repo.EXPECT().CountFailures(c, "7778889955", domain.PurposeRegister, gomock.Any()).Return(0, nil)
+		repo.EXPECT().FindNewestByPhone(c, "7778889955", domain.PurposeRegister).Return(stored(0, time.Now().Add(-time.Minute)), nil)
+		repo.EXPECT().AddAttempt(c, 1, 5).Return(nil)
+		repo.EXPECT().RemoveAttempt(c, 1).Return(nil)
+
+		service := New(cfg, repo, nil)
+
+		err := service.Verify(c, "7778889955", domain.PurposeRegister, "1234")
+
+		assert.ErrorIs(t, err, domain.ErrCodeExpired)
+	})
 }
 
 func TestRemoveAll(t *testing.T) {
 	c, repo := setup(t)
 
 	t.Run("success", func(t *testing.T) {
-		repo.EXPECT().RemoveAll(c, "7778889955").Return(nil)
+		repo.EXPECT().RemoveAll(c, "7778889955", domain.PurposeRegister).Return(nil)
+
+		service := New(&configuration.SMSConfig{}, repo, nil)
+
+		err := service.RemoveAll(c, "7778889955", domain.PurposeRegister)
+
+		assert.NoError(t, err)
+	})
+}
+
+func TestDeliverQueued(t *testing.T) {
+	c, repo := setup(t)
+	sender := mock.NewMockSender(gomock.NewController(t))
+
+	cfg := &configuration.SMSConfig{
+		OutboxBatchSize:   2,
+		OutboxLease:       time.Second * 30,
+		OutboxMaxAttempts: 3,
+		OutboxBackoff:     time.Second,
+		OutboxMaxBackoff:  time.Minute,
+	}
+	queued := func(id, attempts int) domain.SMS {
+		return domain.SMS{ID: id, Recipient: "77778889966", Text: "code", Attempts: attempts, ExpiresAt: time.Now().Add(time.Minute)}
+	}
+
+	t.Run("sent", func(t *testing.T) {
+		repo.EXPECT().ClaimQueued(c, 2, cfg.OutboxLease).Return([]domain.SMS{queued(1, 0)}, nil)
+		sender.EXPECT().Send(gomock.Any(), gomock.Any()).DoAndReturn(func(c context.Context, message *domain.SMS) error {
+			assert.Equal(t, "77778889966", message.Recipient)
+			message.Provider, message.ProviderMessageID = "mobizon", "25"
+			return nil
+		})
+		repo.EXPECT().MarkSent(c, gomock.Any()).DoAndReturn(func(c context.Context, message *domain.SMS) error {
+			assert.Equal(t, 1, message.ID)
+			assert.Equal(t, "mobizon", message.Provider)
+			assert.Equal(t, "25", message.ProviderMessageID)
+			return nil
+		})
+
+		service := New(cfg, repo, sender)
+
+		sent, dead, err := service.DeliverQueued(c)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, sent)
+		assert.Equal(t, 0, dead)
+	})
+
+	t.Run("full batch is followed by the next one", func(t *testing.T) {
+		repo.EXPECT().ClaimQueued(c, 2, cfg.OutboxLease).Return([]domain.SMS{queued(1, 0), queued(2, 0)}, nil)
+		repo.EXPECT().ClaimQueued(c, 2, cfg.OutboxLease).Return([]domain.SMS{}, nil)
+		sender.EXPECT().Send(gomock.Any(), gomock.Any()).Return(nil).Times(2)
+		repo.EXPECT().MarkSent(c, gomock.Any()).Return(nil).Times(2)
+
+		service := New(cfg, repo, sender)
+
+		sent, _, err := service.DeliverQueued(c)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, sent)
+	})
+
+	t.Run("failed attempt is retried later", func(t *testing.T) {
+		repo.EXPECT().ClaimQueued(c, 2, cfg.OutboxLease).Return([]domain.SMS{queued(1, 1)}, nil)
+		sender.EXPECT().Send(gomock.Any(), gomock.Any()).Return(errors.New("unavailable"))
+		repo.EXPECT().MarkFailed(c, 1, gomock.Any(), "unavailable").DoAndReturn(func(c context.Context, id int, nextAttemptAt time.Time, lastError string) error {
+			// the second failure waits twice the backoff
+			assert.WithinDuration(t, time.Now().Add(time.Second*2), nextAttemptAt, time.Second)
+			return nil
+		})
+
+		service := New(cfg, repo, sender)
+
+		sent, dead, err := service.DeliverQueued(c)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, sent)
+		assert.Equal(t, 0, dead)
+	})
+
+	t.Run("last attempt failed", func(t *testing.T) {
+		repo.EXPECT().ClaimQueued(c, 2, cfg.OutboxLease).Return([]domain.SMS{queued(1, 2)}, nil)
+		sender.EXPECT().Send(gomock.Any(), gomock.Any()).Return(errors.New("unavailable"))
+		repo.EXPECT().MarkDead(c, 1, 3, "unavailable").Return(nil)
+
+		service := New(cfg, repo, sender)
+
+		_, dead, err := service.DeliverQueued(c)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, dead)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		message := queued(1, 1)
+		message.ExpiresAt = time.Now().Add(-time.Minute)
+		repo.EXPECT().ClaimQueued(c, 2, cfg.OutboxLease).Return([]domain.SMS{message}, nil)
+		repo.EXPECT().MarkDead(c, 1, 1, gomock.Any()).Return(nil)
+
+		service := New(cfg, repo, sender)
+
+		_, dead, err := service.DeliverQueued(c)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, dead)
+	})
+
+	t.Run("undecryptable", func(t *testing.T) {
+		message := queued(1, 0)
+		message.Text = ""
+		repo.EXPECT().ClaimQueued(c, 2, cfg.OutboxLease).Return([]domain.SMS{message}, nil)
+		repo.EXPECT().MarkDead(c, 1, 0, gomock.Any()).Return(nil)
+
+		service := New(cfg, repo, sender)
+
+		_, dead, err := service.DeliverQueued(c)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, dead)
+	})
+}
+
+func TestPollStatuses(t *testing.T) {
+	c, repo := setup(t)
+	sender := mock.NewMockSender(gomock.NewController(t))
+
+	cfg := &configuration.SMSConfig{
+		OutboxBatchSize:    10,
+		StatusPollInterval: time.Minute,
+		StatusPollWindow:   time.Hour,
+	}
+
+	t.Run("success", func(t *testing.T) {
+		repo.EXPECT().FindUnconfirmed(c, gomock.Any(), gomock.Any(), 10).Return([]domain.SMS{
+			{ID: 1, Recipient: "77778889966", Provider: "mobizon", ProviderMessageID: "25"},
+			{ID: 2, Recipient: "77778889955", Provider: "smsc", ProviderMessageID: "7"},
+			{ID: 3, Recipient: "77778889944", Provider: "smsc", ProviderMessageID: "8"},
+		}, nil)
+		sender.EXPECT().Status(c, "mobizon", "25", "77778889966").Return(domain.SMSDelivered, nil)
+		sender.EXPECT().Status(c, "smsc", "7", "77778889955").Return(domain.SMSSent, nil)
+		sender.EXPECT().Status(c, "smsc", "8", "77778889944").Return(domain.SMSStatus(""), errors.New("unavailable"))
+		repo.EXPECT().UpdateStatus(c, 1, domain.SMSDelivered).Return(nil)
+		repo.EXPECT().UpdateStatus(c, 2, domain.SMSSent).Return(nil)
+		repo.EXPECT().UpdateStatus(c, 3, domain.SMSSent).Return(nil)
+
+		service := New(cfg, repo, sender)
+
+		updated, err := service.PollStatuses(c)
+		assert.ErrorContains(t, err, "unavailable")
+		assert.Equal(t, 1, updated)
+	})
+}
+
+func TestUpdateStatus(t *testing.T) {
+	c, repo := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo.EXPECT().UpdateStatusByProviderID(c, "smsc", "7", domain.SMSFailed).Return(nil)
+
+		service := New(&configuration.SMSConfig{}, repo, nil)
+
+		assert.NoError(t, service.UpdateStatus(c, "smsc", "7", domain.SMSFailed))
+	})
+
+	t.Run("unknown message", func(t *testing.T) {
+		repo.EXPECT().UpdateStatusByProviderID(c, "smsc", "8", domain.SMSFailed).Return(pgx.ErrNoRows)
+
+		service := New(&configuration.SMSConfig{}, repo, nil)
+
+		assert.ErrorIs(t, service.UpdateStatus(c, "smsc", "8", domain.SMSFailed), domain.ErrSMSNotFound)
+	})
+}
+
+func TestDeliveryStatus(t *testing.T) {
+	c, repo := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo.EXPECT().FindLatestByRecipient(c, "77778889966").Return(&domain.SMS{ID: 1, Status: domain.SMSDelivered}, nil)
+
+		service := New(&configuration.SMSConfig{}, repo, nil)
+
+		message, err := service.DeliveryStatus(c, "7778889966")
+		assert.NoError(t, err)
+		assert.Equal(t, domain.SMSDelivered, message.Status)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		repo.EXPECT().FindLatestByRecipient(c, "77778889955").Return(nil, pgx.ErrNoRows)
+
+		service := New(&configuration.SMSConfig{}, repo, nil)
+
+		_, err := service.DeliveryStatus(c, "7778889955")
+		assert.ErrorIs(t, err, domain.ErrSMSNotFound)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	service := New(&configuration.SMSConfig{OutboxBackoff: time.Second, OutboxMaxBackoff: time.Second * 5}, nil, nil)
+
+	assert.Equal(t, time.Second, service.backoff(1))
+	assert.Equal(t, time.Second*2, service.backoff(2))
+	assert.Equal(t, time.Second*4, service.backoff(3))
+	assert.Equal(t, time.Second*5, service.backoff(4))
+	assert.Equal(t, time.Second*5, service.backoff(20))
+}
+
+func TestCooldown(t *testing.T) {
+	service := New(&configuration.SMSConfig{PhoneCooldown: time.Minute, PhoneWindow: time.Minute * 30}, nil, nil)
+
+	assert.Equal(t, time.Minute, service.cooldown(1))
+	assert.Equal(t, time.Minute*2, service.cooldown(2))
+	assert.Equal(t, time.Minute*16, service.cooldown(5))
+	assert.Equal(t, time.Minute*30, service.cooldown(6))
+	assert.Equal(t, time.Minute*30, service.cooldown(100))
+}
+
+func TestCodeMessage(t *testing.T) {
+	t.Run("localized", func(t *testing.T) {
+		service := New(&configuration.SMSConfig{AppDomain: "mangahana.com"}, nil, nil)
+
+		assert.Equal(t, "mangahana.com\nКод подтверждения: 1234\n\n@mangahana.com #1234", service.codeMessage(domain.LocaleRussian, "1234"))
+		assert.Contains(t, service.codeMessage(domain.LocaleEnglish, "1234"), "Verification code: 1234")
+		assert.Contains(t, service.codeMessage(domain.Locale("de"), "1234"), "Растау коды: 1234")
+	})
+
+	t.Run("android app hash", func(t *testing.T) {
+		service := New(&configuration.SMSConfig{AppDomain: "mangahana.com", AndroidAppHash: "FA+9qCX9VSu"}, nil, nil)
+
+		assert.Equal(t, "mangahana.com\nРастау коды: 1234\nFA+9qCX9VSu\n\n@mangahana.com #1234", service.codeMessage(domain.LocaleKazakh, "1234"))
+	})
+}
+
+func TestPurgeExpired(t *testing.T) {
+	c, repo := setup(t)
+
+	cfg := &configuration.SMSConfig{
+		CodeRetention:   time.Hour * 24,
+		PhoneWindow:     time.Minute * 30,
+		PhoneLockWindow: time.Hour,
+		PurgeBatchSize:  2,
+	}
+
+	t.Run("success", func(t *testing.T) {
+		before := func(c context.Context, before time.Time, limit int) {
+			assert.WithinDuration(t, time.Now().Add(-time.Hour*24), before, time.Second)
+			assert.Equal(t, 2, limit)
+		}
+		// a full batch is followed by the next one
+		repo.EXPECT().RemoveExpired(c, gomock.Any(), 2).Do(before).Return(int64(2), nil)
+		repo.EXPECT().RemoveExpired(c, gomock.Any(), 2).Do(before).Return(int64(1), nil)
+		repo.EXPECT().RemoveFailures(c, gomock.Any(), 2).Do(before).Return(int64(0), nil)
+		repo.EXPECT().RemoveFinished(c, gomock.Any(), 2).Do(before).Return(int64(1), nil)
+
+		service := New(cfg, repo, nil)
+
+		codes, failures, messages, err := service.PurgeExpired(c)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), codes)
+		assert.Equal(t, int64(0), failures)
+		assert.Equal(t, int64(1), messages)
+	})
+
+	t.Run("retention shorter than limits", func(t *testing.T) {
+		short := *cfg
+		short.CodeRetention = time.Minute
+		repo.EXPECT().RemoveExpired(c, gomock.Any(), 2).Do(func(c context.Context, before time.Time, limit int) {
+			// failures within the phone lock window are still counted
+			assert.WithinDuration(t, time.Now().Add(-time.Hour), before, time.Second)
+		}).Return(int64(0), nil)
+		repo.EXPECT().RemoveFailures(c, gomock.Any(), 2).Return(int64(0), nil)
+		repo.EXPECT().RemoveFinished(c, gomock.Any(), 2).Return(int64(0), nil)
+
+		service := New(&short, repo, nil)
+
+		_, _, _, err := service.PurgeExpired(c)
+		assert.NoError(t, err)
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		stopped, stop := context.WithCancel(c)
+		repo.EXPECT().RemoveExpired(stopped, gomock.Any(), 2).DoAndReturn(func(c context.Context, before time.Time, limit int) (int64, error) {
+			stop()
+			return 2, nil
+		})
+
+		service := New(cfg, repo, nil)
+
+		codes, _, _, err := service.PurgeExpired(stopped)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, int64(2), codes)
+	})
 
-		service := New(&configuration.SMSConfig{}, repo)
+	t.Run("no batch size", func(t *testing.T) {
+		empty := *cfg
+		empty.PurgeBatchSize = 0
+		// LIMIT 0 removes nothing, which must not look like a full batch
+		repo.EXPECT().RemoveExpired(c, gomock.Any(), 0).Return(int64(0), nil)
+		repo.EXPECT().RemoveFailures(c, gomock.Any(), 0).Return(int64(0), nil)
+		repo.EXPECT().RemoveFinished(c, gomock.Any(), 0).Return(int64(0), nil)
 
-		err := service.RemoveAll(c, "7778889955")
+		service := New(&empty, repo, nil)
 
+		_, _, _, err := service.PurgeExpired(c)
 		assert.NoError(t, err)
 	})
 }
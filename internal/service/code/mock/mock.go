@@ -42,75 +42,360 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 	return m.recorder
 }
 
+// AddAttempt mocks base method.
+func (m *MockRepository) AddAttempt(c context.Context, id, maxAttempts int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAttempt", c, id, maxAttempts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddAttempt indicates an expected call of AddAttempt.
+func (mr *MockRepositoryMockRecorder) AddAttempt(c, id, maxAttempts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAttempt", reflect.TypeOf((*MockRepository)(nil).AddAttempt), c, id, maxAttempts)
+}
+
+// ClaimQueued mocks base method.
+func (m *MockRepository) ClaimQueued(c context.Context, limit int, lease time.Duration) ([]domain.SMS, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimQueued", c, limit, lease)
+	ret0, _ := ret[0].([]domain.SMS)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimQueued indicates an expected call of ClaimQueued.
+func (mr *MockRepositoryMockRecorder) ClaimQueued(c, limit, lease any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimQueued", reflect.TypeOf((*MockRepository)(nil).ClaimQueued), c, limit, lease)
+}
+
+// CountFailures mocks base method.
+func (m *MockRepository) CountFailures(c context.Context, phone string, purpose domain.CodePurpose, since time.Time) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountFailures", c, phone, purpose, since)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountFailures indicates an expected call of CountFailures.
+func (mr *MockRepositoryMockRecorder) CountFailures(c, phone, purpose, since any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountFailures", reflect.TypeOf((*MockRepository)(nil).CountFailures), c, phone, purpose, since)
+}
+
 // FindLatestByIP mocks base method.
-func (m *MockRepository) FindLatestByIP(c context.Context, ip string, timestamp time.Time) ([]domain.Code, error) {
+func (m *MockRepository) FindLatestByIP(c context.Context, ip string, purpose domain.CodePurpose, timestamp time.Time) ([]domain.Code, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindLatestByIP", c, ip, timestamp)
+	ret := m.ctrl.Call(m, "FindLatestByIP", c, ip, purpose, timestamp)
 	ret0, _ := ret[0].([]domain.Code)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindLatestByIP indicates an expected call of FindLatestByIP.
-func (mr *MockRepositoryMockRecorder) FindLatestByIP(c, ip, timestamp any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) FindLatestByIP(c, ip, purpose, timestamp any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindLatestByIP", reflect.TypeOf((*MockRepository)(nil).FindLatestByIP), c, ip, purpose, timestamp)
+}
+
+// FindLatestByPhone mocks base method.
+func (m *MockRepository) FindLatestByPhone(c context.Context, phone string, purpose domain.CodePurpose, timestamp time.Time) ([]domain.Code, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindLatestByPhone", c, phone, purpose, timestamp)
+	ret0, _ := ret[0].([]domain.Code)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindLatestByPhone indicates an expected call of FindLatestByPhone.
+func (mr *MockRepositoryMockRecorder) FindLatestByPhone(c, phone, purpose, timestamp any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindLatestByIP", reflect.TypeOf((*MockRepository)(nil).FindLatestByIP), c, ip, timestamp)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindLatestByPhone", reflect.TypeOf((*MockRepository)(nil).FindLatestByPhone), c, phone, purpose, timestamp)
 }
 
-// FindOneByCredentials mocks base method.
-func (m *MockRepository) FindOneByCredentials(c context.Context, phone, code string) (*domain.Code, error) {
+// FindLatestByRecipient mocks base method.
+func (m *MockRepository) FindLatestByRecipient(c context.Context, recipient string) (*domain.SMS, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindOneByCredentials", c, phone, code)
+	ret := m.ctrl.Call(m, "FindLatestByRecipient", c, recipient)
+	ret0, _ := ret[0].(*domain.SMS)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindLatestByRecipient indicates an expected call of FindLatestByRecipient.
+func (mr *MockRepositoryMockRecorder) FindLatestByRecipient(c, recipient any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindLatestByRecipient", reflect.TypeOf((*MockRepository)(nil).FindLatestByRecipient), c, recipient)
+}
+
+// FindNewestByPhone mocks base method.
+func (m *MockRepository) FindNewestByPhone(c context.Context, phone string, purpose domain.CodePurpose) (*domain.Code, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindNewestByPhone", c, phone, purpose)
 	ret0, _ := ret[0].(*domain.Code)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// FindOneByCredentials indicates an expected call of FindOneByCredentials.
-func (mr *MockRepositoryMockRecorder) FindOneByCredentials(c, phone, code any) *gomock.Call {
+// FindNewestByPhone indicates an expected call of FindNewestByPhone.
+func (mr *MockRepositoryMockRecorder) FindNewestByPhone(c, phone, purpose any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOneByCredentials", reflect.TypeOf((*MockRepository)(nil).FindOneByCredentials), c, phone, code)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindNewestByPhone", reflect.TypeOf((*MockRepository)(nil).FindNewestByPhone), c, phone, purpose)
 }
 
 // FindOneByPhoneAndIP mocks base method.
-func (m *MockRepository) FindOneByPhoneAndIP(c context.Context, phone, ip string) (*domain.Code, error) {
+func (m *MockRepository) FindOneByPhoneAndIP(c context.Context, phone, ip string, purpose domain.CodePurpose) (*domain.Code, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "FindOneByPhoneAndIP", c, phone, ip)
+	ret := m.ctrl.Call(m, "FindOneByPhoneAndIP", c, phone, ip, purpose)
 	ret0, _ := ret[0].(*domain.Code)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // FindOneByPhoneAndIP indicates an expected call of FindOneByPhoneAndIP.
-func (mr *MockRepositoryMockRecorder) FindOneByPhoneAndIP(c, phone, ip any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) FindOneByPhoneAndIP(c, phone, ip, purpose any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOneByPhoneAndIP", reflect.TypeOf((*MockRepository)(nil).FindOneByPhoneAndIP), c, phone, ip, purpose)
+}
+
+// FindUnconfirmed mocks base method.
+func (m *MockRepository) FindUnconfirmed(c context.Context, sentAfter, checkedBefore time.Time, limit int) ([]domain.SMS, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindUnconfirmed", c, sentAfter, checkedBefore, limit)
+	ret0, _ := ret[0].([]domain.SMS)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindUnconfirmed indicates an expected call of FindUnconfirmed.
+func (mr *MockRepositoryMockRecorder) FindUnconfirmed(c, sentAfter, checkedBefore, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindUnconfirmed", reflect.TypeOf((*MockRepository)(nil).FindUnconfirmed), c, sentAfter, checkedBefore, limit)
+}
+
+// MarkDead mocks base method.
+func (m *MockRepository) MarkDead(c context.Context, id, attempts int, lastError string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkDead", c, id, attempts, lastError)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkDead indicates an expected call of MarkDead.
+func (mr *MockRepositoryMockRecorder) MarkDead(c, id, attempts, lastError any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOneByPhoneAndIP", reflect.TypeOf((*MockRepository)(nil).FindOneByPhoneAndIP), c, phone, ip)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkDead", reflect.TypeOf((*MockRepository)(nil).MarkDead), c, id, attempts, lastError)
+}
+
+// MarkFailed mocks base method.
+func (m *MockRepository) MarkFailed(c context.Context, id int, nextAttemptAt time.Time, lastError string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkFailed", c, id, nextAttemptAt, lastError)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkFailed indicates an expected call of MarkFailed.
+func (mr *MockRepositoryMockRecorder) MarkFailed(c, id, nextAttemptAt, lastError any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkFailed", reflect.TypeOf((*MockRepository)(nil).MarkFailed), c, id, nextAttemptAt, lastError)
+}
+
+// MarkSent mocks base method.
+func (m *MockRepository) MarkSent(c context.Context, message *domain.SMS) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkSent", c, message)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkSent indicates an expected call of MarkSent.
+func (mr *MockRepositoryMockRecorder) MarkSent(c, message any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkSent", reflect.TypeOf((*MockRepository)(nil).MarkSent), c, message)
 }
 
 // RemoveAll mocks base method.
-func (m *MockRepository) RemoveAll(c context.Context, phone string) error {
+func (m *MockRepository) RemoveAll(c context.Context, phone string, purpose domain.CodePurpose) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "RemoveAll", c, phone)
+	ret := m.ctrl.Call(m, "RemoveAll", c, phone, purpose)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // RemoveAll indicates an expected call of RemoveAll.
-func (mr *MockRepositoryMockRecorder) RemoveAll(c, phone any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) RemoveAll(c, phone, purpose any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveAll", reflect.TypeOf((*MockRepository)(nil).RemoveAll), c, phone, purpose)
+}
+
+// RemoveAttempt mocks base method.
+func (m *MockRepository) RemoveAttempt(c context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveAttempt", c, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveAttempt indicates an expected call of RemoveAttempt.
+func (mr *MockRepositoryMockRecorder) RemoveAttempt(c, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveAttempt", reflect.TypeOf((*MockRepository)(nil).RemoveAttempt), c, id)
+}
+
+// RemoveExpired mocks base method.
+func (m *MockRepository) RemoveExpired(c context.Context, before time.Time, limit int) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveExpired", c, before, limit)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveExpired indicates an expected call of RemoveExpired.
+func (mr *MockRepositoryMockRecorder) RemoveExpired(c, before, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveExpired", reflect.TypeOf((*MockRepository)(nil).RemoveExpired), c, before, limit)
+}
+
+// RemoveFailures mocks base method.
+func (m *MockRepository) RemoveFailures(c context.Context, before time.Time, limit int) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveFailures", c, before, limit)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveFailures indicates an expected call of RemoveFailures.
+func (mr *MockRepositoryMockRecorder) RemoveFailures(c, before, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveFailures", reflect.TypeOf((*MockRepository)(nil).RemoveFailures), c, before, limit)
+}
+
+// RemoveFinished mocks base method.
+func (m *MockRepository) RemoveFinished(c context.Context, before time.Time, limit int) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveFinished", c, before, limit)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveFinished indicates an expected call of RemoveFinished.
+func (mr *MockRepositoryMockRecorder) RemoveFinished(c, before, limit any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveAll", reflect.TypeOf((*MockRepository)(nil).RemoveAll), c, phone)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveFinished", reflect.TypeOf((*MockRepository)(nil).RemoveFinished), c, before, limit)
 }
 
 // Save mocks base method.
-func (m *MockRepository) Save(c context.Context, code *domain.Code) error {
+func (m *MockRepository) Save(c context.Context, code *domain.Code, message *domain.SMS) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Save", c, code)
+	ret := m.ctrl.Call(m, "Save", c, code, message)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Save indicates an expected call of Save.
-func (mr *MockRepositoryMockRecorder) Save(c, code any) *gomock.Call {
+func (mr *MockRepositoryMockRecorder) Save(c, code, message any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockRepository)(nil).Save), c, code, message)
+}
+
+// SaveFailure mocks base method.
+func (m *MockRepository) SaveFailure(c context.Context, phone string, purpose domain.CodePurpose) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SaveFailure", c, phone, purpose)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SaveFailure indicates an expected call of SaveFailure.
+func (mr *MockRepositoryMockRecorder) SaveFailure(c, phone, purpose any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SaveFailure", reflect.TypeOf((*MockRepository)(nil).SaveFailure), c, phone, purpose)
+}
+
+// UpdateStatus mocks base method.
+func (m *MockRepository) UpdateStatus(c context.Context, id int, status domain.SMSStatus) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateStatus", c, id, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateStatus indicates an expected call of UpdateStatus.
+func (mr *MockRepositoryMockRecorder) UpdateStatus(c, id, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatus", reflect.TypeOf((*MockRepository)(nil).UpdateStatus), c, id, status)
+}
+
+// UpdateStatusByProviderID mocks base method.
+func (m *MockRepository) UpdateStatusByProviderID(c context.Context, provider, providerMessageId string, status domain.SMSStatus) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateStatusByProviderID", c, provider, providerMessageId, status)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateStatusByProviderID indicates an expected call of UpdateStatusByProviderID.
+func (mr *MockRepositoryMockRecorder) UpdateStatusByProviderID(c, provider, providerMessageId, status any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateStatusByProviderID", reflect.TypeOf((*MockRepository)(nil).UpdateStatusByProviderID), c, provider, providerMessageId, status)
+}
+
+// MockSender is a mock of Sender interface.
+type MockSender struct {
+	ctrl     *gomock.Controller
+	recorder *MockSenderMockRecorder
+	isgomock struct{}
+}
+
+// MockSenderMockRecorder is the mock recorder for MockSender.
+type MockSenderMockRecorder struct {
+	mock *MockSender
+}
+
+// NewMockSender creates a new mock instance.
+func NewMockSender(ctrl *gomock.Controller) *MockSender {
+	mock := &MockSender{ctrl: ctrl}
+	mock.recorder = &MockSenderMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSender) EXPECT() *MockSenderMockRecorder {
+	return m.recorder
+}
+
+// Send mocks base method.
+func (m *MockSender) Send(c context.Context, message *domain.SMS) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", c, message)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockSenderMockRecorder) Send(c, message any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockSender)(nil).Send), c, message)
+}
+
+// Status mocks base method.
+func (m *MockSender) Status(c context.Context, provider, messageId, recipient string) (domain.SMSStatus, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Status", c, provider, messageId, recipient)
+	ret0, _ := ret[0].(domain.SMSStatus)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Status indicates an expected call of Status.
+func (mr *MockSenderMockRecorder) Status(c, provider, messageId, recipient any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockRepository)(nil).Save), c, code)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Status", reflect.TypeOf((*MockSender)(nil).Status), c, provider, messageId, recipient)
 }
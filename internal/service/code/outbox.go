@@ -0,0 +1,133 @@
+package code
+
+import (
+	"account/internal/domain"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// DeliverQueued sends queued messages until the outbox has none due.
+// A failed message is retried with exponential backoff and marked dead
+// after outboxMaxAttempts attempts or once it has expired.
+func (s *service) DeliverQueued(c context.Context) (sent, dead int, err error) {
+	for c.Err() == nil {
+		messages, err := s.repo.ClaimQueued(c, s.outboxBatchSize, s.outboxLease)
+		if err != nil {
+			return sent, dead, err
+		}
+
+		for _, message := range messages {
+			if message.IsExpired(time.Now().UTC()) {
+				if err := s.repo.MarkDead(c, message.ID, message.Attempts, "expired before delivery"); err != nil {
+					return sent, dead, err
+				}
+				dead++
+				continue
+			}
+			if message.Text == "" {
+				// e.g. the code secret was changed while the message was queued
+				if err := s.repo.MarkDead(c, message.ID, message.Attempts, "text can't be decrypted"); err != nil {
+					return sent, dead, err
+				}
+				dead++
+				continue
+			}
+
+			sendCtx, cancel := context.WithTimeout(c, s.outboxLease)
+			sendErr := s.sender.Send(sendCtx, &message)
+			cancel()
+
+			if sendErr == nil {
+				if err := s.repo.MarkSent(c, &message); err != nil {
+					return sent, dead, err
+				}
+				sent++
+				continue
+			}
+
+			attempts := message.Attempts + 1
+			if attempts >= s.outboxMaxAttempts {
+				if err := s.repo.MarkDead(c, message.ID, attempts, sendErr.Error()); err != nil {
+					return sent, dead, err
+				}
+				dead++
+				continue
+			}
+
+			if err := s.repo.MarkFailed(c, message.ID, time.Now().UTC().Add(s.backoff(attempts)), sendErr.Error()); err != nil {
+				return sent, dead, err
+			}
+		}
+
+		if len(messages) < s.outboxBatchSize {
+			break
+		}
+	}
+
+	return sent, dead, nil
+}
+
+// backoff is the pause after the given number of failed attempts
+func (s *service) backoff(attempts int) time.Duration {
+	delay := s.outboxBackoff
+	for i := 1; i < attempts && delay < s.outboxMaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, s.outboxMaxBackoff)
+}
+
+// PollStatuses asks providers about messages sent within statusPollWindow which
+// have no delivery report yet, every message is asked once per statusPollInterval.
+// Provider errors don't stop polling of other messages, they are joined into the result.
+func (s *service) PollStatuses(c context.Context) (int, error) {
+	now := time.Now().UTC()
+
+	messages, err := s.repo.FindUnconfirmed(c, now.Add(-s.statusPollWindow), now.Add(-s.statusPollInterval), s.outboxBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var updated int
+	var errs []error
+	for _, message := range messages {
+		status, err := s.sender.Status(c, message.Provider, message.ProviderMessageID, message.Recipient)
+		if err != nil {
+			errs = append(errs, err)
+			// the message is still marked as checked, so a failing provider isn't asked again right away
+			status = domain.SMSSent
+		}
+
+		if err := s.repo.UpdateStatus(c, message.ID, status); err != nil {
+			return updated, err
+		}
+		if status != domain.SMSSent {
+			updated++
+		}
+	}
+
+	return updated, errors.Join(errs...)
+}
+
+// UpdateStatus saves a delivery report sent by the provider of the message
+func (s *service) UpdateStatus(c context.Context, provider, providerMessageId string, status domain.SMSStatus) error {
+	err := s.repo.UpdateStatusByProviderID(c, provider, providerMessageId, status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrSMSNotFound
+	}
+	return err
+}
+
+// DeliveryStatus returns the last message sent to the phone
+func (s *service) DeliveryStatus(c context.Context, phone string) (*domain.SMS, error) {
+	message, err := s.repo.FindLatestByRecipient(c, "7"+phone)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrSMSNotFound
+		}
+		return nil, err
+	}
+	return message, nil
+}
@@ -0,0 +1,25 @@
+package code
+
+import (
+	"account/internal/service/purge"
+	"context"
+	"time"
+)
+
+// PurgeExpired removes codes which expired more than codeRetention ago, failures
+// and finished messages as old. Rows are removed in batches of purgeBatchSize,
+// so the tables aren't locked for long, and removal stops once c is done.
+func (s *service) PurgeExpired(c context.Context) (codes, failures, messages int64, err error) {
+	// codes and failures still counted by the limits are kept
+	before := time.Now().UTC().Add(-max(s.codeRetention, s.phoneWindow, s.phoneLockWindow, ipWindow))
+
+	if codes, err = purge.Batched(c, s.repo.RemoveExpired, before, s.purgeBatchSize); err != nil {
+		return codes, failures, messages, err
+	}
+	if failures, err = purge.Batched(c, s.repo.RemoveFailures, before, s.purgeBatchSize); err != nil {
+		return codes, failures, messages, err
+	}
+	messages, err = purge.Batched(c, s.repo.RemoveFinished, before, s.purgeBatchSize)
+
+	return codes, failures, messages, err
+}
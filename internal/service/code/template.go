@@ -0,0 +1,32 @@
+package code
+
+import (
+	"account/internal/domain"
+	"strings"
+)
+
+var codeLabels = map[domain.Locale]string{
+	domain.LocaleKazakh:  "Растау коды",
+	domain.LocaleRussian: "Код подтверждения",
+	domain.LocaleEnglish: "Verification code",
+}
+
+// codeMessage is the sms with a verification code. It ends with hints
+// for apps to autofill the code: the app hash for Android SMS Retriever
+// and the "@domain #code" line for iOS one-time-code fields.
+func (s *service) codeMessage(locale domain.Locale, code string) string {
+	label, ok := codeLabels[locale]
+	if !ok {
+		label = codeLabels[domain.DefaultLocale]
+	}
+
+	var b strings.Builder
+	b.WriteString(s.appDomain + "\n")
+	b.WriteString(label + ": " + code + "\n")
+	if s.androidAppHash != "" {
+		b.WriteString(s.androidAppHash + "\n")
+	}
+	b.WriteString("\n@" + s.appDomain + " #" + code)
+
+	return b.String()
+}
@@ -0,0 +1,180 @@
+package email
+
+import (
+	"account/internal/domain"
+	"account/internal/infrastructure/configuration"
+	"account/internal/service/purge"
+	"context"
+	"crypto/hmac"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+//go:generate mockgen -source ./email.go -destination ./mock/mock.go -package mock
+type Repository interface {
+	FindLatestByUser(c context.Context, userId int, timestamp time.Time) ([]domain.EmailCode, error)
+	FindNewestByUser(c context.Context, userId int) (*domain.EmailCode, error)
+
+	Save(c context.Context, code *domain.EmailCode) error
+	AddAttempt(c context.Context, id, maxAttempts int) error
+
+	RemoveAll(c context.Context, userId int) error
+	RemoveExpired(c context.Context, before time.Time, limit int) (int64, error)
+}
+
+type Mailer interface {
+	Send(c context.Context, to, subject, body string) error
+}
+
+type service struct {
+	secret     []byte
+	codePolicy domain.CodePolicy
+
+	codeMaxAttempts int
+
+	maxCodes int
+	window   time.Duration
+	cooldown time.Duration
+
+	timeout time.Duration
+
+	purgeBatchSize int
+
+	repo   Repository
+	mailer Mailer
+}
+
+// New takes the secret of code digests, it's shared with sms codes.
+// Codes can't be sent or verified if mailer is nil.
+func New(cfg *configuration.MailConfig, secret string, repo Repository, mailer Mailer) *service {
+	return &service{
+		secret:     []byte(secret),
+		codePolicy: Policy(cfg),
+
+		codeMaxAttempts: cfg.CodeMaxAttempts,
+
+		maxCodes: cfg.MaxCodes,
+		window:   cfg.Window,
+		cooldown: cfg.Cooldown,
+
+		timeout: cfg.Timeout,
+
+		purgeBatchSize: cfg.PurgeBatchSize,
+
+		repo:   repo,
+		mailer: mailer,
+	}
+}
+
+// Policy is the code generation policy of the configuration, it should be validated on start
+func Policy(cfg *configuration.MailConfig) domain.CodePolicy {
+	return domain.CodePolicy{
+		Length:   cfg.CodeLength,
+		Alphabet: "0123456789",
+		TTL:      cfg.CodeTTL,
+	}
+}
+
+// Send mails a code confirming that the email belongs to the user. At most maxCodes
+// are sent to a user within window, and the pause before the next code doubles with every code sent up to window.
+func (s *service) Send(c context.Context, userId int, email string, locale domain.Locale) error {
+	if s.mailer == nil {
+		return domain.ErrEmailDisabled
+	}
+
+	now := time.Now().UTC()
+
+	codes, err := s.repo.FindLatestByUser(c, userId, now.Add(-s.window))
+	if err != nil {
+		return err
+	}
+	if len(codes) >= s.maxCodes {
+		return domain.ErrTooManyCodesSent
+	}
+	if len(codes) > 0 {
+		if now.Sub(codes[0].CreatedAt.UTC()) < s.cooldownAfter(len(codes)) {
+			return domain.ErrCodeSendingLimit
+		}
+	}
+
+	code, err := domain.NewEmailCode(userId, email, s.codePolicy)
+	if err != nil {
+		return err
+	}
+
+	salt, err := domain.NewSalt()
+	if err != nil {
+		return err
+	}
+	code.Salt = salt
+	code.Hash = domain.HashCode(s.secret, code.Salt, code.Code)
+
+	if err := s.repo.Save(c, code); err != nil {
+		return err
+	}
+
+	subject, body := codeEmail(locale, code.Code)
+
+	sendCtx, cancel := context.WithTimeout(c, s.timeout)
+	defer cancel()
+
+	return s.mailer.Send(sendCtx, email, subject, body)
+}
+
+// cooldownAfter is the pause after the given number of codes sent within window
+func (s *service) cooldownAfter(sent int) time.Duration {
+	delay := s.cooldown
+	for i := 1; i < sent && delay < s.window; i++ {
+		delay *= 2
+	}
+	return min(delay, s.window)
+}
+
+// Verify checks the code against the last code sent to the user and returns the email it was sent to.
+// A code can be checked only codeMaxAttempts times.
+func (s *service) Verify(c context.Context, userId int, code string) (string, error) {
+	if s.mailer == nil {
+		return "", domain.ErrEmailDisabled
+	}
+
+	newest, err := s.repo.FindNewestByUser(c, userId)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", domain.ErrInvalidCode
+		}
+		return "", err
+	}
+
+	// the attempt is counted before the check, so concurrent guesses can't exceed the limit
+	if err := s.repo.AddAttempt(c, newest.ID, s.codeMaxAttempts); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", domain.ErrTooManyAttempts
+		}
+		return "", err
+	}
+
+	if !hmac.Equal([]byte(newest.Hash), []byte(domain.HashCode(s.secret, newest.Salt, code))) {
+		if newest.Attempts+1 >= s.codeMaxAttempts {
+			return "", domain.ErrTooManyAttempts
+		}
+		return "", domain.ErrInvalidCode
+	}
+
+	if newest.IsExpired(time.Now().UTC()) {
+		return "", domain.ErrCodeExpired
+	}
+
+	return newest.Email, nil
+}
+
+func (s *service) RemoveAll(c context.Context, userId int) error {
+	return s.repo.RemoveAll(c, userId)
+}
+
+// PurgeExpired removes codes which expired before window, so they are no longer counted by the limits.
+// Codes are removed in batches of purgeBatchSize and removal stops once c is done.
+func (s *service) PurgeExpired(c context.Context) (int64, error) {
+	return purge.Batched(c, s.repo.RemoveExpired, time.Now().UTC().Add(-s.window), s.purgeBatchSize)
+}
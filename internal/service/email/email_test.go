@@ -0,0 +1,207 @@
+package email
+
+import (
+	"account/internal/domain"
+	"account/internal/infrastructure/configuration"
+	"account/internal/service/email/mock"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/mock/gomock"
+)
+
+func setup(t *testing.T) (context.Context, *mock.MockRepository, *mock.MockMailer) {
+	ctx := context.Background()
+	ctrl := gomock.NewController(t)
+	repo := mock.NewMockRepository(ctrl)
+	mailer := mock.NewMockMailer(ctrl)
+	return ctx, repo, mailer
+}
+
+func TestSend(t *testing.T) {
+	c, repo, mailer := setup(t)
+
+	cfg := &configuration.MailConfig{
+		Timeout:    time.Second,
+		CodeLength: 6,
+		CodeTTL:    time.Minute * 30,
+		MaxCodes:   5,
+		Window:     time.Hour,
+		Cooldown:   time.Minute,
+	}
+
+	t.Run("success", func(t *testing.T) {
+		var sent *domain.EmailCode
+		repo.EXPECT().FindLatestByUser(c, 1, gomock.Any()).Return([]domain.EmailCode{}, nil)
+		repo.EXPECT().Save(c, gomock.Any()).DoAndReturn(func(c context.Context, code *domain.EmailCode) error {
+			assert.Equal(t, 1, code.UserID)
+			assert.Equal(t, "john@example.com", code.Email)
+			assert.Len(t, code.Code, 6)
+			assert.Equal(t, domain.HashCode([]byte("secret"), code.Salt, code.Code), code.Hash)
+			sent = code
+			return nil
+		})
+		mailer.EXPECT().Send(gomock.Any(), "john@example.com", "Подтверждение почты", gomock.Any()).DoAndReturn(func(c context.Context, to, subject, body string) error {
+			assert.Contains(t, body, sent.Code)
+			return nil
+		})
+
+		service := New(cfg, "secret", repo, mailer)
+
+		err := service.Send(c, 1, "john@example.com", domain.LocaleRussian)
+		assert.NoError(t, err)
+	})
+
+	t.Run("cooldown", func(t *testing.T) {
+		repo.EXPECT().FindLatestByUser(c, 1, gomock.Any()).Return([]domain.EmailCode{
+			{UserID: 1, CreatedAt: time.Now().Add(-time.Second * 30)},
+		}, nil)
+
+		service := New(cfg, "secret", repo, mailer)
+
+		err := service.Send(c, 1, "john@example.com", domain.LocaleRussian)
+		assert.ErrorIs(t, err, domain.ErrCodeSendingLimit)
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		codes := []domain.EmailCode{}
+		for i := range 5 {
+			codes = append(codes, domain.EmailCode{UserID: 1, CreatedAt: time.Now().Add(-time.Minute * time.Duration(40+i))})
+		}
+		repo.EXPECT().FindLatestByUser(c, 1, gomock.Any()).Return(codes, nil)
+
+		service := New(cfg, "secret", repo, mailer)
+
+		err := service.Send(c, 1, "john@example.com", domain.LocaleRussian)
+		assert.ErrorIs(t, err, domain.ErrTooManyCodesSent)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		service := New(cfg, "secret", repo, nil)
+
+		err := service.Send(c, 1, "john@example.com", domain.LocaleRussian)
+		assert.ErrorIs(t, err, domain.ErrEmailDisabled)
+	})
+}
+
+func TestVerify(t *testing.T) {
+	c, repo, mailer := setup(t)
+
+	cfg := &configuration.MailConfig{CodeMaxAttempts: 5}
+	// stored is the code "123456" as it's kept in the database
+	stored := func(attempts int, expiresAt time.Time) *domain.EmailCode {
+		return &domain.EmailCode{ID: 1, UserID: 1, Email: "john@example.com", Hash: domain.HashCode([]byte("secret"), "salt", "123456"), Salt: "salt", Attempts: attempts, ExpiresAt: expiresAt}
+	}
+
+	t.Run("success", func(t *testing.T) {
+		repo.EXPECT().FindNewestByUser(c, 1).Return(stored(0, time.Now().Add(time.Minute)), nil)
+		repo.EXPECT().AddAttempt(c, 1, 5).Return(nil)
+
+		service := New(cfg, "secret", repo, mailer)
+
+		email, err := service.Verify(c, 1, "123456")
+		assert.NoError(t, err)
+		assert.Equal(t, "john@example.com", email)
+	})
+
+	t.Run("wrong code", func(t *testing.T) {
+		repo.EXPECT().FindNewestByUser(c, 1).Return(stored(0, time.Now().Add(time.Minute)), nil)
+		repo.EXPECT().AddAttempt(c, 1, 5).Return(nil)
+
+		service := New(cfg, "secret", repo, mailer)
+
+		_, err := service.Verify(c, 1, "654321")
+		assert.ErrorIs(t, err, domain.ErrInvalidCode)
+	})
+
+	t.Run("last attempt", func(t *testing.T) {
+		repo.EXPECT().FindNewestByUser(c, 1).Return(stored(4, time.Now().Add(time.Minute)), nil)
+		repo.EXPECT().AddAttempt(c, 1, 5).Return(nil)
+
+		service := New(cfg, "secret", repo, mailer)
+
+		_, err := service.Verify(c, 1, "654321")
+		assert.ErrorIs(t, err, domain.ErrTooManyAttempts)
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		repo.EXPECT().FindNewestByUser(c, 1).Return(stored(5, time.Now().Add(time.Minute)), nil)
+		repo.EXPECT().AddAttempt(c, 1, 5).Return(pgx.ErrNoRows)
+
+		service := New(cfg, "secret", repo, mailer)
+
+		_, err := service.Verify(c, 1, "123456")
+		assert.ErrorIs(t, err, domain.ErrTooManyAttempts)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		repo.EXPECT().FindNewestByUser(c, 1).Return(stored(0, time.Now().Add(-time.Minute)), nil)
+		repo.EXPECT().AddAttempt(c, 1, 5).Return(nil)
+
+		service := New(cfg, "secret", repo, mailer)
+
+		_, err := service.Verify(c, 1, "123456")
+		assert.ErrorIs(t, err, domain.ErrCodeExpired)
+	})
+
+	t.Run("no code", func(t *testing.T) {
+		repo.EXPECT().FindNewestByUser(c, 1).Return(nil, pgx.ErrNoRows)
+
+		service := New(cfg, "secret", repo, mailer)
+
+		_, err := service.Verify(c, 1, "123456")
+		assert.ErrorIs(t, err, domain.ErrInvalidCode)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		service := New(cfg, "secret", repo, nil)
+
+		_, err := service.Verify(c, 1, "123456")
+		assert.ErrorIs(t, err, domain.ErrEmailDisabled)
+	})
+}
+
+func TestCooldownAfter(t *testing.T) {
+	service := New(&configuration.MailConfig{Cooldown: time.Minute, Window: time.Hour}, "secret", nil, nil)
+
+	assert.Equal(t, time.Minute, service.cooldownAfter(1))
+	assert.Equal(t, time.Minute*4, service.cooldownAfter(3))
+	assert.Equal(t, time.Minute*32, service.cooldownAfter(6))
+	assert.Equal(t, time.Hour, service.cooldownAfter(7))
+	assert.Equal(t, time.Hour, service.cooldownAfter(100))
+}
+
+func TestCodeEmail(t *testing.T) {
+	subject, body := codeEmail(domain.LocaleEnglish, "123456")
+	assert.Equal(t, "Email verification", subject)
+	assert.Contains(t, body, "123456")
+
+	// unsupported locales fall back to the default one
+	subject, _ = codeEmail(domain.Locale("de"), "123456")
+	assert.Equal(t, "Электрондық поштаны растау", subject)
+}
+
+func TestPurgeExpired(t *testing.T) {
+	c, repo, mailer := setup(t)
+
+	cfg := &configuration.MailConfig{Window: time.Hour, PurgeBatchSize: 2}
+
+	t.Run("success", func(t *testing.T) {
+		before := func(c context.Context, before time.Time, limit int) {
+			// codes within the window are still counted by the limits
+			assert.WithinDuration(t, time.Now().Add(-time.Hour), before, time.Second)
+		}
+		// a full batch is followed by the next one
+		repo.EXPECT().RemoveExpired(c, gomock.Any(), 2).Do(before).Return(int64(2), nil)
+		repo.EXPECT().RemoveExpired(c, gomock.Any(), 2).Do(before).Return(int64(1), nil)
+
+		service := New(cfg, "secret", repo, mailer)
+
+		count, err := service.PurgeExpired(c)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+	})
+}
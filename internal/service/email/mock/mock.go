@@ -0,0 +1,168 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: ./email.go
+//
+// Generated by this command:
+//
+//	mockgen -source ./email.go -destination ./mock/mock.go -package mock
+//
+
+// Package mock is a generated GoMock package.
+package mock
+
+import (
+	domain "account/internal/domain"
+	context "context"
+	reflect "reflect"
+	time "time"
+
+	gomock "go.uber.org/mock/gomock"
+)
+
+// MockRepository is a mock of Repository interface.
+type MockRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockRepositoryMockRecorder
+	isgomock struct{}
+}
+
+// MockRepositoryMockRecorder is the mock recorder for MockRepository.
+type MockRepositoryMockRecorder struct {
+	mock *MockRepository
+}
+
+// NewMockRepository creates a new mock instance.
+func NewMockRepository(ctrl *gomock.Controller) *MockRepository {
+	mock := &MockRepository{ctrl: ctrl}
+	mock.recorder = &MockRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
+	return m.recorder
+}
+
+// AddAttempt mocks base method.
+func (m *MockRepository) AddAttempt(c context.Context, id, maxAttempts int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddAttempt", c, id, maxAttempts)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AddAttempt indicates an expected call of AddAttempt.
+func (mr *MockRepositoryMockRecorder) AddAttempt(c, id, maxAttempts any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddAttempt", reflect.TypeOf((*MockRepository)(nil).AddAttempt), c, id, maxAttempts)
+}
+
+// FindLatestByUser mocks base method.
+func (m *MockRepository) FindLatestByUser(c context.Context, userId int, timestamp time.Time) ([]domain.EmailCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindLatestByUser", c, userId, timestamp)
+	ret0, _ := ret[0].([]domain.EmailCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindLatestByUser indicates an expected call of FindLatestByUser.
+func (mr *MockRepositoryMockRecorder) FindLatestByUser(c, userId, timestamp any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindLatestByUser", reflect.TypeOf((*MockRepository)(nil).FindLatestByUser), c, userId, timestamp)
+}
+
+// FindNewestByUser mocks base method.
+func (m *MockRepository) FindNewestByUser(c context.Context, userId int) (*domain.EmailCode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindNewestByUser", c, userId)
+	ret0, _ := ret[0].(*domain.EmailCode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindNewestByUser indicates an expected call of FindNewestByUser.
+func (mr *MockRepositoryMockRecorder) FindNewestByUser(c, userId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindNewestByUser", reflect.TypeOf((*MockRepository)(nil).FindNewestByUser), c, userId)
+}
+
+// RemoveAll mocks base method.
+func (m *MockRepository) RemoveAll(c context.Context, userId int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveAll", c, userId)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveAll indicates an expected call of RemoveAll.
+func (mr *MockRepositoryMockRecorder) RemoveAll(c, userId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveAll", reflect.TypeOf((*MockRepository)(nil).RemoveAll), c, userId)
+}
+
+// RemoveExpired mocks base method.
+func (m *MockRepository) RemoveExpired(c context.Context, before time.Time, limit int) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveExpired", c, before, limit)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveExpired indicates an expected call of RemoveExpired.
+func (mr *MockRepositoryMockRecorder) RemoveExpired(c, before, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveExpired", reflect.TypeOf((*MockRepository)(nil).RemoveExpired), c, before, limit)
+}
+
+// Save mocks base method.
+func (m *MockRepository) Save(c context.Context, code *domain.EmailCode) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Save", c, code)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Save indicates an expected call of Save.
+func (mr *MockRepositoryMockRecorder) Save(c, code any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Save", reflect.TypeOf((*MockRepository)(nil).Save), c, code)
+}
+
+// MockMailer is a mock of Mailer interface.
+type MockMailer struct {
+	ctrl     *gomock.Controller
+	recorder *MockMailerMockRecorder
+	isgomock struct{}
+}
+
+// MockMailerMockRecorder is the mock recorder for MockMailer.
+type MockMailerMockRecorder struct {
+	mock *MockMailer
+}
+
+// NewMockMailer creates a new mock instance.
+func NewMockMailer(ctrl *gomock.Controller) *MockMailer {
+	mock := &MockMailer{ctrl: ctrl}
+	mock.recorder = &MockMailerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockMailer) EXPECT() *MockMailerMockRecorder {
+	return m.recorder
+}
+
+// Send mocks base method.
+func (m *MockMailer) Send(c context.Context, to, subject, body string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Send", c, to, subject, body)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Send indicates an expected call of Send.
+func (mr *MockMailerMockRecorder) Send(c, to, subject, body any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Send", reflect.TypeOf((*MockMailer)(nil).Send), c, to, subject, body)
+}
@@ -0,0 +1,35 @@
+package email
+
+import (
+	"account/internal/domain"
+	"fmt"
+)
+
+type codeTemplate struct {
+	subject string
+	body    string
+}
+
+var codeTemplates = map[domain.Locale]codeTemplate{
+	domain.LocaleKazakh: {
+		subject: "Электрондық поштаны растау",
+		body:    "Электрондық поштаны растау коды: %s\n\nЕгер сіз поштаны қоспаған болсаңыз, бұл хатты елемеңіз.",
+	},
+	domain.LocaleRussian: {
+		subject: "Подтверждение почты",
+		body:    "Код подтверждения почты: %s\n\nЕсли вы не добавляли эту почту, проигнорируйте письмо.",
+	},
+	domain.LocaleEnglish: {
+		subject: "Email verification",
+		body:    "Your email verification code: %s\n\nIf you didn't add this email, ignore this message.",
+	},
+}
+
+// codeEmail is the subject and the body of an email with a verification code
+func codeEmail(locale domain.Locale, code string) (string, string) {
+	t, ok := codeTemplates[locale]
+	if !ok {
+		t = codeTemplates[domain.DefaultLocale]
+	}
+	return t.subject, fmt.Sprintf(t.body, code)
+}
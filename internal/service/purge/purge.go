@@ -0,0 +1,27 @@
+package purge
+
+import (
+	"context"
+	"time"
+)
+
+// RemoveFunc removes at most limit rows older than before and returns how many were removed
+type RemoveFunc func(c context.Context, before time.Time, limit int) (int64, error)
+
+// Batched calls remove with batchSize until a batch comes out short, so tables
+// aren't locked for long. Removal stops once c is done.
+func Batched(c context.Context, remove RemoveFunc, before time.Time, batchSize int) (int64, error) {
+	var total int64
+	for c.Err() == nil {
+		count, err := remove(c, before, batchSize)
+		total += count
+		if err != nil {
+			return total, err
+		}
+		// an empty batch ends removal even if the batch size is misconfigured
+		if count == 0 || count < int64(batchSize) {
+			break
+		}
+	}
+	return total, c.Err()
+}
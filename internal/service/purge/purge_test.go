@@ -0,0 +1,63 @@
+package purge
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatched(t *testing.T) {
+	c := context.Background()
+	before := time.Now()
+
+	// batches returns the counts one by one and records the calls
+	batches := func(calls *int, counts ...int64) RemoveFunc {
+		return func(c context.Context, at time.Time, limit int) (int64, error) {
+			assert.Equal(t, before, at)
+			assert.Equal(t, 2, limit)
+			count := counts[*calls]
+			*calls++
+			return count, nil
+		}
+	}
+
+	t.Run("until a short batch", func(t *testing.T) {
+		var calls int
+
+		total, err := Batched(c, batches(&calls, 2, 2, 1), before, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(5), total)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("until an empty batch", func(t *testing.T) {
+		var calls int
+
+		total, err := Batched(c, batches(&calls, 2, 0), before, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("failed", func(t *testing.T) {
+		total, err := Batched(c, func(c context.Context, before time.Time, limit int) (int64, error) {
+			return 0, errors.New("db is down")
+		}, before, 2)
+		assert.Error(t, err)
+		assert.Equal(t, int64(0), total)
+	})
+
+	t.Run("stopped", func(t *testing.T) {
+		stopped, stop := context.WithCancel(c)
+
+		total, err := Batched(stopped, func(c context.Context, at time.Time, limit int) (int64, error) {
+			stop()
+			return 2, nil
+		}, before, 2)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, int64(2), total)
+	})
+}
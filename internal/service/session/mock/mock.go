@@ -10,8 +10,10 @@
 package mock
 
 import (
+	domain "account/internal/domain"
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "go.uber.org/mock/gomock"
 )
@@ -41,15 +43,301 @@ func (m *MockRepository) EXPECT() *MockRepositoryMockRecorder {
 }
 
 // Create mocks base method.
-func (m *MockRepository) Create(c context.Context, userId int, accessToken string) error {
+func (m *MockRepository) Create(c context.Context, session *domain.Session, refreshTokenHash string, limit int, evict bool) ([]domain.Session, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Create", c, userId, accessToken)
+	ret := m.ctrl.Call(m, "Create", c, session, refreshTokenHash, limit, evict)
+	ret0, _ := ret[0].([]domain.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Create indicates an expected call of Create.
+func (mr *MockRepositoryMockRecorder) Create(c, session, refreshTokenHash, limit, evict any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockRepository)(nil).Create), c, session, refreshTokenHash, limit, evict)
+}
+
+// CreateImpersonated mocks base method.
+func (m *MockRepository) CreateImpersonated(c context.Context, session *domain.Session) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateImpersonated", c, session)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// Create indicates an expected call of Create.
-func (mr *MockRepositoryMockRecorder) Create(c, userId, accessToken any) *gomock.Call {
+// CreateImpersonated indicates an expected call of CreateImpersonated.
+func (mr *MockRepositoryMockRecorder) CreateImpersonated(c, session any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateImpersonated", reflect.TypeOf((*MockRepository)(nil).CreateImpersonated), c, session)
+}
+
+// CurrentRevocationCursor mocks base method.
+func (m *MockRepository) CurrentRevocationCursor(c context.Context) (domain.RevocationCursor, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CurrentRevocationCursor", c)
+	ret0, _ := ret[0].(domain.RevocationCursor)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CurrentRevocationCursor indicates an expected call of CurrentRevocationCursor.
+func (mr *MockRepositoryMockRecorder) CurrentRevocationCursor(c any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CurrentRevocationCursor", reflect.TypeOf((*MockRepository)(nil).CurrentRevocationCursor), c)
+}
+
+// FindAllByUserID mocks base method.
+func (m *MockRepository) FindAllByUserID(c context.Context, userId int) ([]domain.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindAllByUserID", c, userId)
+	ret0, _ := ret[0].([]domain.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindAllByUserID indicates an expected call of FindAllByUserID.
+func (mr *MockRepositoryMockRecorder) FindAllByUserID(c, userId any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindAllByUserID", reflect.TypeOf((*MockRepository)(nil).FindAllByUserID), c, userId)
+}
+
+// FindOneByAccessToken mocks base method.
+func (m *MockRepository) FindOneByAccessToken(c context.Context, accessTokenHash string) (*domain.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOneByAccessToken", c, accessTokenHash)
+	ret0, _ := ret[0].(*domain.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOneByAccessToken indicates an expected call of FindOneByAccessToken.
+func (mr *MockRepositoryMockRecorder) FindOneByAccessToken(c, accessTokenHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOneByAccessToken", reflect.TypeOf((*MockRepository)(nil).FindOneByAccessToken), c, accessTokenHash)
+}
+
+// FindOneByID mocks base method.
+func (m *MockRepository) FindOneByID(c context.Context, id int) (*domain.Session, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOneByID", c, id)
+	ret0, _ := ret[0].(*domain.Session)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOneByID indicates an expected call of FindOneByID.
+func (mr *MockRepositoryMockRecorder) FindOneByID(c, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOneByID", reflect.TypeOf((*MockRepository)(nil).FindOneByID), c, id)
+}
+
+// FindRefreshToken mocks base method.
+func (m *MockRepository) FindRefreshToken(c context.Context, tokenHash string) (*domain.RefreshToken, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindRefreshToken", c, tokenHash)
+	ret0, _ := ret[0].(*domain.RefreshToken)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindRefreshToken indicates an expected call of FindRefreshToken.
+func (mr *MockRepositoryMockRecorder) FindRefreshToken(c, tokenHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindRefreshToken", reflect.TypeOf((*MockRepository)(nil).FindRefreshToken), c, tokenHash)
+}
+
+// FindRevocationsAfter mocks base method.
+func (m *MockRepository) FindRevocationsAfter(c context.Context, cursor domain.RevocationCursor, limit int) ([]domain.Revocation, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindRevocationsAfter", c, cursor, limit)
+	ret0, _ := ret[0].([]domain.Revocation)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindRevocationsAfter indicates an expected call of FindRevocationsAfter.
+func (mr *MockRepositoryMockRecorder) FindRevocationsAfter(c, cursor, limit any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindRevocationsAfter", reflect.TypeOf((*MockRepository)(nil).FindRevocationsAfter), c, cursor, limit)
+}
+
+// NextID mocks base method.
+func (m *MockRepository) NextID(c context.Context) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NextID", c)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// NextID indicates an expected call of NextID.
+func (mr *MockRepositoryMockRecorder) NextID(c any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NextID", reflect.TypeOf((*MockRepository)(nil).NextID), c)
+}
+
+// Remove mocks base method.
+func (m *MockRepository) Remove(c context.Context, accessTokenHash string, reason domain.RevocationReason) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Remove", c, accessTokenHash, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Remove indicates an expected call of Remove.
+func (mr *MockRepositoryMockRecorder) Remove(c, accessTokenHash, reason any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Remove", reflect.TypeOf((*MockRepository)(nil).Remove), c, accessTokenHash, reason)
+}
+
+// RemoveAllByUserID mocks base method.
+func (m *MockRepository) RemoveAllByUserID(c context.Context, userId int, exceptAccessTokenHash string, reason domain.RevocationReason) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveAllByUserID", c, userId, exceptAccessTokenHash, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveAllByUserID indicates an expected call of RemoveAllByUserID.
+func (mr *MockRepositoryMockRecorder) RemoveAllByUserID(c, userId, exceptAccessTokenHash, reason any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveAllByUserID", reflect.TypeOf((*MockRepository)(nil).RemoveAllByUserID), c, userId, exceptAccessTokenHash, reason)
+}
+
+// RemoveByID mocks base method.
+func (m *MockRepository) RemoveByID(c context.Context, id int, reason domain.RevocationReason) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveByID", c, id, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveByID indicates an expected call of RemoveByID.
+func (mr *MockRepositoryMockRecorder) RemoveByID(c, id, reason any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveByID", reflect.TypeOf((*MockRepository)(nil).RemoveByID), c, id, reason)
+}
+
+// RemoveExpired mocks base method.
+func (m *MockRepository) RemoveExpired(c context.Context, createdBefore, lastSeenBefore time.Time) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveExpired", c, createdBefore, lastSeenBefore)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveExpired indicates an expected call of RemoveExpired.
+func (mr *MockRepositoryMockRecorder) RemoveExpired(c, createdBefore, lastSeenBefore any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveExpired", reflect.TypeOf((*MockRepository)(nil).RemoveExpired), c, createdBefore, lastSeenBefore)
+}
+
+// RemoveOneByUserID mocks base method.
+func (m *MockRepository) RemoveOneByUserID(c context.Context, userId, id int, reason domain.RevocationReason) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveOneByUserID", c, userId, id, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveOneByUserID indicates an expected call of RemoveOneByUserID.
+func (mr *MockRepositoryMockRecorder) RemoveOneByUserID(c, userId, id, reason any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveOneByUserID", reflect.TypeOf((*MockRepository)(nil).RemoveOneByUserID), c, userId, id, reason)
+}
+
+// RemoveRevocations mocks base method.
+func (m *MockRepository) RemoveRevocations(c context.Context, createdBefore time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveRevocations", c, createdBefore)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveRevocations indicates an expected call of RemoveRevocations.
+func (mr *MockRepositoryMockRecorder) RemoveRevocations(c, createdBefore any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveRevocations", reflect.TypeOf((*MockRepository)(nil).RemoveRevocations), c, createdBefore)
+}
+
+// Rotate mocks base method.
+func (m *MockRepository) Rotate(c context.Context, session *domain.Session, usedRefreshTokenHash, refreshTokenHash string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Rotate", c, session, usedRefreshTokenHash, refreshTokenHash)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Rotate indicates an expected call of Rotate.
+func (mr *MockRepositoryMockRecorder) Rotate(c, session, usedRefreshTokenHash, refreshTokenHash any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Rotate", reflect.TypeOf((*MockRepository)(nil).Rotate), c, session, usedRefreshTokenHash, refreshTokenHash)
+}
+
+// Touch mocks base method.
+func (m *MockRepository) Touch(c context.Context, accessTokenHash string, lastSeenAt time.Time) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Touch", c, accessTokenHash, lastSeenAt)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Touch indicates an expected call of Touch.
+func (mr *MockRepositoryMockRecorder) Touch(c, accessTokenHash, lastSeenAt any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Touch", reflect.TypeOf((*MockRepository)(nil).Touch), c, accessTokenHash, lastSeenAt)
+}
+
+// MockSigner is a mock of Signer interface.
+type MockSigner struct {
+	ctrl     *gomock.Controller
+	recorder *MockSignerMockRecorder
+	isgomock struct{}
+}
+
+// MockSignerMockRecorder is the mock recorder for MockSigner.
+type MockSignerMockRecorder struct {
+	mock *MockSigner
+}
+
+// NewMockSigner creates a new mock instance.
+func NewMockSigner(ctrl *gomock.Controller) *MockSigner {
+	mock := &MockSigner{ctrl: ctrl}
+	mock.recorder = &MockSignerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSigner) EXPECT() *MockSignerMockRecorder {
+	return m.recorder
+}
+
+// PublicKeys mocks base method.
+func (m *MockSigner) PublicKeys() []domain.PublicKey {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PublicKeys")
+	ret0, _ := ret[0].([]domain.PublicKey)
+	return ret0
+}
+
+// PublicKeys indicates an expected call of PublicKeys.
+func (mr *MockSignerMockRecorder) PublicKeys() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PublicKeys", reflect.TypeOf((*MockSigner)(nil).PublicKeys))
+}
+
+// Sign mocks base method.
+func (m *MockSigner) Sign(claims *domain.TokenClaims) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sign", claims)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Sign indicates an expected call of Sign.
+func (mr *MockSignerMockRecorder) Sign(claims any) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockRepository)(nil).Create), c, userId, accessToken)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sign", reflect.TypeOf((*MockSigner)(nil).Sign), claims)
 }
@@ -2,37 +2,450 @@ package session
 
 import (
 	"account/internal/application/dtos"
+	"account/internal/domain"
+	"account/internal/infrastructure/configuration"
 	"context"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
 )
 
 //go:generate mockgen -source ./session.go -destination ./mock/mock.go -package mock
 type Repository interface {
-	Create(c context.Context, userId int, accessToken string) error
+	NextID(c context.Context) (int, error)
+	// Create revokes sessions of the user beyond limit, or refuses the new one if evict isn't set,
+	// in the same transaction. It returns revoked sessions.
+	Create(c context.Context, session *domain.Session, refreshTokenHash string, limit int, evict bool) ([]domain.Session, error)
+	CreateImpersonated(c context.Context, session *domain.Session) error
+	FindOneByAccessToken(c context.Context, accessTokenHash string) (*domain.Session, error)
+	FindOneByID(c context.Context, id int) (*domain.Session, error)
+	FindAllByUserID(c context.Context, userId int) ([]domain.Session, error)
+	FindRefreshToken(c context.Context, tokenHash string) (*domain.RefreshToken, error)
+	Rotate(c context.Context, session *domain.Session, usedRefreshTokenHash, refreshTokenHash string) error
+	Touch(c context.Context, accessTokenHash string, lastSeenAt time.Time) error
+
+	Remove(c context.Context, accessTokenHash string, reason domain.RevocationReason) error
+	RemoveByID(c context.Context, id int, reason domain.RevocationReason) error
+	RemoveOneByUserID(c context.Context, userId, id int, reason domain.RevocationReason) error
+	RemoveAllByUserID(c context.Context, userId int, exceptAccessTokenHash string, reason domain.RevocationReason) error
+	RemoveExpired(c context.Context, createdBefore, lastSeenBefore time.Time) (int64, error)
+
+	CurrentRevocationCursor(c context.Context) (domain.RevocationCursor, error)
+	FindRevocationsAfter(c context.Context, cursor domain.RevocationCursor, limit int) ([]domain.Revocation, error)
+	RemoveRevocations(c context.Context, createdBefore time.Time) error
+}
+
+// Signer issues self-contained access tokens that can be verified without calling us
+type Signer interface {
+	Sign(claims *domain.TokenClaims) (string, error)
+	PublicKeys() []domain.PublicKey
 }
 
 type service struct {
+	secret []byte
+	signer Signer
+
+	accessTTL        time.Duration
+	absoluteTTL      time.Duration
+	idleTTL          time.Duration
+	touchInterval    time.Duration
+	impersonationTTL time.Duration
+
+	revocationPollInterval time.Duration
+	revocationRetention    time.Duration
+	watchToken             []byte
+
+	maxPerUser  int
+	maxPerRole  map[int]int
+	limitPolicy string
+
 	repo Repository
 }
 
-func New(repo Repository) *service {
-	return &service{repo: repo}
+// New creates the session service, access tokens are signed by signer
+// or are random strings if signer is nil
+func New(cfg *configuration.SessionConfig, repo Repository, signer Signer) *service {
+	return &service{
+		secret:           []byte(cfg.TokenSecret),
+		signer:           signer,
+		accessTTL:        cfg.AccessTTL,
+		absoluteTTL:      cfg.AbsoluteTTL,
+		idleTTL:          cfg.IdleTTL,
+		touchInterval:    cfg.TouchInterval,
+		impersonationTTL: cfg.ImpersonationTTL,
+
+		revocationPollInterval: cfg.RevocationPollInterval,
+		revocationRetention:    cfg.RevocationRetention,
+		watchToken:             []byte(cfg.WatchToken),
+
+		maxPerUser:  cfg.MaxPerUser,
+		maxPerRole:  cfg.MaxPerRole,
+		limitPolicy: cfg.LimitPolicy,
+		repo:        repo,
+	}
 }
 
-func (s *service) Create(c context.Context, userId int) (*dtos.AuthOutput, error) {
-	var output dtos.AuthOutput
+// Create starts a new session of the user, if the user is at the session limit
+// the least recently used sessions are evicted or the login is refused, depending on policy
+func (s *service) Create(c context.Context, user *domain.User, device domain.Device) (*dtos.AuthOutput, error) {
+	sessionId, err := s.repo.NextID(c)
+	if err != nil {
+		return nil, err
+	}
 
-	accessToken, err := s.generateRandomToken()
+	now := time.Now().UTC()
+
+	session := &domain.Session{
+		ID:              sessionId,
+		UserID:          user.ID,
+		Username:        user.Username,
+		Role:            *user.Role,
+		AccessExpiresAt: now.Add(s.accessTTL),
+		Device:          device,
+	}
+
+	accessToken, err := s.newAccessToken(session, now)
 	if err != nil {
 		return nil, err
 	}
+	session.AccessTokenHash = s.hash(accessToken)
+
+	refreshToken, err := s.generateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	evicted, err := s.repo.Create(c, session, s.hash(refreshToken), s.limit(user), s.limitPolicy == configuration.LimitEvictOldest)
+	if err != nil {
+		return nil, err
+	}
+
+	output := s.output(accessToken, refreshToken)
+	for _, session := range evicted {
+		output.EvictedSessions = append(output.EvictedSessions, dtos.SessionOutput{
+			ID:         session.ID,
+			IP:         session.Device.IP,
+			UserAgent:  session.Device.UserAgent,
+			Platform:   session.Device.Platform,
+			DeviceName: session.Device.Name,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+		})
+	}
+
+	return output, nil
+}
+
+// Impersonate starts a short-lived session of the target user on behalf of the staff member.
+// The session has no refresh token, so it ends when its access token expires.
+func (s *service) Impersonate(c context.Context, staff, target *domain.User, reason string, device domain.Device) (*dtos.AuthOutput, error) {
+	sessionId, err := s.repo.NextID(c)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	session := &domain.Session{
+		ID:                  sessionId,
+		UserID:              target.ID,
+		Username:            target.Username,
+		Role:                *target.Role,
+		AccessExpiresAt:     now.Add(s.impersonationTTL),
+		Device:              device,
+		ImpersonatorID:      staff.ID,
+		ImpersonationReason: reason,
+	}
 
-	output.AccessToken = accessToken
+	accessToken, err := s.newAccessToken(session, now)
+	if err != nil {
+		return nil, err
+	}
+	session.AccessTokenHash = s.hash(accessToken)
+
+	if err := s.repo.CreateImpersonated(c, session); err != nil {
+		return nil, err
+	}
+
+	return &dtos.AuthOutput{
+		AccessToken: accessToken,
+		ExpiresIn:   int(s.impersonationTTL.Seconds()),
+	}, nil
+}
+
+func (s *service) limit(user *domain.User) int {
+	// limit is the maximum number of sessions of the user, 0 means unlimited
+	if user.Role != nil {
+		if limit, ok := s.maxPerRole[int(user.Role.ID)]; ok {
+			return limit
+		}
+	}
+	return s.maxPerUser
+}
+
+// Refresh exchanges the refresh token for a new token pair.
+// A refresh token can be used only once, presenting it again means
+// it was stolen, so the whole session is revoked.
+func (s *service) Refresh(c context.Context, refreshToken string) (*dtos.AuthOutput, error) {
+	token, err := s.repo.FindRefreshToken(c, s.hash(refreshToken))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if token.IsUsed() {
+		return nil, s.revokeFamily(c, token.SessionID)
+	}
+
+	session, err := s.repo.FindOneByID(c, token.SessionID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	if session.IsExpired(now, s.absoluteTTL, s.idleTTL) {
+		if err := s.repo.RemoveByID(c, session.ID, domain.RevokedByExpiry); err != nil {
+			return nil, err
+		}
+		return nil, domain.ErrSessionExpired
+	}
+
+	session.AccessExpiresAt = now.Add(s.accessTTL)
+	session.LastSeenAt = now
+
+	accessToken, err := s.newAccessToken(session, now)
+	if err != nil {
+		return nil, err
+	}
+	session.AccessTokenHash = s.hash(accessToken)
+
+	newRefreshToken, err := s.generateRandomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Rotate(c, session, s.hash(refreshToken), s.hash(newRefreshToken)); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			// the token was used by a concurrent request
+			return nil, s.revokeFamily(c, session.ID)
+		}
+		return nil, err
+	}
+
+	return s.output(accessToken, newRefreshToken), nil
+}
+
+func (s *service) revokeFamily(c context.Context, sessionId int) error {
+	if err := s.repo.RemoveByID(c, sessionId, domain.RevokedByTokenReuse); err != nil {
+		return err
+	}
+	return domain.ErrRefreshTokenReused
+}
+
+func (s *service) newAccessToken(session *domain.Session, now time.Time) (string, error) {
+	if s.signer == nil {
+		return s.generateRandomToken()
+	}
+
+	return s.signer.Sign(&domain.TokenClaims{
+		SessionID: session.ID,
+		UserID:    session.UserID,
+		RoleID:    session.Role.ID,
+		IssuedAt:  now,
+		ExpiresAt: session.AccessExpiresAt,
+
+		ImpersonatorID: session.ImpersonatorID,
+	})
+}
+
+// PublicKeys returns keys that verify signed access tokens
+func (s *service) PublicKeys() []domain.PublicKey {
+	if s.signer == nil {
+		return []domain.PublicKey{}
+	}
+	return s.signer.PublicKeys()
+}
+
+func (s *service) output(accessToken, refreshToken string) *dtos.AuthOutput {
+	return &dtos.AuthOutput{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(s.accessTTL.Seconds()),
+	}
+}
+
+// Validate returns an active session by its access token
+// and refreshes its idle window not more often than once per touchInterval
+func (s *service) Validate(c context.Context, accessToken string) (*domain.Session, error) {
+	session, err := s.repo.FindOneByAccessToken(c, s.hash(accessToken))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrInvalidAccessToken
+		}
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	if session.IsExpired(now, s.absoluteTTL, s.idleTTL) {
+		if err := s.repo.Remove(c, session.AccessTokenHash, domain.RevokedByExpiry); err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		return nil, domain.ErrSessionExpired
+	}
+
+	if session.IsAccessExpired(now) {
+		return nil, domain.ErrAccessTokenExpired
+	}
+
+	if now.Sub(session.LastSeenAt) > s.touchInterval {
+		if err := s.repo.Touch(c, session.AccessTokenHash, now); err != nil {
+			return nil, err
+		}
+		session.LastSeenAt = now
+	}
+
+	return session, nil
+}
+
+// List returns active sessions of the user, most recently used first
+func (s *service) List(c context.Context, userId int) ([]domain.Session, error) {
+	sessions, err := s.repo.FindAllByUserID(c, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+
+	output := []domain.Session{}
+	for _, session := range sessions {
+		if !session.IsExpired(now, s.absoluteTTL, s.idleTTL) {
+			output = append(output, session)
+		}
+	}
+
+	return output, nil
+}
+
+func (s *service) Remove(c context.Context, accessToken string) error {
+	err := s.repo.Remove(c, s.hash(accessToken), domain.RevokedByLogout)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrInvalidAccessToken
+	}
+	return err
+}
+
+// RemoveOne removes the session only if it belongs to the user
+func (s *service) RemoveOne(c context.Context, userId, id int) error {
+	err := s.repo.RemoveOneByUserID(c, userId, id, domain.RevokedByUser)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return domain.ErrSessionNotFound
+	}
+	return err
+}
+
+// RemoveAll removes every session of the user,
+// except the one with exceptAccessToken if it's not empty
+func (s *service) RemoveAll(c context.Context, userId int, exceptAccessToken string) error {
+	var except string
+	if exceptAccessToken != "" {
+		except = s.hash(exceptAccessToken)
+	}
+	return s.repo.RemoveAllByUserID(c, userId, except, domain.RevokedByLogoutAll)
+}
+
+// PurgeExpired removes expired sessions and returns how many were removed,
+// revocations older than the retention period are removed too
+func (s *service) PurgeExpired(c context.Context) (int64, error) {
+	now := time.Now().UTC()
+
+	count, err := s.repo.RemoveExpired(c, now.Add(-s.absoluteTTL), now.Add(-s.idleTTL))
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.repo.RemoveRevocations(c, now.Add(-s.revocationRetention)); err != nil {
+		return count, err
+	}
+
+	return count, nil
+}
+
+const revocationsBatchSize = 100
+
+// ValidateWatchToken checks the token of a service asking to watch revocations,
+// no token is valid if the watch token isn't configured
+func (s *service) ValidateWatchToken(token string) error {
+	if len(s.watchToken) == 0 || subtle.ConstantTimeCompare([]byte(token), s.watchToken) != 1 {
+		return domain.ErrInvalidServiceToken
+	}
+	return nil
+}
+
+// WatchRevocations calls send for every revocation after the cursor until c is done or send fails.
+// An empty cursor starts from the revocations that happen after the call.
+func (s *service) WatchRevocations(c context.Context, cursor string, send func(revocation *domain.Revocation) error) error {
+	var position domain.RevocationCursor
+	var err error
+	if cursor == "" {
+		position, err = s.repo.CurrentRevocationCursor(c)
+	} else {
+		position, err = domain.ParseRevocationCursor(cursor)
+	}
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(s.revocationPollInterval)
+	defer ticker.Stop()
+
+	for {
+		revocations, err := s.repo.FindRevocationsAfter(c, position, revocationsBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, revocation := range revocations {
+			if err := send(&revocation); err != nil {
+				return err
+			}
+			position = revocation.Cursor
+		}
+
+		if len(revocations) == revocationsBatchSize {
+			continue
+		}
+
+		select {
+		case <-c.Done():
+			return c.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// hash returns a keyed digest of the token, only digests are passed to the repository,
+// so tokens leaked from a database dump can't be used without the server secret
+func (s *service) hash(token string) string {
+	return hashToken(s.secret, token)
+}
 
-	err = s.repo.Create(c, userId, accessToken)
-	return &output, err
+func hashToken(secret []byte, token string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
 func (s *service) generateRandomToken() (string, error) {
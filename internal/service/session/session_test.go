@@ -1,14 +1,35 @@
 package session
 
 import (
+	"account/internal/domain"
+	"account/internal/infrastructure/configuration"
 	"account/internal/service/session/mock"
 	"context"
 	"testing"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/mock/gomock"
 )
 
+var cfg = &configuration.SessionConfig{
+	TokenSecret:   "secret",
+	AccessTTL:     time.Minute * 15,
+	AbsoluteTTL:   time.Hour * 24 * 30,
+	IdleTTL:       time.Hour * 24 * 7,
+	TouchInterval: time.Minute * 5,
+
+	ImpersonationTTL: time.Minute * 30,
+
+	RevocationPollInterval: time.Millisecond * 10,
+	RevocationRetention:    time.Hour * 24,
+}
+
+func hash(token string) string {
+	return hashToken([]byte(cfg.TokenSecret), token)
+}
+
 func setup(t *testing.T) (context.Context, *mock.MockRepository) {
 	c := context.Background()
 
@@ -21,16 +42,468 @@ func setup(t *testing.T) (context.Context, *mock.MockRepository) {
 func TestCreate(t *testing.T) {
 	c, repo := setup(t)
 
+	user := &domain.User{ID: 1, Role: &domain.Role{ID: domain.UserRole}}
+
 	t.Run("success", func(t *testing.T) {
-		repo.EXPECT().Create(c, 1, gomock.Any()).Return(nil)
+		var stored *domain.Session
+		var storedRefreshToken string
+		repo.EXPECT().NextID(c).Return(1, nil)
+		repo.EXPECT().Create(c, gomock.Any(), gomock.Any(), 0, gomock.Any()).DoAndReturn(func(_ context.Context, session *domain.Session, refreshTokenHash string, _ int, _ bool) ([]domain.Session, error) {
+			stored, storedRefreshToken = session, refreshTokenHash
+			return nil, nil
+		})
 
-		service := New(repo)
+		service := New(cfg, repo, nil)
 
-		output, err := service.Create(c, 1)
+		output, err := service.Create(c, user, domain.NewDevice("127.0.0.1", "Mozilla/5.0", "web", "Firefox"))
 
 		assert.NoError(t, err)
 		assert.NotZero(t, output)
+		assert.NotEmpty(t, output.RefreshToken)
+		assert.Equal(t, 900, output.ExpiresIn)
+
+		// only digests of the tokens are stored
+		assert.Equal(t, hash(output.AccessToken), stored.AccessTokenHash)
+		assert.Equal(t, hash(output.RefreshToken), storedRefreshToken)
+		assert.Equal(t, "Firefox", stored.Device.Name)
 
 		t.Log(output.AccessToken)
 	})
+
+	t.Run("signed token", func(t *testing.T) {
+		signer := mock.NewMockSigner(gomock.NewController(t))
+
+		repo.EXPECT().NextID(c).Return(7, nil)
+		signer.EXPECT().Sign(gomock.Any()).DoAndReturn(func(claims *domain.TokenClaims) (string, error) {
+			assert.Equal(t, 7, claims.SessionID)
+			assert.Equal(t, 1, claims.UserID)
+			assert.Equal(t, domain.UserRole, claims.RoleID)
+			return "signed token", nil
+		})
+		repo.EXPECT().Create(c, gomock.Any(), gomock.Any(), 0, gomock.Any()).DoAndReturn(func(_ context.Context, session *domain.Session, _ string, _ int, _ bool) ([]domain.Session, error) {
+			assert.Equal(t, hash("signed token"), session.AccessTokenHash)
+			return nil, nil
+		})
+
+		service := New(cfg, repo, signer)
+
+		output, err := service.Create(c, user, domain.Device{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "signed token", output.AccessToken)
+	})
+
+	t.Run("evict oldest", func(t *testing.T) {
+		now := time.Now().UTC()
+		repo.EXPECT().NextID(c).Return(4, nil)
+		repo.EXPECT().Create(c, gomock.Any(), gomock.Any(), 2, true).Return([]domain.Session{
+			{ID: 2, UserID: 1, CreatedAt: now, LastSeenAt: now.Add(-time.Hour)},
+			{ID: 1, UserID: 1, CreatedAt: now, LastSeenAt: now.Add(-time.Hour * 2)},
+		}, nil)
+
+		limited := *cfg
+		limited.MaxPerUser = 2
+		limited.LimitPolicy = "evict_oldest"
+		service := New(&limited, repo, nil)
+
+		output, err := service.Create(c, user, domain.Device{})
+
+		assert.NoError(t, err)
+		assert.Len(t, output.EvictedSessions, 2)
+		assert.Equal(t, 2, output.EvictedSessions[0].ID)
+		assert.Equal(t, 1, output.EvictedSessions[1].ID)
+	})
+
+	t.Run("refuse", func(t *testing.T) {
+		repo.EXPECT().NextID(c).Return(2, nil)
+		repo.EXPECT().Create(c, gomock.Any(), gomock.Any(), 1, false).Return(nil, domain.ErrTooManySessions)
+
+		limited := *cfg
+		limited.MaxPerUser = 1
+		limited.LimitPolicy = "refuse"
+		service := New(&limited, repo, nil)
+
+		_, err := service.Create(c, user, domain.Device{})
+
+		assert.ErrorIs(t, err, domain.ErrTooManySessions)
+	})
+
+	t.Run("role override", func(t *testing.T) {
+		repo.EXPECT().NextID(c).Return(2, nil)
+		repo.EXPECT().Create(c, gomock.Any(), gomock.Any(), 5, false).Return(nil, nil)
+
+		limited := *cfg
+		limited.MaxPerUser = 1
+		limited.MaxPerRole = configuration.RoleLimits{int(domain.UserRole): 5}
+		limited.LimitPolicy = "refuse"
+		service := New(&limited, repo, nil)
+
+		output, err := service.Create(c, user, domain.Device{})
+
+		assert.NoError(t, err)
+		assert.Empty(t, output.EvictedSessions)
+	})
+}
+
+func TestImpersonate(t *testing.T) {
+	c, repo := setup(t)
+
+	staff := &domain.User{ID: 2, Role: &domain.Role{ID: domain.ModeratorRole}}
+	target := &domain.User{ID: 1, Role: &domain.Role{ID: domain.UserRole}}
+
+	t.Run("success", func(t *testing.T) {
+		var stored *domain.Session
+		repo.EXPECT().NextID(c).Return(5, nil)
+		repo.EXPECT().CreateImpersonated(c, gomock.Any()).DoAndReturn(func(_ context.Context, session *domain.Session) error {
+			stored = session
+			return nil
+		})
+
+		service := New(cfg, repo, nil)
+
+		output, err := service.Impersonate(c, staff, target, "report #42", domain.Device{})
+
+		assert.NoError(t, err)
+		assert.Empty(t, output.RefreshToken)
+		assert.Equal(t, 1800, output.ExpiresIn)
+
+		assert.Equal(t, 1, stored.UserID)
+		assert.Equal(t, 2, stored.ImpersonatorID)
+		assert.Equal(t, "report #42", stored.ImpersonationReason)
+		assert.Equal(t, hash(output.AccessToken), stored.AccessTokenHash)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	c, repo := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		now := time.Now().UTC()
+		repo.EXPECT().FindOneByAccessToken(c, hash("token")).Return(&domain.Session{AccessTokenHash: hash("token"), UserID: 1, AccessExpiresAt: now.Add(time.Minute), CreatedAt: now, LastSeenAt: now}, nil)
+
+		service := New(cfg, repo, nil)
+
+		session, err := service.Validate(c, "token")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, session.UserID)
+	})
+
+	t.Run("touch", func(t *testing.T) {
+		now := time.Now().UTC()
+		repo.EXPECT().FindOneByAccessToken(c, hash("token")).Return(&domain.Session{AccessTokenHash: hash("token"), UserID: 1, AccessExpiresAt: now.Add(time.Minute), CreatedAt: now.Add(-time.Hour), LastSeenAt: now.Add(-time.Hour)}, nil)
+		repo.EXPECT().Touch(c, hash("token"), gomock.Any()).Return(nil)
+
+		service := New(cfg, repo, nil)
+
+		session, err := service.Validate(c, "token")
+
+		assert.NoError(t, err)
+		assert.WithinDuration(t, now, session.LastSeenAt, time.Minute)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		now := time.Now().UTC()
+		repo.EXPECT().FindOneByAccessToken(c, hash("token")).Return(&domain.Session{AccessTokenHash: hash("token"), UserID: 1, CreatedAt: now.Add(-time.Hour * 24 * 10), LastSeenAt: now.Add(-time.Hour * 24 * 8)}, nil)
+		repo.EXPECT().Remove(c, hash("token"), domain.RevokedByExpiry).Return(nil)
+
+		service := New(cfg, repo, nil)
+
+		_, err := service.Validate(c, "token")
+
+		assert.ErrorIs(t, err, domain.ErrSessionExpired)
+	})
+
+	t.Run("access token expired", func(t *testing.T) {
+		now := time.Now().UTC()
+		repo.EXPECT().FindOneByAccessToken(c, hash("token")).Return(&domain.Session{AccessTokenHash: hash("token"), UserID: 1, AccessExpiresAt: now.Add(-time.Minute), CreatedAt: now.Add(-time.Hour), LastSeenAt: now.Add(-time.Minute * 20)}, nil)
+
+		service := New(cfg, repo, nil)
+
+		_, err := service.Validate(c, "token")
+
+		assert.ErrorIs(t, err, domain.ErrAccessTokenExpired)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		repo.EXPECT().FindOneByAccessToken(c, hash("wrong token")).Return(&domain.Session{}, pgx.ErrNoRows)
+
+		service := New(cfg, repo, nil)
+
+		_, err := service.Validate(c, "wrong token")
+
+		assert.ErrorIs(t, err, domain.ErrInvalidAccessToken)
+	})
+}
+
+func TestRefresh(t *testing.T) {
+	c, repo := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		now := time.Now().UTC()
+		repo.EXPECT().FindRefreshToken(c, hash("refresh")).Return(&domain.RefreshToken{TokenHash: hash("refresh"), SessionID: 1}, nil)
+		repo.EXPECT().FindOneByID(c, 1).Return(&domain.Session{ID: 1, UserID: 1, AccessTokenHash: hash("old"), CreatedAt: now.Add(-time.Hour), LastSeenAt: now.Add(-time.Hour)}, nil)
+		repo.EXPECT().Rotate(c, gomock.Any(), hash("refresh"), gomock.Any()).Return(nil)
+
+		service := New(cfg, repo, nil)
+
+		output, err := service.Refresh(c, "refresh")
+
+		assert.NoError(t, err)
+		assert.NotEqual(t, "old", output.AccessToken)
+		assert.NotEqual(t, "refresh", output.RefreshToken)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		repo.EXPECT().FindRefreshToken(c, hash("wrong")).Return(&domain.RefreshToken{}, pgx.ErrNoRows)
+
+		service := New(cfg, repo, nil)
+
+		_, err := service.Refresh(c, "wrong")
+
+		assert.ErrorIs(t, err, domain.ErrInvalidRefreshToken)
+	})
+
+	t.Run("reused token", func(t *testing.T) {
+		usedAt := time.Now().UTC().Add(-time.Minute)
+		repo.EXPECT().FindRefreshToken(c, hash("refresh")).Return(&domain.RefreshToken{TokenHash: hash("refresh"), SessionID: 1, UsedAt: &usedAt}, nil)
+		repo.EXPECT().RemoveByID(c, 1, domain.RevokedByTokenReuse).Return(nil)
+
+		service := New(cfg, repo, nil)
+
+		_, err := service.Refresh(c, "refresh")
+
+		assert.ErrorIs(t, err, domain.ErrRefreshTokenReused)
+	})
+
+	t.Run("concurrent reuse", func(t *testing.T) {
+		now := time.Now().UTC()
+		repo.EXPECT().FindRefreshToken(c, hash("refresh")).Return(&domain.RefreshToken{TokenHash: hash("refresh"), SessionID: 1}, nil)
+		repo.EXPECT().FindOneByID(c, 1).Return(&domain.Session{ID: 1, UserID: 1, CreatedAt: now, LastSeenAt: now}, nil)
+		repo.EXPECT().Rotate(c, gomock.Any(), hash("refresh"), gomock.Any()).Return(pgx.ErrNoRows)
+		repo.EXPECT().RemoveByID(c, 1, domain.RevokedByTokenReuse).Return(nil)
+
+		service := New(cfg, repo, nil)
+
+		_, err := service.Refresh(c, "refresh")
+
+		assert.ErrorIs(t, err, domain.ErrRefreshTokenReused)
+	})
+
+	t.Run("session expired", func(t *testing.T) {
+		now := time.Now().UTC()
+		repo.EXPECT().FindRefreshToken(c, hash("refresh")).Return(&domain.RefreshToken{TokenHash: hash("refresh"), SessionID: 1}, nil)
+		repo.EXPECT().FindOneByID(c, 1).Return(&domain.Session{ID: 1, UserID: 1, CreatedAt: now.Add(-time.Hour * 24 * 31), LastSeenAt: now}, nil)
+		repo.EXPECT().RemoveByID(c, 1, domain.RevokedByExpiry).Return(nil)
+
+		service := New(cfg, repo, nil)
+
+		_, err := service.Refresh(c, "refresh")
+
+		assert.ErrorIs(t, err, domain.ErrSessionExpired)
+	})
+}
+
+func TestList(t *testing.T) {
+	c, repo := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		now := time.Now().UTC()
+		repo.EXPECT().FindAllByUserID(c, 1).Return([]domain.Session{
+			{ID: 1, UserID: 1, CreatedAt: now, LastSeenAt: now},
+			{ID: 2, UserID: 1, CreatedAt: now.Add(-time.Hour * 24 * 10), LastSeenAt: now.Add(-time.Hour * 24 * 8)},
+		}, nil)
+
+		service := New(cfg, repo, nil)
+
+		sessions, err := service.List(c, 1)
+
+		assert.NoError(t, err)
+		assert.Len(t, sessions, 1)
+		assert.Equal(t, 1, sessions[0].ID)
+	})
+}
+
+func TestRemoveOne(t *testing.T) {
+	c, repo := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo.EXPECT().RemoveOneByUserID(c, 1, 5, domain.RevokedByUser).Return(nil)
+
+		service := New(cfg, repo, nil)
+
+		err := service.RemoveOne(c, 1, 5)
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		repo.EXPECT().RemoveOneByUserID(c, 1, 6, domain.RevokedByUser).Return(pgx.ErrNoRows)
+
+		service := New(cfg, repo, nil)
+
+		err := service.RemoveOne(c, 1, 6)
+
+		assert.ErrorIs(t, err, domain.ErrSessionNotFound)
+	})
+}
+
+func TestRemove(t *testing.T) {
+	c, repo := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo.EXPECT().Remove(c, hash("token"), domain.RevokedByLogout).Return(nil)
+
+		service := New(cfg, repo, nil)
+
+		err := service.Remove(c, "token")
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		repo.EXPECT().Remove(c, hash("wrong token"), domain.RevokedByLogout).Return(pgx.ErrNoRows)
+
+		service := New(cfg, repo, nil)
+
+		err := service.Remove(c, "wrong token")
+
+		assert.ErrorIs(t, err, domain.ErrInvalidAccessToken)
+	})
+}
+
+func TestRemoveAll(t *testing.T) {
+	c, repo := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo.EXPECT().RemoveAllByUserID(c, 1, hash("token"), domain.RevokedByLogoutAll).Return(nil)
+
+		service := New(cfg, repo, nil)
+
+		err := service.RemoveAll(c, 1, "token")
+
+		assert.NoError(t, err)
+	})
+}
+
+func TestPurgeExpired(t *testing.T) {
+	c, repo := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo.EXPECT().RemoveExpired(c, gomock.Any(), gomock.Any()).Return(int64(3), nil)
+		repo.EXPECT().RemoveRevocations(c, gomock.Any()).Return(nil)
+
+		service := New(cfg, repo, nil)
+
+		count, err := service.PurgeExpired(c)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), count)
+	})
+}
+
+func TestPublicKeys(t *testing.T) {
+	_, repo := setup(t)
+
+	t.Run("opaque tokens", func(t *testing.T) {
+		service := New(cfg, repo, nil)
+
+		assert.Empty(t, service.PublicKeys())
+	})
+
+	t.Run("signed tokens", func(t *testing.T) {
+		signer := mock.NewMockSigner(gomock.NewController(t))
+		signer.EXPECT().PublicKeys().Return([]domain.PublicKey{{ID: "first"}})
+
+		service := New(cfg, repo, signer)
+
+		assert.Len(t, service.PublicKeys(), 1)
+	})
+}
+
+func TestValidateWatchToken(t *testing.T) {
+	t.Run("success", func(t *testing.T) {
+		watched := *cfg
+		watched.WatchToken = "watch"
+
+		service := New(&watched, nil, nil)
+
+		assert.NoError(t, service.ValidateWatchToken("watch"))
+	})
+
+	t.Run("wrong token", func(t *testing.T) {
+		watched := *cfg
+		watched.WatchToken = "watch"
+
+		service := New(&watched, nil, nil)
+
+		assert.ErrorIs(t, service.ValidateWatchToken("wrong"), domain.ErrInvalidServiceToken)
+	})
+
+	t.Run("not configured", func(t *testing.T) {
+		service := New(cfg, nil, nil)
+
+		assert.ErrorIs(t, service.ValidateWatchToken(""), domain.ErrInvalidServiceToken)
+	})
+}
+
+func TestWatchRevocations(t *testing.T) {
+	c, repo := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		watchCtx, stop := context.WithCancel(c)
+		defer stop()
+
+		start := domain.RevocationCursor{TxID: 100}
+		first := domain.Revocation{Cursor: domain.RevocationCursor{TxID: 100, ID: 1}, SessionID: 1, Reason: domain.RevokedByLogout}
+		second := domain.Revocation{Cursor: domain.RevocationCursor{TxID: 101, ID: 2}, SessionID: 2, Reason: domain.RevokedByExpiry}
+
+		repo.EXPECT().CurrentRevocationCursor(watchCtx).Return(start, nil)
+		gomock.InOrder(
+			repo.EXPECT().FindRevocationsAfter(watchCtx, start, gomock.Any()).Return([]domain.Revocation{first}, nil),
+			repo.EXPECT().FindRevocationsAfter(watchCtx, first.Cursor, gomock.Any()).Return([]domain.Revocation{}, nil),
+			repo.EXPECT().FindRevocationsAfter(watchCtx, first.Cursor, gomock.Any()).Return([]domain.Revocation{second}, nil),
+			repo.EXPECT().FindRevocationsAfter(watchCtx, second.Cursor, gomock.Any()).Return([]domain.Revocation{}, nil).AnyTimes(),
+		)
+
+		service := New(cfg, repo, nil)
+
+		var sent []int
+		err := service.WatchRevocations(watchCtx, "", func(revocation *domain.Revocation) error {
+			sent = append(sent, revocation.SessionID)
+			if len(sent) == 2 {
+				stop()
+			}
+			return nil
+		})
+
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, []int{1, 2}, sent)
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		watchCtx, stop := context.WithCancel(c)
+		stop()
+
+		cursor := domain.RevocationCursor{TxID: 100, ID: 1}
+		repo.EXPECT().FindRevocationsAfter(watchCtx, cursor, gomock.Any()).Return([]domain.Revocation{}, nil)
+
+		service := New(cfg, repo, nil)
+
+		err := service.WatchRevocations(watchCtx, cursor.String(), func(revocation *domain.Revocation) error {
+			return nil
+		})
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		service := New(cfg, repo, nil)
+
+		err := service.WatchRevocations(c, "wrong", func(revocation *domain.Revocation) error {
+			return nil
+		})
+
+		assert.ErrorIs(t, err, domain.ErrInvalidCursor)
+	})
 }
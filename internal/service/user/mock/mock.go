@@ -56,6 +56,36 @@ func (mr *MockRepositoryMockRecorder) Create(c, user any) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Create", reflect.TypeOf((*MockRepository)(nil).Create), c, user)
 }
 
+// FindOneByEmail mocks base method.
+func (m *MockRepository) FindOneByEmail(c context.Context, email string) (*domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOneByEmail", c, email)
+	ret0, _ := ret[0].(*domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOneByEmail indicates an expected call of FindOneByEmail.
+func (mr *MockRepositoryMockRecorder) FindOneByEmail(c, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOneByEmail", reflect.TypeOf((*MockRepository)(nil).FindOneByEmail), c, email)
+}
+
+// FindOneByID mocks base method.
+func (m *MockRepository) FindOneByID(c context.Context, id int) (*domain.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FindOneByID", c, id)
+	ret0, _ := ret[0].(*domain.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FindOneByID indicates an expected call of FindOneByID.
+func (mr *MockRepositoryMockRecorder) FindOneByID(c, id any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOneByID", reflect.TypeOf((*MockRepository)(nil).FindOneByID), c, id)
+}
+
 // FindOneByPhone mocks base method.
 func (m *MockRepository) FindOneByPhone(c context.Context, phone string) (*domain.User, error) {
 	m.ctrl.T.Helper()
@@ -85,3 +115,17 @@ func (mr *MockRepositoryMockRecorder) FindOneByUsername(c, username any) *gomock
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FindOneByUsername", reflect.TypeOf((*MockRepository)(nil).FindOneByUsername), c, username)
 }
+
+// SetEmail mocks base method.
+func (m *MockRepository) SetEmail(c context.Context, id int, email string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetEmail", c, id, email)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetEmail indicates an expected call of SetEmail.
+func (mr *MockRepositoryMockRecorder) SetEmail(c, id, email any) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetEmail", reflect.TypeOf((*MockRepository)(nil).SetEmail), c, id, email)
+}
@@ -11,9 +11,12 @@ import (
 //go:generate mockgen -source ./user.go -destination ./mock/mock.go -package mock
 type Repository interface {
 	FindOneByPhone(c context.Context, phone string) (*domain.User, error)
+	FindOneByID(c context.Context, id int) (*domain.User, error)
 	FindOneByUsername(c context.Context, username string) (*domain.User, error)
+	FindOneByEmail(c context.Context, email string) (*domain.User, error)
 
 	Create(c context.Context, user *domain.User) (int, error)
+	SetEmail(c context.Context, id int, email string) error
 }
 
 type service struct {
@@ -37,7 +40,7 @@ func (s *service) IsPhoneExists(c context.Context, phone string) (bool, error) {
 	return true, nil
 }
 
-func (s *service) Create(c context.Context, username, phone, password string) (int, error) {
+func (s *service) Create(c context.Context, username, phone, password string, locale domain.Locale) (int, error) {
 	_, err := s.repo.FindOneByUsername(c, username)
 	if !errors.Is(err, pgx.ErrNoRows) {
 		return 0, domain.ErrUsernameAlreadyInUse
@@ -47,6 +50,7 @@ func (s *service) Create(c context.Context, username, phone, password string) (i
 	if err != nil {
 		return 0, err
 	}
+	user.Locale = locale
 
 	return s.repo.Create(c, user)
 }
@@ -62,3 +66,41 @@ func (s *service) FindOneByPhone(c context.Context, phone string) (*domain.User,
 
 	return user, nil
 }
+
+func (s *service) FindOneByID(c context.Context, id int) (*domain.User, error) {
+	user, err := s.repo.FindOneByID(c, id)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *service) FindOneByEmail(c context.Context, email string) (*domain.User, error) {
+	user, err := s.repo.FindOneByEmail(c, email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, domain.ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// SetEmail sets a verified email of the user, an email can belong to one user only.
+// The repository reports an email taken concurrently after the check as well.
+func (s *service) SetEmail(c context.Context, id int, email string) error {
+	owner, err := s.repo.FindOneByEmail(c, email)
+	if err == nil && owner.ID != id {
+		return domain.ErrEmailAlreadyInUse
+	}
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
+
+	return s.repo.SetEmail(c, id, email)
+}
@@ -51,7 +51,7 @@ func TestCreate(t *testing.T) {
 
 		service := New(repo)
 
-		userId, err := service.Create(c, "john", "7773336699", "12345678")
+		userId, err := service.Create(c, "john", "7773336699", "12345678", domain.LocaleRussian)
 
 		assert.NoError(t, err)
 		assert.NotZero(t, userId)
@@ -82,3 +82,64 @@ func TestFindOneByPhone(t *testing.T) {
 		assert.ErrorIs(t, err, domain.ErrUserNotFound)
 	})
 }
+
+func TestFindOneByID(t *testing.T) {
+	c, repo := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo.EXPECT().FindOneByID(c, 1).Return(&domain.User{ID: 1}, nil)
+
+		service := New(repo)
+
+		user, err := service.FindOneByID(c, 1)
+
+		assert.NoError(t, err)
+		assert.NotZero(t, user)
+	})
+
+	t.Run("fail", func(t *testing.T) {
+		repo.EXPECT().FindOneByID(c, 2).Return(nil, pgx.ErrNoRows)
+
+		service := New(repo)
+
+		_, err := service.FindOneByID(c, 2)
+
+		assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	})
+}
+
+func TestSetEmail(t *testing.T) {
+	c, repo := setup(t)
+
+	t.Run("success", func(t *testing.T) {
+		repo.EXPECT().FindOneByEmail(c, "john@example.com").Return(nil, pgx.ErrNoRows)
+		repo.EXPECT().SetEmail(c, 1, "john@example.com").Return(nil)
+
+		service := New(repo)
+
+		err := service.SetEmail(c, 1, "john@example.com")
+
+		assert.NoError(t, err)
+	})
+
+	t.Run("used by another user", func(t *testing.T) {
+		repo.EXPECT().FindOneByEmail(c, "john@example.com").Return(&domain.User{ID: 2}, nil)
+
+		service := New(repo)
+
+		err := service.SetEmail(c, 1, "john@example.com")
+
+		assert.ErrorIs(t, err, domain.ErrEmailAlreadyInUse)
+	})
+
+	t.Run("taken concurrently", func(t *testing.T) {
+		repo.EXPECT().FindOneByEmail(c, "john@example.com").Return(nil, pgx.ErrNoRows)
+		repo.EXPECT().SetEmail(c, 1, "john@example.com").Return(domain.ErrEmailAlreadyInUse)
+
+		service := New(repo)
+
+		err := service.SetEmail(c, 1, "john@example.com")
+
+		assert.ErrorIs(t, err, domain.ErrEmailAlreadyInUse)
+	})
+}
@@ -0,0 +1,91 @@
+package grpc
+
+import (
+	"account/internal/domain"
+	"context"
+	"net/netip"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// metadata keys set by proxies in front of the server
+const (
+	forwardedForKey = "x-forwarded-for"
+	realIPKey       = "x-real-ip"
+)
+
+// clientIPInterceptor puts the address of the client into the context of every request, see domain.ClientIP
+func clientIPInterceptor(trustedProxies []netip.Prefix) grpc.UnaryServerInterceptor {
+	return func(c context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(withClientIP(c, trustedProxies), req)
+	}
+}
+
+func streamClientIPInterceptor(trustedProxies []netip.Prefix) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{ServerStream: stream, ctx: withClientIP(stream.Context(), trustedProxies)})
+	}
+}
+
+// serverStream overrides the context of a stream
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func withClientIP(c context.Context, trustedProxies []netip.Prefix) context.Context {
+	md, _ := metadata.FromIncomingContext(c)
+	return domain.WithClientIP(c, clientIP(peerIP(c), md, trustedProxies))
+}
+
+// clientIP resolves the address of the client. Forwarding metadata is read only from
+// trusted proxies, and x-forwarded-for is walked from the right up to the first untrusted hop,
+// so a client can't pick its address by sending the metadata itself.
+func clientIP(remote string, md metadata.MD, trustedProxies []netip.Prefix) string {
+	addr, err := netip.ParseAddr(remote)
+	if err != nil || !trusted(addr, trustedProxies) {
+		return remote
+	}
+
+	var hops []string
+	for _, value := range md.Get(forwardedForKey) {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+
+	if len(hops) == 0 {
+		if realIP, err := netip.ParseAddr(strings.TrimSpace(first(md, realIPKey))); err == nil {
+			return realIP.Unmap().String()
+		}
+		return remote
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// nothing left of a malformed hop can be trusted
+			break
+		}
+		addr = hop.Unmap()
+		if !trusted(addr, trustedProxies) {
+			break
+		}
+	}
+
+	return addr.String()
+}
+
+func trusted(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
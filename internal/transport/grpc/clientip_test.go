@@ -0,0 +1,80 @@
+package grpc
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("fd00::/8")}
+
+	testCases := []struct {
+		name   string
+		remote string
+		md     metadata.MD
+		want   string
+	}{
+		{
+			name:   "direct client",
+			remote: "203.0.113.7",
+			want:   "203.0.113.7",
+		},
+		{
+			name:   "untrusted peer can't forward",
+			remote: "203.0.113.7",
+			md:     metadata.Pairs(forwardedForKey, "198.51.100.1"),
+			want:   "203.0.113.7",
+		},
+		{
+			name:   "forwarded by a trusted proxy",
+			remote: "10.0.0.2",
+			md:     metadata.Pairs(forwardedForKey, "198.51.100.1"),
+			want:   "198.51.100.1",
+		},
+		{
+			name:   "spoofed hops before the client are ignored",
+			remote: "10.0.0.2",
+			md:     metadata.Pairs(forwardedForKey, "1.1.1.1, 198.51.100.1, 10.0.0.3"),
+			want:   "198.51.100.1",
+		},
+		{
+			name:   "forwarded in several values",
+			remote: "10.0.0.2",
+			md:     metadata.Pairs(forwardedForKey, "1.1.1.1", forwardedForKey, "198.51.100.1"),
+			want:   "198.51.100.1",
+		},
+		{
+			name:   "malformed hop",
+			remote: "10.0.0.2",
+			md:     metadata.Pairs(forwardedForKey, "198.51.100.1, unknown, 10.0.0.3"),
+			want:   "10.0.0.3",
+		},
+		{
+			name:   "real ip",
+			remote: "10.0.0.2",
+			md:     metadata.Pairs(realIPKey, "198.51.100.1"),
+			want:   "198.51.100.1",
+		},
+		{
+			name:   "ipv6",
+			remote: "fd00::1",
+			md:     metadata.Pairs(forwardedForKey, "2001:db8::1"),
+			want:   "2001:db8::1",
+		},
+		{
+			name:   "ipv4 mapped proxy",
+			remote: "::ffff:10.0.0.2",
+			md:     metadata.Pairs(forwardedForKey, "198.51.100.1"),
+			want:   "198.51.100.1",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, clientIP(tc.remote, tc.md, proxies))
+		})
+	}
+}
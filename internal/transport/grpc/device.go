@@ -0,0 +1,50 @@
+package grpc
+
+import (
+	"account/internal/domain"
+	"context"
+	"net"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// metadata keys sent by our clients to describe the device
+const (
+	userAgentKey  = "x-user-agent"
+	platformKey   = "x-platform"
+	deviceNameKey = "x-device-name"
+)
+
+// device collects client metadata of the request, grpc always sets its own
+// user-agent, so clients that want to pass the real one use x-user-agent
+func device(c context.Context) domain.Device {
+	md, _ := metadata.FromIncomingContext(c)
+
+	userAgent := first(md, userAgentKey)
+	if userAgent == "" {
+		userAgent = first(md, "user-agent")
+	}
+
+	return domain.NewDevice(domain.ClientIP(c), userAgent, first(md, platformKey), first(md, deviceNameKey))
+}
+
+func peerIP(c context.Context) string {
+	p, ok := peer.FromContext(c)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
@@ -0,0 +1,68 @@
+package grpc
+
+import (
+	"account/internal/domain"
+	"context"
+	"errors"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// errorCodes maps domain errors to grpc status codes,
+// the error text is kept as status message so clients can still match on it
+var errorCodes = map[error]codes.Code{
+	domain.ErrUserNotFound:         codes.NotFound,
+	domain.ErrInvalidUsername:      codes.InvalidArgument,
+	domain.ErrTooShortPassword:     codes.InvalidArgument,
+	domain.ErrPhoneAlreadyInUse:    codes.AlreadyExists,
+	domain.ErrUsernameAlreadyInUse: codes.AlreadyExists,
+	domain.ErrTooManyCodesSent:     codes.ResourceExhausted,
+	domain.ErrCodeSendingLimit:     codes.ResourceExhausted,
+	domain.ErrInvalidCredentials:   codes.Unauthenticated,
+	domain.ErrInvalidAccessToken:   codes.Unauthenticated,
+	domain.ErrSessionExpired:       codes.Unauthenticated,
+	domain.ErrAccessTokenExpired:   codes.Unauthenticated,
+	domain.ErrInvalidRefreshToken:  codes.Unauthenticated,
+	domain.ErrRefreshTokenReused:   codes.Unauthenticated,
+	domain.ErrSessionNotFound:      codes.NotFound,
+	domain.ErrTooManySessions:      codes.ResourceExhausted,
+	domain.ErrImpersonationDenied:  codes.PermissionDenied,
+	domain.ErrPermissionDenied:     codes.PermissionDenied,
+	domain.ErrReasonRequired:       codes.InvalidArgument,
+	domain.ErrImpersonatedSession:  codes.PermissionDenied,
+	domain.ErrInvalidCursor:        codes.InvalidArgument,
+	domain.ErrInvalidServiceToken:  codes.Unauthenticated,
+	domain.ErrInvalidCode:          codes.InvalidArgument,
+	domain.ErrCodeExpired:          codes.FailedPrecondition,
+	domain.ErrTooManyAttempts:      codes.ResourceExhausted,
+	domain.ErrSMSNotFound:          codes.NotFound,
+	domain.ErrInvalidChannel:       codes.InvalidArgument,
+	domain.ErrInvalidEmail:         codes.InvalidArgument,
+	domain.ErrEmailAlreadyInUse:    codes.AlreadyExists,
+	domain.ErrEmailDisabled:        codes.Unimplemented,
+}
+
+func errorInterceptor(c context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	res, err := handler(c, req)
+	return res, statusError(err)
+}
+
+func streamErrorInterceptor(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return statusError(handler(srv, stream))
+}
+
+func statusError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	for domainErr, code := range errorCodes {
+		if errors.Is(err, domainErr) {
+			return status.Error(code, domainErr.Error())
+		}
+	}
+
+	return err
+}
@@ -2,25 +2,41 @@ package grpc
 
 import (
 	"account/internal/application/dtos"
+	"account/internal/domain"
 	pb "account/proto"
 	"context"
+	"encoding/base64"
 	"net"
+	"net/netip"
 
 	"google.golang.org/grpc"
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type server struct {
 	pb.UnimplementedAccountServer
 	server *grpc.Server
 
+	// stopping is cancelled on Stop to end long-living streams,
+	// otherwise the graceful stop would wait for them forever
+	stopping context.Context
+	stop     context.CancelFunc
+
 	useCase UseCase
 }
 
-func New(useCase UseCase) *server {
+// New takes CIDRs of proxies trusted to forward the client address
+func New(useCase UseCase, trustedProxies []netip.Prefix) *server {
+	stopping, stop := context.WithCancel(context.Background())
 	return &server{
-		server:  grpc.NewServer(),
-		useCase: useCase}
+		server: grpc.NewServer(
+			grpc.ChainUnaryInterceptor(errorInterceptor, clientIPInterceptor(trustedProxies)),
+			grpc.ChainStreamInterceptor(streamErrorInterceptor, streamClientIPInterceptor(trustedProxies)),
+		),
+		stopping: stopping,
+		stop:     stop,
+		useCase:  useCase}
 }
 
 func (s *server) Run(socket string) error {
@@ -35,12 +51,16 @@ func (s *server) Run(socket string) error {
 }
 
 func (s *server) Stop() {
+	s.stop()
 	s.server.GracefulStop()
 }
 
 func (s *server) Register(c context.Context, req *pb.RegisterReq) (*emptypb.Empty, error) {
 	err := s.useCase.Register(c, &dtos.RegisterInput{
-		Phone: req.Phone,
+		Phone:   req.Phone,
+		Locale:  req.Locale,
+		Channel: req.Channel,
+		IP:      domain.ClientIP(c),
 	})
 
 	return &emptypb.Empty{}, err
@@ -60,6 +80,8 @@ func (s *server) CompleteRegister(c context.Context, req *pb.CompleteRegisterReq
 		Code:     req.Code,
 		Username: req.Username,
 		Password: req.Password,
+		Locale:   req.Locale,
+		Device:   device(c),
 	})
 
 	if err != nil {
@@ -68,18 +90,193 @@ func (s *server) CompleteRegister(c context.Context, req *pb.CompleteRegisterReq
 		}, err
 	}
 
-	return &pb.AuthRes{
-		AccessToken: res.AccessToken,
-	}, nil
+	return authRes(res), nil
 }
 
 func (s *server) Login(c context.Context, req *pb.LoginReq) (*pb.AuthRes, error) {
-	res, err := s.useCase.Login(c, &dtos.LoginInput{Phone: req.Phone, Password: req.Password})
+	res, err := s.useCase.Login(c, &dtos.LoginInput{
+		Phone:    req.Phone,
+		Email:    req.Email,
+		Password: req.Password,
+		Device:   device(c),
+	})
 	if err != nil {
 		return &pb.AuthRes{AccessToken: ""}, err
 	}
 
+	return authRes(res), nil
+}
+
+func (s *server) Refresh(c context.Context, req *pb.RefreshReq) (*pb.AuthRes, error) {
+	res, err := s.useCase.Refresh(c, &dtos.RefreshInput{RefreshToken: req.RefreshToken})
+	if err != nil {
+		return &pb.AuthRes{}, err
+	}
+
+	return authRes(res), nil
+}
+
+func authRes(res *dtos.AuthOutput) *pb.AuthRes {
+	evicted := make([]*pb.Session, 0, len(res.EvictedSessions))
+	for _, session := range res.EvictedSessions {
+		evicted = append(evicted, sessionRes(&session))
+	}
+
 	return &pb.AuthRes{
-		AccessToken: res.AccessToken,
+		AccessToken:     res.AccessToken,
+		RefreshToken:    res.RefreshToken,
+		ExpiresIn:       int32(res.ExpiresIn),
+		EvictedSessions: evicted,
+	}
+}
+
+func sessionRes(session *dtos.SessionOutput) *pb.Session {
+	return &pb.Session{
+		Id:         int32(session.ID),
+		Ip:         session.IP,
+		UserAgent:  session.UserAgent,
+		Platform:   session.Platform,
+		DeviceName: session.DeviceName,
+		CreatedAt:  timestamppb.New(session.CreatedAt),
+		LastSeenAt: timestamppb.New(session.LastSeenAt),
+		Current:    session.Current,
+
+		Impersonated: session.Impersonated,
+	}
+}
+
+func (s *server) ValidateSession(c context.Context, req *pb.ValidateSessionReq) (*pb.ValidateSessionRes, error) {
+	res, err := s.useCase.ValidateSession(c, &dtos.ValidateSessionInput{AccessToken: req.AccessToken})
+	if err != nil {
+		return &pb.ValidateSessionRes{}, err
+	}
+
+	return &pb.ValidateSessionRes{
+		UserId:   int32(res.UserID),
+		Username: res.Username,
+		Role: &pb.Role{
+			Id:          int32(res.Role.ID),
+			Name:        res.Role.Name,
+			Permissions: res.Role.Permissions,
+		},
+		ImpersonatorId: int32(res.ImpersonatorID),
+		SessionId:      int32(res.SessionID),
+	}, nil
+}
+
+func (s *server) Logout(c context.Context, req *pb.LogoutReq) (*emptypb.Empty, error) {
+	err := s.useCase.Logout(c, &dtos.LogoutInput{AccessToken: req.AccessToken})
+	return &emptypb.Empty{}, err
+}
+
+func (s *server) LogoutAll(c context.Context, req *pb.LogoutAllReq) (*emptypb.Empty, error) {
+	err := s.useCase.LogoutAll(c, &dtos.LogoutAllInput{
+		AccessToken: req.AccessToken,
+		KeepCurrent: req.KeepCurrent,
+	})
+	return &emptypb.Empty{}, err
+}
+
+func (s *server) ListSessions(c context.Context, req *pb.ListSessionsReq) (*pb.ListSessionsRes, error) {
+	sessions, err := s.useCase.ListSessions(c, &dtos.ListSessionsInput{AccessToken: req.AccessToken})
+	if err != nil {
+		return &pb.ListSessionsRes{}, err
+	}
+
+	res := &pb.ListSessionsRes{Sessions: make([]*pb.Session, 0, len(sessions))}
+	for _, session := range sessions {
+		res.Sessions = append(res.Sessions, sessionRes(&session))
+	}
+
+	return res, nil
+}
+
+func (s *server) RevokeSession(c context.Context, req *pb.RevokeSessionReq) (*emptypb.Empty, error) {
+	err := s.useCase.RevokeSession(c, &dtos.RevokeSessionInput{
+		AccessToken: req.AccessToken,
+		SessionID:   int(req.SessionId),
+	})
+	return &emptypb.Empty{}, err
+}
+
+func (s *server) GetJWKS(c context.Context, req *emptypb.Empty) (*pb.JWKSRes, error) {
+	keys := s.useCase.GetJWKS(c)
+
+	res := &pb.JWKSRes{Keys: make([]*pb.JWK, 0, len(keys))}
+	for _, key := range keys {
+		res.Keys = append(res.Keys, &pb.JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key.Key),
+			Kid: key.ID,
+			Use: "sig",
+			Alg: "EdDSA",
+		})
+	}
+
+	return res, nil
+}
+
+func (s *server) Impersonate(c context.Context, req *pb.ImpersonateReq) (*pb.AuthRes, error) {
+	res, err := s.useCase.Impersonate(c, &dtos.ImpersonateInput{
+		AccessToken:  req.AccessToken,
+		TargetUserID: int(req.TargetUserId),
+		Reason:       req.Reason,
+		Device:       device(c),
+	})
+	if err != nil {
+		return &pb.AuthRes{}, err
+	}
+
+	return authRes(res), nil
+}
+
+func (s *server) WatchRevocations(req *pb.WatchRevocationsReq, stream pb.Account_WatchRevocationsServer) error {
+	c, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	defer context.AfterFunc(s.stopping, cancel)()
+
+	return s.useCase.WatchRevocations(c, &dtos.WatchRevocationsInput{Cursor: req.Cursor, Token: req.Token}, func(revocation *dtos.RevocationOutput) error {
+		return stream.Send(&pb.Revocation{
+			Cursor:    revocation.Cursor,
+			SessionId: int32(revocation.SessionID),
+			UserId:    int32(revocation.UserID),
+			Reason:    revocation.Reason,
+			RevokedAt: timestamppb.New(revocation.RevokedAt),
+		})
+	})
+}
+
+func (s *server) GetCodeDeliveryStatus(c context.Context, req *pb.GetCodeDeliveryStatusReq) (*pb.CodeDeliveryStatus, error) {
+	res, err := s.useCase.GetCodeDeliveryStatus(c, &dtos.CodeDeliveryStatusInput{
+		AccessToken: req.AccessToken,
+		Phone:       req.Phone,
+	})
+	if err != nil {
+		return &pb.CodeDeliveryStatus{}, err
+	}
+
+	return &pb.CodeDeliveryStatus{
+		Status:    res.Status,
+		Channel:   res.Channel,
+		Attempts:  int32(res.Attempts),
+		QueuedAt:  timestamppb.New(res.QueuedAt),
+		UpdatedAt: timestamppb.New(res.UpdatedAt),
 	}, nil
 }
+
+func (s *server) AddEmail(c context.Context, req *pb.AddEmailReq) (*emptypb.Empty, error) {
+	err := s.useCase.AddEmail(c, &dtos.AddEmailInput{
+		AccessToken: req.AccessToken,
+		Email:       req.Email,
+	})
+	return &emptypb.Empty{}, err
+}
+
+func (s *server) VerifyEmail(c context.Context, req *pb.VerifyEmailReq) (*emptypb.Empty, error) {
+	err := s.useCase.VerifyEmail(c, &dtos.VerifyEmailInput{
+		AccessToken: req.AccessToken,
+		Code:        req.Code,
+	})
+	return &emptypb.Empty{}, err
+}
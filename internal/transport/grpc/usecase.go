@@ -2,6 +2,7 @@ package grpc
 
 import (
 	"account/internal/application/dtos"
+	"account/internal/domain"
 	"context"
 )
 
@@ -10,4 +11,16 @@ type UseCase interface {
 	ConfirmCode(c context.Context, dto *dtos.ConfirmCodeInput) error
 	CompleteRegister(c context.Context, dto *dtos.CompleteRegisterInput) (*dtos.AuthOutput, error)
 	Login(c context.Context, dto *dtos.LoginInput) (*dtos.AuthOutput, error)
+	Refresh(c context.Context, dto *dtos.RefreshInput) (*dtos.AuthOutput, error)
+	ValidateSession(c context.Context, dto *dtos.ValidateSessionInput) (*dtos.ValidateSessionOutput, error)
+	Logout(c context.Context, dto *dtos.LogoutInput) error
+	LogoutAll(c context.Context, dto *dtos.LogoutAllInput) error
+	ListSessions(c context.Context, dto *dtos.ListSessionsInput) ([]dtos.SessionOutput, error)
+	RevokeSession(c context.Context, dto *dtos.RevokeSessionInput) error
+	GetJWKS(c context.Context) []domain.PublicKey
+	Impersonate(c context.Context, dto *dtos.ImpersonateInput) (*dtos.AuthOutput, error)
+	WatchRevocations(c context.Context, dto *dtos.WatchRevocationsInput, send func(revocation *dtos.RevocationOutput) error) error
+	GetCodeDeliveryStatus(c context.Context, dto *dtos.CodeDeliveryStatusInput) (*dtos.CodeDeliveryStatusOutput, error)
+	AddEmail(c context.Context, dto *dtos.AddEmailInput) error
+	VerifyEmail(c context.Context, dto *dtos.VerifyEmailInput) error
 }
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"account/internal/application/dtos"
+	"account/internal/domain"
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// CallbackParser reads delivery reports in the format of the provider
+type CallbackParser interface {
+	ParseCallback(provider string, r *http.Request) ([]domain.SMSReport, error)
+	VerifyCallback(provider string, r *http.Request) (string, error)
+}
+
+// maxReportSize limits the body of a delivery report
+const maxReportSize = 1 << 20
+
+// server receives delivery reports of sms providers
+type server struct {
+	server *http.Server
+
+	useCase UseCase
+	parser  CallbackParser
+	token   string
+}
+
+func New(useCase UseCase, parser CallbackParser, token string) *server {
+	s := &server{useCase: useCase, parser: parser, token: token}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sms/status/{provider}", s.smsStatus)
+
+	s.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: time.Second * 10,
+	}
+	return s
+}
+
+func (s *server) Run(addr string) error {
+	s.server.Addr = addr
+
+	err := s.server.ListenAndServe()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *server) Stop(c context.Context) error {
+	return s.server.Shutdown(c)
+}
+
+// smsStatus handles /sms/status/{provider}?token=..., providers retry reports
+// answered with an error, so reports of unknown messages are acknowledged
+func (s *server) smsStatus(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.URL.Query().Get("token")), []byte(s.token)) != 1 {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	provider := r.PathValue("provider")
+
+	// whatsapp confirms the url with a GET request before subscribing it
+	if r.Method == http.MethodGet && r.URL.Query().Has("hub.mode") {
+		challenge, err := s.parser.VerifyCallback(provider, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(challenge))
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxReportSize)
+	reports, err := s.parser.ParseCallback(provider, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, report := range reports {
+		err = s.useCase.UpdateSMSStatus(r.Context(), &dtos.SMSStatusInput{
+			Provider:  provider,
+			MessageID: report.MessageID,
+			Status:    report.Status,
+		})
+		if err != nil && !errors.Is(err, domain.ErrSMSNotFound) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,178 @@
+package http
+
+import (
+	"account/internal/application/dtos"
+	"account/internal/domain"
+	"account/internal/infrastructure/configuration"
+	"account/internal/infrastructure/sms"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+)
+
+type fakeUseCase struct {
+	inputs []*dtos.SMSStatusInput
+	err    error
+}
+
+func (u *fakeUseCase) UpdateSMSStatus(c context.Context, dto *dtos.SMSStatusInput) error {
+	u.inputs = append(u.inputs, dto)
+	return u.err
+}
+
+func TestSMSStatus(t *testing.T) {
+	parser, err := sms.New(&configuration.SMSConfig{
+		Senders:             []string{"smsc"},
+		Channels:            []string{"whatsapp"},
+		WhatsAppVerifyToken: "verify",
+		WhatsAppAppSecret:   "appsecret",
+	}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := func(path string, form url.Values) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+	delivered := url.Values{"id": {"25"}, "status": {"1"}}
+
+	testCases := []struct {
+		name    string
+		request *http.Request
+		err     error
+		want    int
+	}{
+		{
+			name:    "no token",
+			request: report("/sms/status/smsc", delivered),
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name:    "wrong token",
+			request: report("/sms/status/smsc?token=wrong", delivered),
+			want:    http.StatusUnauthorized,
+		},
+		{
+			name:    "unknown provider",
+			request: report("/sms/status/pigeon?token=secret", delivered),
+			want:    http.StatusBadRequest,
+		},
+		{
+			name:    "bad body",
+			request: report("/sms/status/smsc?token=secret", url.Values{"status": {"1"}}),
+			want:    http.StatusBadRequest,
+		},
+		{
+			name:    "unknown message is acknowledged",
+			request: report("/sms/status/smsc?token=secret", delivered),
+			err:     domain.ErrSMSNotFound,
+			want:    http.StatusOK,
+		},
+		{
+			name:    "failed to save",
+			request: report("/sms/status/smsc?token=secret", delivered),
+			err:     errors.New("db is down"),
+			want:    http.StatusInternalServerError,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			useCase := &fakeUseCase{err: tc.err}
+			w := httptest.NewRecorder()
+
+			New(useCase, parser, "secret").server.Handler.ServeHTTP(w, tc.request)
+
+			assert.Equal(t, tc.want, w.Code)
+			if tc.want == http.StatusUnauthorized || tc.want == http.StatusBadRequest {
+				assert.Empty(t, useCase.inputs)
+			}
+		})
+	}
+
+	t.Run("success", func(t *testing.T) {
+		useCase := &fakeUseCase{}
+		w := httptest.NewRecorder()
+
+		New(useCase, parser, "secret").server.Handler.ServeHTTP(w, report("/sms/status/smsc?token=secret", delivered))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []*dtos.SMSStatusInput{{Provider: "smsc", MessageID: "25", Status: domain.SMSDelivered}}, useCase.inputs)
+	})
+
+	t.Run("batch of reports", func(t *testing.T) {
+		body := `{"entry":[{"changes":[{"value":{"statuses":[{"id":"wamid.1","status":"delivered"},{"id":"wamid.2","status":"failed"}]}}]}]}`
+		mac := hmac.New(sha256.New, []byte("appsecret"))
+		mac.Write([]byte(body))
+
+		r := httptest.NewRequest(http.MethodPost, "/sms/status/whatsapp?token=secret", strings.NewReader(body))
+		r.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+		useCase := &fakeUseCase{}
+		w := httptest.NewRecorder()
+
+		New(useCase, parser, "secret").server.Handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []*dtos.SMSStatusInput{
+			{Provider: "whatsapp", MessageID: "wamid.1", Status: domain.SMSDelivered},
+			{Provider: "whatsapp", MessageID: "wamid.2", Status: domain.SMSFailed},
+		}, useCase.inputs)
+	})
+
+	t.Run("unsigned reports", func(t *testing.T) {
+		body := `{"entry":[{"changes":[{"value":{"statuses":[{"id":"wamid.1","status":"delivered"}]}}]}]}`
+		r := httptest.NewRequest(http.MethodPost, "/sms/status/whatsapp?token=secret", strings.NewReader(body))
+
+		useCase := &fakeUseCase{}
+		w := httptest.NewRecorder()
+
+		New(useCase, parser, "secret").server.Handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, useCase.inputs)
+	})
+
+	t.Run("too large body", func(t *testing.T) {
+		form := url.Values{"id": {"25"}, "status": {"1"}, "padding": {strings.Repeat("a", maxReportSize)}}
+
+		useCase := &fakeUseCase{}
+		w := httptest.NewRecorder()
+
+		New(useCase, parser, "secret").server.Handler.ServeHTTP(w, report("/sms/status/smsc?token=secret", form))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Empty(t, useCase.inputs)
+	})
+
+	t.Run("verify callback", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/sms/status/whatsapp?token=secret&hub.mode=subscribe&hub.verify_token=verify&hub.challenge=1158201444", nil)
+		w := httptest.NewRecorder()
+
+		New(&fakeUseCase{}, parser, "secret").server.Handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "1158201444", w.Body.String())
+	})
+
+	t.Run("verify callback with wrong token", func(t *testing.T) {
+		r := httptest.NewRequest(http.MethodGet, "/sms/status/whatsapp?token=secret&hub.mode=subscribe&hub.verify_token=wrong&hub.challenge=1158201444", nil)
+		w := httptest.NewRecorder()
+
+		New(&fakeUseCase{}, parser, "secret").server.Handler.ServeHTTP(w, r)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+}
@@ -0,0 +1,10 @@
+package http
+
+import (
+	"account/internal/application/dtos"
+	"context"
+)
+
+type UseCase interface {
+	UpdateSMSStatus(c context.Context, dto *dtos.SMSStatusInput) error
+}
@@ -0,0 +1,6 @@
+-- sessions expiry
+
+ALTER TABLE sessions
+  ADD COLUMN last_seen_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id);
@@ -0,0 +1,19 @@
+-- refresh tokens
+
+ALTER TABLE sessions ADD COLUMN id SERIAL PRIMARY KEY;
+
+ALTER TABLE sessions
+  ADD COLUMN access_expires_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- sessions created before refresh tokens have no way to be renewed,
+-- so their access tokens stay valid until the session itself expires
+UPDATE sessions SET access_expires_at = created_at + INTERVAL '30 days';
+
+CREATE TABLE refresh_tokens (
+  token      TEXT NOT NULL UNIQUE,
+  session_id INTEGER NOT NULL REFERENCES sessions (id) ON DELETE CASCADE,
+  used_at    TIMESTAMP WITHOUT TIME ZONE,
+  created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX refresh_tokens_session_id_idx ON refresh_tokens (session_id);
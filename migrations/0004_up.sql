@@ -0,0 +1,9 @@
+-- tokens are stored as HMAC-SHA256 digests keyed with SESSION_TOKEN_SECRET,
+-- raw tokens of existing rows can't be rehashed without the secret,
+-- so all existing sessions are invalidated and users have to log in again
+
+TRUNCATE TABLE sessions CASCADE;
+
+ALTER TABLE sessions RENAME COLUMN access_token TO access_token_hash;
+
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
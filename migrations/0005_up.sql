@@ -0,0 +1,7 @@
+-- sessions device metadata
+
+ALTER TABLE sessions
+  ADD COLUMN ip          VARCHAR(45) NOT NULL DEFAULT '',
+  ADD COLUMN user_agent  VARCHAR(256) NOT NULL DEFAULT '',
+  ADD COLUMN platform    VARCHAR(64) NOT NULL DEFAULT '',
+  ADD COLUMN device_name VARCHAR(128) NOT NULL DEFAULT '';
@@ -0,0 +1,12 @@
+-- notify service instances about revoked sessions, so they drop them from cache
+
+CREATE FUNCTION notify_session_revoked() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('session_revoked', OLD.access_token_hash);
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER sessions_revoked
+  AFTER DELETE OR UPDATE OF access_token_hash ON sessions
+  FOR EACH ROW EXECUTE FUNCTION notify_session_revoked();
@@ -0,0 +1,19 @@
+-- impersonation sessions of staff members
+
+ALTER TABLE sessions
+  ADD COLUMN impersonator_id      INTEGER,
+  ADD COLUMN impersonation_reason TEXT NOT NULL DEFAULT '';
+
+-- audit log, it's kept after the sessions are removed
+CREATE TABLE impersonations (
+  id             SERIAL PRIMARY KEY,
+  session_id     INTEGER NOT NULL,
+  staff_id       INTEGER NOT NULL,
+  target_user_id INTEGER NOT NULL,
+  reason         TEXT NOT NULL,
+  ip             VARCHAR(45) NOT NULL DEFAULT '',
+  created_at     TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX impersonations_staff_id_idx ON impersonations (staff_id);
+CREATE INDEX impersonations_target_user_id_idx ON impersonations (target_user_id);
@@ -0,0 +1,13 @@
+-- feed of revoked sessions for token caching gateways
+
+CREATE TABLE session_revocations (
+  id         BIGSERIAL PRIMARY KEY,
+  txid       XID8 NOT NULL DEFAULT pg_current_xact_id(),
+  session_id INTEGER NOT NULL,
+  user_id    INTEGER NOT NULL,
+  reason     VARCHAR(32) NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX session_revocations_cursor_idx ON session_revocations (txid, id);
+CREATE INDEX session_revocations_created_at_idx ON session_revocations (created_at);
@@ -0,0 +1,7 @@
+-- verification codes expiry
+
+-- codes sent before the expiry was introduced are expired right away
+ALTER TABLE codes
+  ADD COLUMN expires_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+CREATE INDEX codes_phone_created_at_idx ON codes (phone, created_at);
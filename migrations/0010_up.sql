@@ -0,0 +1,11 @@
+-- verification attempts
+
+ALTER TABLE codes ADD COLUMN id SERIAL PRIMARY KEY;
+ALTER TABLE codes ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE code_failures (
+  phone      VARCHAR(10) NOT NULL,
+  created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX code_failures_phone_created_at_idx ON code_failures (phone, created_at);
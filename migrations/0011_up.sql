@@ -0,0 +1,16 @@
+-- outbox of sms messages, they are written with the codes and delivered in background
+
+CREATE TABLE sms_outbox (
+  id              SERIAL PRIMARY KEY,
+  recipient       VARCHAR(15) NOT NULL,
+  text            TEXT NOT NULL, -- cleared once the message is delivered or given up
+  status          VARCHAR(10) NOT NULL DEFAULT 'queued', -- queued, sent or dead
+  attempts        INTEGER NOT NULL DEFAULT 0,
+  last_error      TEXT NOT NULL DEFAULT '',
+  next_attempt_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  created_at      TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at      TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+  sent_at         TIMESTAMP WITHOUT TIME ZONE
+);
+
+CREATE INDEX sms_outbox_queued_idx ON sms_outbox (next_attempt_at) WHERE status = 'queued';
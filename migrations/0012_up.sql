@@ -0,0 +1,3 @@
+-- preferred language of messages
+
+ALTER TABLE users ADD COLUMN locale VARCHAR(2) NOT NULL DEFAULT 'kk';
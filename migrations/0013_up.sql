@@ -0,0 +1,10 @@
+-- codes are accepted only by the flow they were issued for
+
+ALTER TABLE codes ADD COLUMN purpose VARCHAR(20) NOT NULL DEFAULT 'register';
+ALTER TABLE code_failures ADD COLUMN purpose VARCHAR(20) NOT NULL DEFAULT 'register';
+
+DROP INDEX codes_phone_created_at_idx;
+CREATE INDEX codes_phone_purpose_created_at_idx ON codes (phone, purpose, created_at);
+
+DROP INDEX code_failures_phone_created_at_idx;
+CREATE INDEX code_failures_phone_purpose_created_at_idx ON code_failures (phone, purpose, created_at);
@@ -0,0 +1,8 @@
+-- codes are stored as keyed hashes with a per-row salt,
+-- codes pending at the time of migration can't be verified and have to be requested again
+
+ALTER TABLE codes
+  ADD COLUMN code_hash VARCHAR(64) NOT NULL DEFAULT '',
+  ADD COLUMN salt      VARCHAR(32) NOT NULL DEFAULT '';
+
+ALTER TABLE codes DROP COLUMN code;
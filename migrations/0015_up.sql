@@ -0,0 +1,11 @@
+-- delivery status of sent sms reported by providers
+
+ALTER TABLE sms_outbox
+  ADD COLUMN provider            VARCHAR(20) NOT NULL DEFAULT '',
+  ADD COLUMN provider_message_id VARCHAR(64) NOT NULL DEFAULT '',
+  ADD COLUMN status_at           TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  ADD COLUMN checked_at          TIMESTAMP WITHOUT TIME ZONE; -- last time the status was polled
+
+CREATE INDEX sms_outbox_provider_message_id_idx ON sms_outbox (provider, provider_message_id);
+CREATE INDEX sms_outbox_recipient_created_at_idx ON sms_outbox (recipient, created_at);
+CREATE INDEX sms_outbox_sent_idx ON sms_outbox (sent_at) WHERE status = 'sent';
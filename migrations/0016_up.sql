@@ -0,0 +1,9 @@
+-- codes sent through telegram, whatsapp and flash calls
+
+ALTER TABLE codes
+  ADD COLUMN channel VARCHAR(10) NOT NULL DEFAULT 'sms'; -- requested channel
+
+ALTER TABLE sms_outbox
+  ADD COLUMN code    VARCHAR(32) NOT NULL DEFAULT '', -- cleared with text once the message is sent
+  ADD COLUMN channel VARCHAR(10) NOT NULL DEFAULT 'sms',
+  ADD COLUMN locale  VARCHAR(2) NOT NULL DEFAULT 'kk';
@@ -0,0 +1,18 @@
+-- verified emails of users and codes sent to confirm them
+
+ALTER TABLE users ADD COLUMN email VARCHAR(254); -- set only once verified, in lower case
+
+CREATE UNIQUE INDEX users_email_idx ON users (email);
+
+CREATE TABLE email_codes (
+  id         SERIAL PRIMARY KEY,
+  user_id    INTEGER NOT NULL,
+  email      VARCHAR(254) NOT NULL,
+  code_hash  VARCHAR(64) NOT NULL,
+  salt       VARCHAR(32) NOT NULL,
+  attempts   INTEGER NOT NULL DEFAULT 0,
+  created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  expires_at TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+CREATE INDEX email_codes_user_id_created_at_idx ON email_codes (user_id, created_at);
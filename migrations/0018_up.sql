@@ -0,0 +1,5 @@
+-- expired codes, old failures and finished sms are purged in background
+
+CREATE INDEX codes_expires_at_idx ON codes (expires_at);
+CREATE INDEX code_failures_created_at_idx ON code_failures (created_at);
+CREATE INDEX sms_outbox_finished_expires_at_idx ON sms_outbox (expires_at) WHERE status <> 'queued';
@@ -0,0 +1,4 @@
+-- texts of queued sms are stored encrypted, messages queued with plain texts are given up
+
+UPDATE sms_outbox SET status = 'dead', text = '', code = '', last_error = 'stored unencrypted', status_at = NOW()
+  WHERE status = 'queued';
@@ -0,0 +1,3 @@
+-- codes of queued messages are stored encrypted like their texts, which doesn't fit the old column
+
+ALTER TABLE sms_outbox ALTER COLUMN code TYPE TEXT;
@@ -0,0 +1,3 @@
+-- expired email codes are purged in background with sms codes
+
+CREATE INDEX email_codes_expires_at_idx ON email_codes (expires_at);
@@ -0,0 +1,25 @@
+-- sessions are cached with the username and the role of their user,
+-- instances drop them when the user or the role changes
+
+CREATE FUNCTION notify_user_changed() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('session_revoked', access_token_hash) FROM sessions WHERE user_id = OLD.id;
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER users_changed
+  AFTER DELETE OR UPDATE OF username, role_id ON users
+  FOR EACH ROW EXECUTE FUNCTION notify_user_changed();
+
+-- a role is shared by many sessions, an empty payload drops all cached sessions
+CREATE FUNCTION notify_role_changed() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('session_revoked', '');
+  RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER roles_changed
+  AFTER DELETE OR UPDATE OF name, permissions ON roles
+  FOR EACH ROW EXECUTE FUNCTION notify_role_changed();
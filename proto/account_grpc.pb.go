@@ -20,10 +20,22 @@ import (
 const _ = grpc.SupportPackageIsVersion9
 
 const (
-	Account_Register_FullMethodName         = "/account_proto.Account/Register"
-	Account_ConfirmCode_FullMethodName      = "/account_proto.Account/ConfirmCode"
-	Account_CompleteRegister_FullMethodName = "/account_proto.Account/CompleteRegister"
-	Account_Login_FullMethodName            = "/account_proto.Account/Login"
+	Account_Register_FullMethodName              = "/account_proto.Account/Register"
+	Account_ConfirmCode_FullMethodName           = "/account_proto.Account/ConfirmCode"
+	Account_CompleteRegister_FullMethodName      = "/account_proto.Account/CompleteRegister"
+	Account_Login_FullMethodName                 = "/account_proto.Account/Login"
+	Account_Refresh_FullMethodName               = "/account_proto.Account/Refresh"
+	Account_ValidateSession_FullMethodName       = "/account_proto.Account/ValidateSession"
+	Account_Logout_FullMethodName                = "/account_proto.Account/Logout"
+	Account_LogoutAll_FullMethodName             = "/account_proto.Account/LogoutAll"
+	Account_ListSessions_FullMethodName          = "/account_proto.Account/ListSessions"
+	Account_RevokeSession_FullMethodName         = "/account_proto.Account/RevokeSession"
+	Account_GetJWKS_FullMethodName               = "/account_proto.Account/GetJWKS"
+	Account_Impersonate_FullMethodName           = "/account_proto.Account/Impersonate"
+	Account_WatchRevocations_FullMethodName      = "/account_proto.Account/WatchRevocations"
+	Account_GetCodeDeliveryStatus_FullMethodName = "/account_proto.Account/GetCodeDeliveryStatus"
+	Account_AddEmail_FullMethodName              = "/account_proto.Account/AddEmail"
+	Account_VerifyEmail_FullMethodName           = "/account_proto.Account/VerifyEmail"
 )
 
 // AccountClient is the client API for Account service.
@@ -34,6 +46,18 @@ type AccountClient interface {
 	ConfirmCode(ctx context.Context, in *ConfirmCodeReq, opts ...grpc.CallOption) (*emptypb.Empty, error)
 	CompleteRegister(ctx context.Context, in *CompleteRegisterReq, opts ...grpc.CallOption) (*AuthRes, error)
 	Login(ctx context.Context, in *LoginReq, opts ...grpc.CallOption) (*AuthRes, error)
+	Refresh(ctx context.Context, in *RefreshReq, opts ...grpc.CallOption) (*AuthRes, error)
+	ValidateSession(ctx context.Context, in *ValidateSessionReq, opts ...grpc.CallOption) (*ValidateSessionRes, error)
+	Logout(ctx context.Context, in *LogoutReq, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	LogoutAll(ctx context.Context, in *LogoutAllReq, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	ListSessions(ctx context.Context, in *ListSessionsReq, opts ...grpc.CallOption) (*ListSessionsRes, error)
+	RevokeSession(ctx context.Context, in *RevokeSessionReq, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	GetJWKS(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*JWKSRes, error)
+	Impersonate(ctx context.Context, in *ImpersonateReq, opts ...grpc.CallOption) (*AuthRes, error)
+	WatchRevocations(ctx context.Context, in *WatchRevocationsReq, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Revocation], error)
+	GetCodeDeliveryStatus(ctx context.Context, in *GetCodeDeliveryStatusReq, opts ...grpc.CallOption) (*CodeDeliveryStatus, error)
+	AddEmail(ctx context.Context, in *AddEmailReq, opts ...grpc.CallOption) (*emptypb.Empty, error)
+	VerifyEmail(ctx context.Context, in *VerifyEmailReq, opts ...grpc.CallOption) (*emptypb.Empty, error)
 }
 
 type accountClient struct {
@@ -84,6 +108,135 @@ func (c *accountClient) Login(ctx context.Context, in *LoginReq, opts ...grpc.Ca
 	return out, nil
 }
 
+func (c *accountClient) Refresh(ctx context.Context, in *RefreshReq, opts ...grpc.CallOption) (*AuthRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AuthRes)
+	err := c.cc.Invoke(ctx, Account_Refresh_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *accountClient) ValidateSession(ctx context.Context, in *ValidateSessionReq, opts ...grpc.CallOption) (*ValidateSessionRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ValidateSessionRes)
+	err := c.cc.Invoke(ctx, Account_ValidateSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *accountClient) Logout(ctx context.Context, in *LogoutReq, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Account_Logout_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *accountClient) LogoutAll(ctx context.Context, in *LogoutAllReq, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Account_LogoutAll_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *accountClient) ListSessions(ctx context.Context, in *ListSessionsReq, opts ...grpc.CallOption) (*ListSessionsRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSessionsRes)
+	err := c.cc.Invoke(ctx, Account_ListSessions_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *accountClient) RevokeSession(ctx context.Context, in *RevokeSessionReq, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Account_RevokeSession_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *accountClient) GetJWKS(ctx context.Context, in *emptypb.Empty, opts ...grpc.CallOption) (*JWKSRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(JWKSRes)
+	err := c.cc.Invoke(ctx, Account_GetJWKS_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *accountClient) Impersonate(ctx context.Context, in *ImpersonateReq, opts ...grpc.CallOption) (*AuthRes, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AuthRes)
+	err := c.cc.Invoke(ctx, Account_Impersonate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *accountClient) WatchRevocations(ctx context.Context, in *WatchRevocationsReq, opts ...grpc.CallOption) (grpc.ServerStreamingClient[Revocation], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Account_ServiceDesc.Streams[0], Account_WatchRevocations_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRevocationsReq, Revocation]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Account_WatchRevocationsClient = grpc.ServerStreamingClient[Revocation]
+
+func (c *accountClient) GetCodeDeliveryStatus(ctx context.Context, in *GetCodeDeliveryStatusReq, opts ...grpc.CallOption) (*CodeDeliveryStatus, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CodeDeliveryStatus)
+	err := c.cc.Invoke(ctx, Account_GetCodeDeliveryStatus_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *accountClient) AddEmail(ctx context.Context, in *AddEmailReq, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Account_AddEmail_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *accountClient) VerifyEmail(ctx context.Context, in *VerifyEmailReq, opts ...grpc.CallOption) (*emptypb.Empty, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(emptypb.Empty)
+	err := c.cc.Invoke(ctx, Account_VerifyEmail_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AccountServer is the server API for Account service.
 // All implementations must embed UnimplementedAccountServer
 // for forward compatibility.
@@ -92,6 +245,18 @@ type AccountServer interface {
 	ConfirmCode(context.Context, *ConfirmCodeReq) (*emptypb.Empty, error)
 	CompleteRegister(context.Context, *CompleteRegisterReq) (*AuthRes, error)
 	Login(context.Context, *LoginReq) (*AuthRes, error)
+	Refresh(context.Context, *RefreshReq) (*AuthRes, error)
+	ValidateSession(context.Context, *ValidateSessionReq) (*ValidateSessionRes, error)
+	Logout(context.Context, *LogoutReq) (*emptypb.Empty, error)
+	LogoutAll(context.Context, *LogoutAllReq) (*emptypb.Empty, error)
+	ListSessions(context.Context, *ListSessionsReq) (*ListSessionsRes, error)
+	RevokeSession(context.Context, *RevokeSessionReq) (*emptypb.Empty, error)
+	GetJWKS(context.Context, *emptypb.Empty) (*JWKSRes, error)
+	Impersonate(context.Context, *ImpersonateReq) (*AuthRes, error)
+	WatchRevocations(*WatchRevocationsReq, grpc.ServerStreamingServer[Revocation]) error
+	GetCodeDeliveryStatus(context.Context, *GetCodeDeliveryStatusReq) (*CodeDeliveryStatus, error)
+	AddEmail(context.Context, *AddEmailReq) (*emptypb.Empty, error)
+	VerifyEmail(context.Context, *VerifyEmailReq) (*emptypb.Empty, error)
 	mustEmbedUnimplementedAccountServer()
 }
 
@@ -114,6 +279,42 @@ func (UnimplementedAccountServer) CompleteRegister(context.Context, *CompleteReg
 func (UnimplementedAccountServer) Login(context.Context, *LoginReq) (*AuthRes, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Login not implemented")
 }
+func (UnimplementedAccountServer) Refresh(context.Context, *RefreshReq) (*AuthRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Refresh not implemented")
+}
+func (UnimplementedAccountServer) ValidateSession(context.Context, *ValidateSessionReq) (*ValidateSessionRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ValidateSession not implemented")
+}
+func (UnimplementedAccountServer) Logout(context.Context, *LogoutReq) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Logout not implemented")
+}
+func (UnimplementedAccountServer) LogoutAll(context.Context, *LogoutAllReq) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method LogoutAll not implemented")
+}
+func (UnimplementedAccountServer) ListSessions(context.Context, *ListSessionsReq) (*ListSessionsRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSessions not implemented")
+}
+func (UnimplementedAccountServer) RevokeSession(context.Context, *RevokeSessionReq) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeSession not implemented")
+}
+func (UnimplementedAccountServer) GetJWKS(context.Context, *emptypb.Empty) (*JWKSRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetJWKS not implemented")
+}
+func (UnimplementedAccountServer) Impersonate(context.Context, *ImpersonateReq) (*AuthRes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Impersonate not implemented")
+}
+func (UnimplementedAccountServer) WatchRevocations(*WatchRevocationsReq, grpc.ServerStreamingServer[Revocation]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchRevocations not implemented")
+}
+func (UnimplementedAccountServer) GetCodeDeliveryStatus(context.Context, *GetCodeDeliveryStatusReq) (*CodeDeliveryStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCodeDeliveryStatus not implemented")
+}
+func (UnimplementedAccountServer) AddEmail(context.Context, *AddEmailReq) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddEmail not implemented")
+}
+func (UnimplementedAccountServer) VerifyEmail(context.Context, *VerifyEmailReq) (*emptypb.Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyEmail not implemented")
+}
 func (UnimplementedAccountServer) mustEmbedUnimplementedAccountServer() {}
 func (UnimplementedAccountServer) testEmbeddedByValue()                 {}
 
@@ -207,6 +408,215 @@ func _Account_Login_Handler(srv interface{}, ctx context.Context, dec func(inter
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Account_Refresh_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RefreshReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AccountServer).Refresh(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Account_Refresh_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AccountServer).Refresh(ctx, req.(*RefreshReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Account_ValidateSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ValidateSessionReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AccountServer).ValidateSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Account_ValidateSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AccountServer).ValidateSession(ctx, req.(*ValidateSessionReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Account_Logout_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogoutReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AccountServer).Logout(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Account_Logout_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AccountServer).Logout(ctx, req.(*LogoutReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Account_LogoutAll_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LogoutAllReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AccountServer).LogoutAll(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Account_LogoutAll_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AccountServer).LogoutAll(ctx, req.(*LogoutAllReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Account_ListSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSessionsReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AccountServer).ListSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Account_ListSessions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AccountServer).ListSessions(ctx, req.(*ListSessionsReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Account_RevokeSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeSessionReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AccountServer).RevokeSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Account_RevokeSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AccountServer).RevokeSession(ctx, req.(*RevokeSessionReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Account_GetJWKS_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(emptypb.Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AccountServer).GetJWKS(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Account_GetJWKS_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AccountServer).GetJWKS(ctx, req.(*emptypb.Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Account_Impersonate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImpersonateReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AccountServer).Impersonate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Account_Impersonate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AccountServer).Impersonate(ctx, req.(*ImpersonateReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Account_WatchRevocations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRevocationsReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AccountServer).WatchRevocations(m, &grpc.GenericServerStream[WatchRevocationsReq, Revocation]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Account_WatchRevocationsServer = grpc.ServerStreamingServer[Revocation]
+
+func _Account_GetCodeDeliveryStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCodeDeliveryStatusReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AccountServer).GetCodeDeliveryStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Account_GetCodeDeliveryStatus_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AccountServer).GetCodeDeliveryStatus(ctx, req.(*GetCodeDeliveryStatusReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Account_AddEmail_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddEmailReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AccountServer).AddEmail(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Account_AddEmail_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AccountServer).AddEmail(ctx, req.(*AddEmailReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Account_VerifyEmail_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyEmailReq)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AccountServer).VerifyEmail(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Account_VerifyEmail_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AccountServer).VerifyEmail(ctx, req.(*VerifyEmailReq))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Account_ServiceDesc is the grpc.ServiceDesc for Account service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -230,7 +640,57 @@ var Account_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Login",
 			Handler:    _Account_Login_Handler,
 		},
+		{
+			MethodName: "Refresh",
+			Handler:    _Account_Refresh_Handler,
+		},
+		{
+			MethodName: "ValidateSession",
+			Handler:    _Account_ValidateSession_Handler,
+		},
+		{
+			MethodName: "Logout",
+			Handler:    _Account_Logout_Handler,
+		},
+		{
+			MethodName: "LogoutAll",
+			Handler:    _Account_LogoutAll_Handler,
+		},
+		{
+			MethodName: "ListSessions",
+			Handler:    _Account_ListSessions_Handler,
+		},
+		{
+			MethodName: "RevokeSession",
+			Handler:    _Account_RevokeSession_Handler,
+		},
+		{
+			MethodName: "GetJWKS",
+			Handler:    _Account_GetJWKS_Handler,
+		},
+		{
+			MethodName: "Impersonate",
+			Handler:    _Account_Impersonate_Handler,
+		},
+		{
+			MethodName: "GetCodeDeliveryStatus",
+			Handler:    _Account_GetCodeDeliveryStatus_Handler,
+		},
+		{
+			MethodName: "AddEmail",
+			Handler:    _Account_AddEmail_Handler,
+		},
+		{
+			MethodName: "VerifyEmail",
+			Handler:    _Account_VerifyEmail_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchRevocations",
+			Handler:       _Account_WatchRevocations_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/account.proto",
 }